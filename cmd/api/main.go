@@ -2,18 +2,28 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"supmap-gis/internal/api"
+	"supmap-gis/internal/auth"
 	"supmap-gis/internal/config"
+	"supmap-gis/internal/metrics"
+	"supmap-gis/internal/providers/gazetteer"
 	"supmap-gis/internal/providers/nominatim"
+	"supmap-gis/internal/providers/pelias"
+	"supmap-gis/internal/providers/photon"
+	"supmap-gis/internal/providers/srtm"
 	supmapIncidents "supmap-gis/internal/providers/supmap-incidents"
+	"supmap-gis/internal/providers/tolls"
+	"supmap-gis/internal/providers/transport"
 	"supmap-gis/internal/providers/valhalla"
 	"supmap-gis/internal/services"
+	"supmap-gis/internal/tracing"
 	"syscall"
+	"time"
 )
 
 // @title			SupMap GIS API
@@ -39,28 +49,253 @@ func run() error {
 	jsonHandler := slog.NewJSONHandler(os.Stdout, nil)
 	logger := slog.New(jsonHandler)
 
-	nominatimURL := fmt.Sprintf("http://%s:%s", conf.NominatimHost, conf.NominatimPort)
-	nominatimClient := nominatim.NewClient(nominatimURL)
-	logger.Info("Nominatim client initialized", "url", nominatimURL)
+	appMetrics := metrics.New()
 
-	geocodingService := services.NewGeocodingService(nominatimClient)
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Options{
+		Exporter:    tracing.Exporter(conf.TracingExporter),
+		SampleRatio: conf.TracingSampleRatio,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// The run context is canceled on shutdown, so the spans are flushed with a fresh one.
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			logger.Error("Failed to flush traces", "error", err)
+		}
+	}()
+	logger.Info("Tracing initialized", "exporter", conf.TracingExporter)
 
-	supmapIncidentsURL := fmt.Sprintf("http://%s:%s", conf.SupmapIncidentsHost, conf.SupmapIncidentsPort)
-	supmapIncidentsClient := supmapIncidents.NewClient(supmapIncidentsURL)
-	logger.Info("supmap-incidents client initialized", "url", supmapIncidentsURL)
+	nominatimOptions := nominatim.DefaultClientOptions()
+	nominatimOptions.Timeout = conf.Nominatim.Timeout
+	if nominatimOptions.HTTP, err = httpOptions(conf.Nominatim, appMetrics); err != nil {
+		return err
+	}
+	nominatimClient := nominatim.NewClient(conf.Nominatim.BaseURL(), nominatimOptions)
+	logger.Info("Nominatim client initialized", "url", conf.Nominatim.BaseURL())
+
+	geocodingClient, err := newGeocodingClient(conf, nominatimClient, appMetrics, logger)
+	if err != nil {
+		return err
+	}
+
+	geocodingService := services.NewGeocodingService(geocodingClient)
+	autocompleteService := services.NewAutocompleteService(geocodingClient, services.AutocompleteOptions{
+		MinLength: conf.AutocompleteMinLength,
+		CacheSize: conf.AutocompleteCacheSize,
+		CacheTTL:  conf.AutocompleteCacheTTL,
+	})
+	appMetrics.RegisterCache("autocomplete", autocompleteService.Stats)
+
+	batchService := services.NewBatchService(geocodingService, services.BatchOptions{
+		Concurrency:    conf.BatchConcurrency,
+		MaxRunningJobs: conf.BatchMaxJobs,
+		JobTTL:         conf.BatchJobTTL,
+	})
+	// The jobs are canceled once the server has shut down.
+	defer batchService.Close()
+
+	supmapIncidentsOptions := supmapIncidents.DefaultClientOptions()
+	supmapIncidentsOptions.Timeout = conf.SupmapIncidents.Timeout
+	if supmapIncidentsOptions.HTTP, err = httpOptions(conf.SupmapIncidents, appMetrics); err != nil {
+		return err
+	}
+	supmapIncidentsClient := supmapIncidents.NewClient(conf.SupmapIncidents.BaseURL(), supmapIncidentsOptions)
+	logger.Info("supmap-incidents client initialized", "url", conf.SupmapIncidents.BaseURL())
 
 	incidentsService := services.NewIncidentsService(supmapIncidentsClient)
 
-	valhallaURL := fmt.Sprintf("http://%s:%s", conf.ValhallaHost, conf.ValhallaPort)
-	valhallaClient := valhalla.NewClient(valhallaURL)
-	logger.Info("Valhalla client initialized", "url", valhallaURL)
+	valhallaOptions := valhalla.DefaultClientOptions()
+	valhallaOptions.Timeout = conf.Valhalla.Timeout
+	if valhallaOptions.HTTP, err = httpOptions(conf.Valhalla, appMetrics); err != nil {
+		return err
+	}
+	valhallaClient := valhalla.NewClient(conf.Valhalla.BaseURL(), valhallaOptions)
+	logger.Info("Valhalla client initialized", "url", conf.Valhalla.BaseURL())
+
+	placesService := services.NewPlacesService(nominatimClient, nominatimClient, valhallaClient)
+
+	var elevationService *services.ElevationService
+	switch conf.ElevationProvider {
+	case "srtm":
+		srtmClient := srtm.NewClient(conf.ElevationSRTMDir, srtm.ClientOptions{CacheSize: conf.ElevationSRTMCacheSize})
+		appMetrics.RegisterCache("srtm", srtmClient.Stats)
+		elevationService = services.NewElevationService(services.NewSRTMElevationClient(srtmClient), conf.ElevationSampleInterval)
+		logger.Info("SRTM elevation provider initialized", "dir", conf.ElevationSRTMDir, "cache_size", conf.ElevationSRTMCacheSize)
+	case "valhalla":
+		elevationService = services.NewElevationService(services.NewValhallaElevationClient(valhallaClient), conf.ElevationSampleInterval)
+		logger.Info("Valhalla elevation provider initialized", "url", conf.Valhalla.BaseURL())
+	}
+
+	var tollService *services.TollService
+	if conf.TollTariffsFile != "" {
+		tariffs, err := tolls.Load(conf.TollTariffsFile)
+		if err != nil {
+			return err
+		}
+		tollService = services.NewTollService(tariffs, services.TollOptions{TruckClass: tolls.VehicleClass(conf.TollTruckClass)})
+		logger.Info("Toll tariffs loaded", "file", conf.TollTariffsFile, "sections", len(tariffs.Sections))
+	}
+
+	routingOptions := services.DefaultRoutingOptions()
+	routingOptions.AlternatesMaxOverlap = conf.RouteAlternatesMaxOverlap
+	routingOptions.ObserveExcludedIncidents = appMetrics.ObserveExcludedIncidents
+	var routingClient services.RoutingClient = valhallaClient
+	if conf.RouteCacheSize > 0 {
+		routeCache := services.NewCachedRoutingClient(valhallaClient, services.RoutingCacheOptions{
+			Size:      conf.RouteCacheSize,
+			TTL:       conf.RouteCacheTTL,
+			Precision: conf.RouteCachePrecision,
+		})
+		appMetrics.RegisterCache("route", routeCache.Stats)
+		routingClient = routeCache
+		logger.Info("Route cache enabled", "size", conf.RouteCacheSize, "ttl", conf.RouteCacheTTL)
+	}
+	routingService := services.NewRoutingService(routingClient, incidentsService, elevationService, tollService, routingOptions)
 
-	routingService := services.NewRoutingService(valhallaClient, incidentsService)
+	healthService := services.NewHealthService([]services.Dependency{
+		{Name: "valhalla", Checker: valhallaClient, Critical: true},
+		{Name: "nominatim", Checker: nominatimClient, Critical: true},
+		// Routes are still calculated without incidents.
+		{Name: "supmap-incidents", Checker: supmapIncidentsClient, Critical: false},
+	}, services.HealthOptions{
+		Timeout:  conf.HealthCheckTimeout,
+		CacheTTL: conf.HealthCheckCacheTTL,
+	})
 
-	server := api.NewServer(conf, logger, geocodingService, routingService)
+	authenticator, err := newAuthenticator(conf, logger)
+	if err != nil {
+		return err
+	}
+
+	server := api.NewServer(conf, logger, geocodingService, autocompleteService, batchService, placesService, routingService, elevationService, healthService, appMetrics, authenticator)
 	if err := server.Start(ctx); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// newAuthenticator returns the authenticator of the configured credentials, or nil if none is configured.
+func newAuthenticator(conf *config.Config, logger *slog.Logger) (auth.Authenticator, error) {
+	var chain auth.Chain
+	if len(conf.AuthAPIKeys) > 0 {
+		chain = append(chain, auth.NewAPIKeyAuthenticator(conf.AuthAPIKeys))
+		logger.Info("API key authentication enabled", "keys", len(conf.AuthAPIKeys))
+	}
+
+	if conf.AuthJWTSecret != "" || conf.AuthJWTJWKSFile != "" {
+		jwtOptions := auth.DefaultJWTOptions()
+		jwtOptions.Secret = conf.AuthJWTSecret
+		jwtOptions.Issuer = conf.AuthJWTIssuer
+		jwtOptions.Audience = conf.AuthJWTAudience
+		jwtOptions.SubjectClaim = conf.AuthJWTSubjectClaim
+		jwtOptions.RoleClaim = conf.AuthJWTRoleClaim
+		jwtOptions.Leeway = conf.AuthJWTLeeway
+		if conf.AuthJWTJWKSFile != "" {
+			keys, err := auth.LoadKeySet(conf.AuthJWTJWKSFile)
+			if err != nil {
+				return nil, err
+			}
+			jwtOptions.Keys = keys
+		}
+
+		authenticator, err := auth.NewJWTAuthenticator(jwtOptions)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, authenticator)
+		logger.Info("JWT authentication enabled", "hmac", conf.AuthJWTSecret != "", "jwks", conf.AuthJWTJWKSFile)
+	}
+
+	if len(chain) == 0 {
+		logger.Warn("Authentication is disabled, the API is open to anyone who can reach it")
+		return nil, nil
+	}
+	return chain, nil
+}
+
+// httpOptions returns the connection options of the client of an upstream.
+func httpOptions(upstream config.Upstream, appMetrics *metrics.Metrics) (transport.HTTPOptions, error) {
+	options := transport.HTTPOptions{
+		MaxIdleConns: upstream.MaxIdleConns,
+		Instrument:   appMetrics.InstrumentUpstream,
+	}
+
+	if upstream.TLSCAFile != "" || upstream.TLSCertFile != "" {
+		tlsConfig, err := transport.LoadTLSConfig(upstream.TLSCAFile, upstream.TLSCertFile, upstream.TLSKeyFile)
+		if err != nil {
+			return transport.HTTPOptions{}, err
+		}
+		options.TLSConfig = tlsConfig
+	}
+
+	if len(upstream.Headers) > 0 {
+		options.Headers = make(http.Header, len(upstream.Headers))
+		for name, value := range upstream.Headers {
+			options.Headers.Set(name, value)
+		}
+	}
+	return options, nil
+}
+
+// newGeocodingClient returns the geocoding client of the configured backends, composing them
+// if there are several, behind a cache if enabled.
+func newGeocodingClient(conf *config.Config, nominatimClient *nominatim.Client, appMetrics *metrics.Metrics, logger *slog.Logger) (services.GeocodingClient, error) {
+	backends := make([]services.GeocodingBackend, 0, len(conf.GeocodingBackends))
+	for _, name := range conf.GeocodingBackends {
+		var client services.GeocodingClient
+		switch name {
+		case "nominatim":
+			client = nominatimClient
+		case "photon":
+			photonOptions := photon.DefaultClientOptions()
+			photonOptions.Timeout = conf.Photon.Timeout
+			var err error
+			if photonOptions.HTTP, err = httpOptions(conf.Photon, appMetrics); err != nil {
+				return nil, err
+			}
+			client = services.NewPhotonGeocodingClient(photon.NewClient(conf.Photon.BaseURL(), photonOptions))
+			logger.Info("Photon client initialized", "url", conf.Photon.BaseURL())
+		case "pelias":
+			peliasOptions := pelias.DefaultClientOptions()
+			peliasOptions.Timeout = conf.Pelias.Timeout
+			peliasOptions.APIKey = conf.PeliasAPIKey
+			var err error
+			if peliasOptions.HTTP, err = httpOptions(conf.Pelias, appMetrics); err != nil {
+				return nil, err
+			}
+			client = services.NewPeliasGeocodingClient(pelias.NewClient(conf.Pelias.BaseURL(), peliasOptions))
+			logger.Info("Pelias client initialized", "url", conf.Pelias.BaseURL())
+		case "gazetteer":
+			places, err := gazetteer.Load(conf.GazetteerFile)
+			if err != nil {
+				return nil, err
+			}
+			client = services.NewGazetteerGeocodingClient(places, conf.GazetteerReverseMaxDistance)
+			logger.Info("Gazetteer loaded", "file", conf.GazetteerFile)
+		}
+		backends = append(backends, services.GeocodingBackend{Name: name, Client: client})
+	}
+
+	client := backends[0].Client
+	if len(backends) > 1 {
+		client = services.NewCompositeGeocodingClient(services.GeocodingMode(conf.GeocodingMode), backends...)
+		logger.Info("Geocoding backends composed", "backends", conf.GeocodingBackends, "mode", conf.GeocodingMode)
+	}
+
+	if conf.GeocodingCacheSize > 0 {
+		cache := services.NewCachedGeocodingClient(client, services.GeocodingCacheOptions{
+			Size:             conf.GeocodingCacheSize,
+			TTL:              conf.GeocodingCacheTTL,
+			NegativeTTL:      conf.GeocodingCacheNegativeTTL,
+			ReversePrecision: conf.GeocodingCacheReversePrecision,
+		})
+		appMetrics.RegisterCache("geocoding", cache.Stats)
+		client = cache
+		logger.Info("Geocoding cache enabled", "size", conf.GeocodingCacheSize, "ttl", conf.GeocodingCacheTTL)
+	}
+	return client, nil
+}
@@ -0,0 +1,398 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/matheodrd/httphelper/handler"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"supmap-gis/internal/providers/nominatim"
+	"supmap-gis/internal/services"
+)
+
+// GeocodeBatchItem is an address to geocode, either free-form or structured.
+type GeocodeBatchItem struct {
+	Address    string `json:"address,omitempty"`
+	Street     string `json:"street,omitempty"`
+	City       string `json:"city,omitempty"`
+	County     string `json:"county,omitempty"`
+	State      string `json:"state,omitempty"`
+	PostalCode string `json:"postalcode,omitempty"`
+	Country    string `json:"country,omitempty"`
+}
+
+type GeocodeBatchRequest struct {
+	Items []GeocodeBatchItem `json:"items"`
+}
+
+type ReverseBatchItem struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+	// err is set when the item was read from a CSV row whose coordinates can't be parsed.
+	err error
+}
+
+type ReverseBatchRequest struct {
+	Items []ReverseBatchItem `json:"items"`
+}
+
+func (i GeocodeBatchItem) toSearchParams(language string) (nominatim.SearchParams, error) {
+	params := nominatim.SearchParams{
+		Query:      i.Address,
+		Street:     i.Street,
+		City:       i.City,
+		County:     i.County,
+		State:      i.State,
+		PostalCode: i.PostalCode,
+		Country:    i.Country,
+		Language:   language,
+	}
+	if params.Query == "" && !params.IsStructured() {
+		return params, errors.New("missing address")
+	}
+	if params.Query != "" && params.IsStructured() {
+		return params, errors.New("'address' can't be combined with a structured address")
+	}
+	return params, nil
+}
+
+// batchInput returns the body of a batch request, and whether it is a CSV file.
+// CSV files can be sent as the body with a text/csv content type, or uploaded
+// in the "file" field of a multipart form.
+func batchInput(r *http.Request) (io.Reader, bool, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "application/json"
+	}
+
+	switch mediaType {
+	case "text/csv":
+		return r.Body, true, nil
+	case "multipart/form-data":
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, false, fmt.Errorf("missing 'file' field: %w", err)
+		}
+		return file, true, nil
+	default:
+		return r.Body, false, nil
+	}
+}
+
+// readCSV reads a CSV file with a header row, and returns each row as a map of column name to value.
+// It stops reading once the file has more than maxRows rows.
+func readCSV(input io.Reader, maxRows int) ([]map[string]string, error) {
+	reader := csv.NewReader(input)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		if len(rows) == maxRows {
+			return nil, tooManyItems(maxRows)
+		}
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(record) {
+				row[column] = strings.TrimSpace(record[i])
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func decodeGeocodeBatch(r *http.Request, maxItems int) ([]GeocodeBatchItem, error) {
+	input, isCSV, err := batchInput(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isCSV {
+		var req GeocodeBatchRequest
+		if err := json.NewDecoder(input).Decode(&req); err != nil {
+			return nil, fmt.Errorf("failed to decode request body: %w", err)
+		}
+		return req.Items, nil
+	}
+
+	rows, err := readCSV(input, maxItems)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]GeocodeBatchItem, len(rows))
+	for i, row := range rows {
+		items[i] = GeocodeBatchItem{
+			Address:    row["address"],
+			Street:     row["street"],
+			City:       row["city"],
+			County:     row["county"],
+			State:      row["state"],
+			PostalCode: row["postalcode"],
+			Country:    row["country"],
+		}
+	}
+	return items, nil
+}
+
+func decodeReverseBatch(r *http.Request, maxItems int) ([]ReverseBatchItem, error) {
+	input, isCSV, err := batchInput(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isCSV {
+		var req ReverseBatchRequest
+		if err := json.NewDecoder(input).Decode(&req); err != nil {
+			return nil, fmt.Errorf("failed to decode request body: %w", err)
+		}
+		return req.Items, nil
+	}
+
+	rows, err := readCSV(input, maxItems)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]ReverseBatchItem, len(rows))
+	for i, row := range rows {
+		lat, errLat := strconv.ParseFloat(row["lat"], 64)
+		lon, errLon := strconv.ParseFloat(row["lon"], 64)
+		switch {
+		case errLat != nil:
+			items[i].err = errors.New("invalid 'lat'")
+		case errLon != nil:
+			items[i].err = errors.New("invalid 'lon'")
+		default:
+			items[i] = ReverseBatchItem{Lat: lat, Lon: lon}
+		}
+	}
+	return items, nil
+}
+
+// validateBatchSize checks the number of items of a batch, which must be lower
+// for synchronous batches than for jobs.
+func (s *Server) validateBatchSize(count int, async bool) error {
+	if count == 0 {
+		return errors.New("at least 1 item must be provided")
+	}
+	if count > s.Config.BatchMaxItems {
+		return tooManyItems(s.Config.BatchMaxItems)
+	}
+	if !async && count > s.Config.BatchSyncMaxItems {
+		return fmt.Errorf("at most %d items can be processed synchronously, use 'async=true'", s.Config.BatchSyncMaxItems)
+	}
+	return nil
+}
+
+func tooManyItems(maxItems int) error {
+	return fmt.Errorf("at most %d items can be provided", maxItems)
+}
+
+func isAsync(r *http.Request) (bool, error) {
+	if !r.URL.Query().Has("async") {
+		return false, nil
+	}
+	async, err := strconv.ParseBool(r.URL.Query().Get("async"))
+	if err != nil {
+		return false, errors.New("'async' must be a boolean")
+	}
+	return async, nil
+}
+
+// jobError converts the error of a job that couldn't be started to an HTTP error:
+// 503 if the service is busy with other jobs or shutting down, or 500 otherwise.
+func jobError(err error) error {
+	if errors.Is(err, services.ErrTooManyJobs) || errors.Is(err, services.ErrBatchServiceClosed) {
+		return newStatusError(http.StatusServiceUnavailable, err)
+	}
+	return newStatusError(http.StatusInternalServerError, err)
+}
+
+// writeJobCreated responds that a job was created, with a link to follow it.
+func writeJobCreated(w http.ResponseWriter, job services.Job) error {
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	resp := handler.Response[services.Job]{
+		Data:    &job,
+		Message: "job created",
+	}
+	return handler.Encode[handler.Response[services.Job]](resp, http.StatusAccepted, w)
+}
+
+// @Summary Géocodage par lot
+// @Description Géocode une liste d'adresses (JSON ou fichier CSV avec une ligne d'en-tête: address, street, city, county, state, postalcode, country). Les résultats sont renvoyés dans l'ordre des adresses, avec une erreur par adresse le cas échéant. Avec 'async=true', un job est créé et peut être suivi avec GET /jobs/{id}.
+// @Tags geocoding
+// @Accept json
+// @Accept text/csv
+// @Accept multipart/form-data
+// @Produce json
+// @Param geocodeBatchRequest body GeocodeBatchRequest true "Adresses à géocoder"
+// @Param async query bool false "Traite le lot en arrière-plan"
+// @Param Accept-Language header string false "Langue des résultats (ex: 'fr-BE, en;q=0.8'). Défaut: fr-FR"
+// @Success 200 {object} handler.Response[[]services.BatchItem[[]services.Place]]
+// @Success 202 {object} handler.Response[services.Job] "Job créé"
+// @Failure 400 {object} ErrResponse "Corps de la requête invalide"
+// @Failure 413 {object} ErrResponse "Corps de la requête trop volumineux"
+// @Failure 500 {object} ErrResponse "Erreur interne du serveur"
+// @Failure 503 {object} ErrResponse "Trop de jobs en cours, avec 'async=true'"
+// @Router /geocode/batch [post]
+func (s *Server) geocodeBatchHandler() http.HandlerFunc {
+	return handle(func(w http.ResponseWriter, r *http.Request) error {
+		async, err := isAsync(r)
+		if err != nil {
+			return newStatusError(http.StatusBadRequest, err)
+		}
+
+		items, err := decodeGeocodeBatch(r, s.Config.BatchMaxItems)
+		if err != nil {
+			return bodyError(err)
+		}
+		if err := s.validateBatchSize(len(items), async); err != nil {
+			return newStatusError(http.StatusBadRequest, err)
+		}
+
+		// Invalid items have an error in their result, like the items that fail.
+		language := negotiateLanguage(r)
+		searches := make([]services.BatchInput[nominatim.SearchParams], len(items))
+		for i, item := range items {
+			params, err := item.toSearchParams(language)
+			searches[i] = services.BatchInput[nominatim.SearchParams]{Params: params, Err: err}
+		}
+
+		if async {
+			job, err := s.batchService.StartGeocodeJob(searches)
+			if err != nil {
+				return jobError(err)
+			}
+			if err := writeJobCreated(w, job); err != nil {
+				return newStatusError(http.StatusInternalServerError, err)
+			}
+			return nil
+		}
+
+		results := s.batchService.GeocodeBatch(r.Context(), searches)
+		resp := handler.Response[[]services.BatchItem[[]services.Place]]{
+			Data:    &results,
+			Message: "success",
+		}
+
+		if err := handler.Encode[handler.Response[[]services.BatchItem[[]services.Place]]](resp, http.StatusOK, w); err != nil {
+			return newStatusError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+// @Summary Géocodage inverse par lot
+// @Description Retourne l'adresse de chaque position d'une liste (JSON ou fichier CSV avec une ligne d'en-tête: lat, lon). Les résultats sont renvoyés dans l'ordre des positions, avec une erreur par position le cas échéant. Avec 'async=true', un job est créé et peut être suivi avec GET /jobs/{id}.
+// @Tags geocoding
+// @Accept json
+// @Accept text/csv
+// @Accept multipart/form-data
+// @Produce json
+// @Param reverseBatchRequest body ReverseBatchRequest true "Positions dont on souhaite l'adresse"
+// @Param async query bool false "Traite le lot en arrière-plan"
+// @Param Accept-Language header string false "Langue des adresses (ex: 'nl-BE'). Défaut: fr-FR"
+// @Success 200 {object} handler.Response[[]services.BatchItem[services.Address]]
+// @Success 202 {object} handler.Response[services.Job] "Job créé"
+// @Failure 400 {object} ErrResponse "Corps de la requête invalide"
+// @Failure 413 {object} ErrResponse "Corps de la requête trop volumineux"
+// @Failure 500 {object} ErrResponse "Erreur interne du serveur"
+// @Failure 503 {object} ErrResponse "Trop de jobs en cours, avec 'async=true'"
+// @Router /address/batch [post]
+func (s *Server) addressBatchHandler() http.HandlerFunc {
+	return handle(func(w http.ResponseWriter, r *http.Request) error {
+		async, err := isAsync(r)
+		if err != nil {
+			return newStatusError(http.StatusBadRequest, err)
+		}
+
+		items, err := decodeReverseBatch(r, s.Config.BatchMaxItems)
+		if err != nil {
+			return bodyError(err)
+		}
+		if err := s.validateBatchSize(len(items), async); err != nil {
+			return newStatusError(http.StatusBadRequest, err)
+		}
+
+		language := negotiateLanguage(r)
+		positions := make([]services.BatchInput[nominatim.ReverseParams], len(items))
+		for i, item := range items {
+			positions[i] = services.BatchInput[nominatim.ReverseParams]{
+				Params: nominatim.ReverseParams{Lat: item.Lat, Lon: item.Lon, Language: language},
+				Err:    item.err,
+			}
+		}
+
+		if async {
+			job, err := s.batchService.StartReverseJob(positions)
+			if err != nil {
+				return jobError(err)
+			}
+			if err := writeJobCreated(w, job); err != nil {
+				return newStatusError(http.StatusInternalServerError, err)
+			}
+			return nil
+		}
+
+		results := s.batchService.ReverseBatch(r.Context(), positions)
+		resp := handler.Response[[]services.BatchItem[services.Address]]{
+			Data:    &results,
+			Message: "success",
+		}
+
+		if err := handler.Encode[handler.Response[[]services.BatchItem[services.Address]]](resp, http.StatusOK, w); err != nil {
+			return newStatusError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+// @Summary État d'un job
+// @Description Retourne l'avancement d'un traitement par lot, et ses résultats une fois terminé. Les jobs terminés sont conservés pendant une durée limitée.
+// @Tags geocoding
+// @Produce json
+// @Param id path string true "Identifiant du job"
+// @Success 200 {object} handler.Response[services.Job]
+// @Failure 404 {object} ErrResponse "Job inconnu ou expiré"
+// @Failure 500 {object} ErrResponse "Erreur interne du serveur"
+// @Router /jobs/{id} [get]
+func (s *Server) jobHandler() http.HandlerFunc {
+	return handle(func(w http.ResponseWriter, r *http.Request) error {
+		job, ok := s.batchService.Job(r.PathValue("id"))
+		if !ok {
+			return newStatusError(http.StatusNotFound, fmt.Errorf("job %q not found", r.PathValue("id")))
+		}
+
+		resp := handler.Response[services.Job]{
+			Data:    &job,
+			Message: "success",
+		}
+
+		if err := handler.Encode[handler.Response[services.Job]](resp, http.StatusOK, w); err != nil {
+			return newStatusError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeReverseBatchCSV(t *testing.T) {
+	body := "lat,lon\n49.18,-0.36\nabc,-0.36\n49.18,\n48.85,2.35\n"
+	r := httptest.NewRequest(http.MethodPost, "/address/batch", strings.NewReader(body))
+	r.Header.Set("Content-Type", "text/csv")
+
+	items, err := decodeReverseBatch(r, 10)
+	if err != nil {
+		t.Fatalf("decodeReverseBatch() error = %v", err)
+	}
+	if len(items) != 4 {
+		t.Fatalf("got %d items, want 4", len(items))
+	}
+
+	wantErrs := []string{"", "invalid 'lat'", "invalid 'lon'", ""}
+	for i, want := range wantErrs {
+		var got string
+		if items[i].err != nil {
+			got = items[i].err.Error()
+		}
+		if got != want {
+			t.Errorf("items[%d].err = %q, want %q", i, got, want)
+		}
+	}
+	if items[3].Lat != 48.85 || items[3].Lon != 2.35 {
+		t.Errorf("items[3] = %+v, want 48.85,2.35", items[3])
+	}
+}
+
+func TestDecodeGeocodeBatchCSVMaxItems(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    int
+		wantErr bool
+	}{
+		{"under the limit", 2, false},
+		{"at the limit", 3, false},
+		{"over the limit", 4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := "address\n" + strings.Repeat("1 rue de la Paix Paris\n", tt.rows)
+			r := httptest.NewRequest(http.MethodPost, "/geocode/batch", strings.NewReader(body))
+			r.Header.Set("Content-Type", "text/csv")
+
+			items, err := decodeGeocodeBatch(r, 3)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeGeocodeBatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(items) != tt.rows {
+				t.Errorf("got %d items, want %d", len(items), tt.rows)
+			}
+		})
+	}
+}
+
+func TestBatchBodyTooLarge(t *testing.T) {
+	h := WithMaxBodyBytes(64, handle(func(w http.ResponseWriter, r *http.Request) error {
+		_, err := decodeGeocodeBatch(r, 1000)
+		if err != nil {
+			return bodyError(err)
+		}
+		return nil
+	}))
+
+	body := "address\n" + strings.Repeat("1 rue de la Paix Paris\n", 10)
+	r := httptest.NewRequest(http.MethodPost, "/geocode/batch", strings.NewReader(body))
+	r.Header.Set("Content-Type", "text/csv")
+	// Without a Content-Length, the limit is only hit while reading the body.
+	r.ContentLength = -1
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+}
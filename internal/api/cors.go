@@ -0,0 +1,139 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSPolicy is the cross-origin resource sharing policy of the API.
+type CORSPolicy struct {
+	// AllowedOrigins are the origins allowed to call the API: "*" for any origin, an origin such as
+	// "https://app.supmap.fr", or an origin with a wildcard subdomain such as "https://*.supmap.fr",
+	// which doesn't match the domain itself.
+	AllowedOrigins []string
+	// AllowCredentials lets browsers send cookies and Authorization headers.
+	AllowCredentials bool
+	// AllowedHeaders are the request headers that browsers may send.
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers that browsers let scripts read.
+	ExposedHeaders []string
+	// MaxAge is how long browsers may cache the result of a preflight request.
+	MaxAge time.Duration
+}
+
+// corsMethods are the methods for which a route can be allowed by a preflight request.
+var corsMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// WithCORS applies policy to the requests handled by mux. Preflight requests are answered
+// according to the routes registered on mux: the allowed methods are those of the requested path,
+// and the request is rejected if the path or the requested method isn't handled.
+func WithCORS(policy CORSPolicy, mux *http.ServeMux) http.Handler {
+	allowedHeaders := strings.Join(policy.AllowedHeaders, ", ")
+	exposedHeaders := strings.Join(policy.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(policy.MaxAge.Seconds()))
+
+	// The response depends on the origin, unless any origin gets the same answer.
+	anyOrigin := slices.Contains(policy.AllowedOrigins, "*") && !policy.AllowCredentials
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Requests without an origin vary too: a shared cache must not serve their response,
+		// which has no CORS headers, to a cross-origin request.
+		if !anyOrigin {
+			w.Header().Add("Vary", "Origin")
+		}
+
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		allowed := policy.allows(origin)
+		if allowed {
+			if anyOrigin {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if policy.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		requestedMethod := r.Header.Get("Access-Control-Request-Method")
+		if r.Method != http.MethodOptions || requestedMethod == "" {
+			if allowed && exposedHeaders != "" {
+				w.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+			}
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		// Preflight request.
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		if !allowed {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		methods := routeMethods(mux, r)
+		if len(methods) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !slices.Contains(methods, requestedMethod) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		if allowedHeaders != "" {
+			w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+		}
+		w.Header().Set("Access-Control-Max-Age", maxAge)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// routeMethods returns the methods handled by mux for the path of r.
+func routeMethods(mux *http.ServeMux, r *http.Request) []string {
+	var methods []string
+	for _, method := range corsMethods {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		if _, pattern := mux.Handler(probe); pattern != "" {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// allows reports whether origin is allowed by the policy.
+func (p CORSPolicy) allows(origin string) bool {
+	originURL, err := url.Parse(origin)
+	if err != nil || originURL.Host == "" {
+		return false
+	}
+
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+
+		scheme, host, ok := strings.Cut(allowed, "://*.")
+		if !ok || !strings.EqualFold(scheme, originURL.Scheme) {
+			continue
+		}
+		// The wildcard matches one or more subdomains, and the port must be the same.
+		domain, port, _ := strings.Cut(host, ":")
+		if port == originURL.Port() && strings.HasSuffix(strings.ToLower(originURL.Hostname()), "."+strings.ToLower(domain)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORSPolicyAllows(t *testing.T) {
+	policy := CORSPolicy{AllowedOrigins: []string{"https://app.supmap.fr", "https://*.supmap.dev", "http://*.localhost:3000"}}
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://app.supmap.fr", true},
+		{"HTTPS://APP.SUPMAP.FR", true},
+		{"https://admin.supmap.fr", false},
+		{"http://app.supmap.fr", false},
+		{"https://app.supmap.fr:8443", false},
+		// The wildcard matches one or more subdomains, but not the domain itself.
+		{"https://preview.supmap.dev", true},
+		{"https://pr-42.preview.supmap.dev", true},
+		{"https://supmap.dev", false},
+		{"https://evilsupmap.dev", false},
+		{"https://supmap.dev.evil.com", false},
+		{"http://preview.supmap.dev", false},
+		{"https://preview.supmap.dev:8443", false},
+		// The port of a wildcard origin must match.
+		{"http://web.localhost:3000", true},
+		{"http://web.localhost:3001", false},
+		{"http://web.localhost", false},
+		{"null", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			if got := policy.allows(tt.origin); got != tt.want {
+				t.Errorf("allows(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+
+	anyOrigin := CORSPolicy{AllowedOrigins: []string{"*"}}
+	if !anyOrigin.allows("https://example.com") {
+		t.Error("* doesn't allow any origin")
+	}
+}
+
+func TestWithCORS(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /geocode", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("POST /route", func(w http.ResponseWriter, r *http.Request) {})
+
+	policy := CORSPolicy{
+		AllowedOrigins:   []string{"https://*.supmap.fr"},
+		AllowCredentials: true,
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		ExposedHeaders:   []string{"X-Request-ID"},
+		MaxAge:           10 * time.Minute,
+	}
+	h := WithCORS(policy, mux)
+
+	tests := []struct {
+		name            string
+		method          string
+		path            string
+		origin          string
+		requestMethod   string
+		wantStatus      int
+		wantAllowOrigin string
+		wantHeaders     map[string]string
+	}{
+		{
+			name:            "preflight",
+			method:          http.MethodOptions,
+			path:            "/route",
+			origin:          "https://app.supmap.fr",
+			requestMethod:   http.MethodPost,
+			wantStatus:      http.StatusNoContent,
+			wantAllowOrigin: "https://app.supmap.fr",
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Methods":     "POST",
+				"Access-Control-Allow-Headers":     "Content-Type, Authorization",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Max-Age":           "600",
+			},
+		},
+		{
+			name:            "preflight of GET route lists HEAD",
+			method:          http.MethodOptions,
+			path:            "/geocode",
+			origin:          "https://app.supmap.fr",
+			requestMethod:   http.MethodGet,
+			wantStatus:      http.StatusNoContent,
+			wantAllowOrigin: "https://app.supmap.fr",
+			wantHeaders:     map[string]string{"Access-Control-Allow-Methods": "GET, HEAD"},
+		},
+		{
+			name:          "preflight from a disallowed origin",
+			method:        http.MethodOptions,
+			path:          "/route",
+			origin:        "https://evil.com",
+			requestMethod: http.MethodPost,
+			wantStatus:    http.StatusForbidden,
+		},
+		{
+			name:            "preflight of an unknown path",
+			method:          http.MethodOptions,
+			path:            "/unknown",
+			origin:          "https://app.supmap.fr",
+			requestMethod:   http.MethodGet,
+			wantStatus:      http.StatusNotFound,
+			wantAllowOrigin: "https://app.supmap.fr",
+		},
+		{
+			name:            "preflight of an unhandled method",
+			method:          http.MethodOptions,
+			path:            "/route",
+			origin:          "https://app.supmap.fr",
+			requestMethod:   http.MethodDelete,
+			wantStatus:      http.StatusMethodNotAllowed,
+			wantAllowOrigin: "https://app.supmap.fr",
+		},
+		{
+			name:            "simple request",
+			method:          http.MethodGet,
+			path:            "/geocode",
+			origin:          "https://app.supmap.fr",
+			wantStatus:      http.StatusOK,
+			wantAllowOrigin: "https://app.supmap.fr",
+			wantHeaders:     map[string]string{"Access-Control-Expose-Headers": "X-Request-ID", "Vary": "Origin"},
+		},
+		{
+			name:        "request from a disallowed origin is still served",
+			method:      http.MethodGet,
+			path:        "/geocode",
+			origin:      "https://evil.com",
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"Vary": "Origin"},
+		},
+		{
+			name:       "request without origin",
+			method:     http.MethodGet,
+			path:       "/geocode",
+			wantStatus: http.StatusOK,
+			// Its response, without CORS headers, must not be reused for other origins.
+			wantHeaders: map[string]string{"Vary": "Origin"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.requestMethod != "" {
+				r.Header.Set("Access-Control-Request-Method", tt.requestMethod)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantAllowOrigin)
+			}
+			for header, want := range tt.wantHeaders {
+				if got := w.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWithCORSAnyOrigin(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /geocode", func(w http.ResponseWriter, r *http.Request) {})
+	h := WithCORS(CORSPolicy{AllowedOrigins: []string{"*"}}, mux)
+
+	for _, origin := range []string{"https://example.com", ""} {
+		r := httptest.NewRequest(http.MethodGet, "/geocode", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		// The same answer is given to any origin, so that caches don't need to vary on it.
+		if got := w.Header().Get("Access-Control-Allow-Origin"); origin != "" && got != "*" {
+			t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+		}
+		if got := w.Header().Get("Vary"); got != "" {
+			t.Errorf("Vary with origin %q = %q, want none", origin, got)
+		}
+	}
+}
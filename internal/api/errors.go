@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"supmap-gis/internal/logging"
+	"supmap-gis/internal/providers/transport"
+)
+
+// ErrResponse is the body of the error responses.
+type ErrResponse struct {
+	Message string `json:"message"`
+	// RequestID identifies the request in the logs.
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// handle wraps a handler returning an error, answered with its status if it's a [statusError]
+// or with 500 otherwise. The error is logged with the logger of the request, and the request ID
+// is returned in the error response. Only the messages of 400, 409, 413 and 422 errors are returned, other errors get their status text.
+func handle(f func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := f(w, r)
+		if err == nil {
+			return
+		}
+
+		status := http.StatusInternalServerError
+		msg := http.StatusText(status)
+		var e *statusError
+		if errors.As(err, &e) {
+			status = e.status
+			msg = http.StatusText(status)
+			switch status {
+			case http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+				msg = e.Error()
+			}
+		}
+
+		logger := logging.FromContext(r.Context())
+		logger.Error("error executing handler", "error", err, "status", status, "message", msg)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		resp := ErrResponse{Message: msg, RequestID: logging.RequestID(r.Context())}
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			logger.Error("failed to encode response", "error", err)
+		}
+	}
+}
+
+// statusError is an error answered by [handle] with its status.
+type statusError struct {
+	status int
+	err    error
+}
+
+// newStatusError returns an error answered with status.
+func newStatusError(status int, err error) error {
+	return &statusError{status: status, err: err}
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+// serviceError converts the error of a service call to an HTTP error: 503 with a Retry-After header
+// if the circuit breaker of an upstream is open, so that clients back off, or 500 otherwise.
+func serviceError(w http.ResponseWriter, err error) error {
+	var circuitErr *transport.CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(circuitErr.RetryAfter.Seconds()))))
+		return newStatusError(http.StatusServiceUnavailable, err)
+	}
+	return newStatusError(http.StatusInternalServerError, err)
+}
+
+// bodyError converts an error reading the body of a request to a 413 error if the body exceeds
+// the limit set by [WithMaxBodyBytes], or to a 400 error otherwise.
+func bodyError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return bodyTooLarge(maxBytesErr.Limit)
+	}
+	return newStatusError(http.StatusBadRequest, err)
+}
+
+// bodyTooLarge returns the 413 error of a request body exceeding limit bytes.
+func bodyTooLarge(limit int64) error {
+	return newStatusError(http.StatusRequestEntityTooLarge, fmt.Errorf("request body must not exceed %d bytes", limit))
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantMessage string
+	}{
+		{"bad request", newStatusError(http.StatusBadRequest, errors.New("missing 'q' query parameter")), http.StatusBadRequest, "missing 'q' query parameter"},
+		{"body too large", bodyTooLarge(1024), http.StatusRequestEntityTooLarge, "request body must not exceed 1024 bytes"},
+		{"wrapped status error", fmt.Errorf("geocoding: %w", newStatusError(http.StatusConflict, errors.New("job already done"))), http.StatusConflict, "job already done"},
+		// The messages of other errors may contain internal details.
+		{"internal error", newStatusError(http.StatusBadGateway, errors.New("dial tcp 10.0.0.3:8080: connection refused")), http.StatusBadGateway, "Bad Gateway"},
+		{"error without status", errors.New("unexpected"), http.StatusInternalServerError, "Internal Server Error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := handle(func(w http.ResponseWriter, r *http.Request) error { return tt.err })
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/geocode", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			var resp ErrResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if resp.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", resp.Message, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -5,34 +5,48 @@ import (
 	"fmt"
 	"github.com/matheodrd/httphelper/handler"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"supmap-gis/internal/providers/nominatim"
 	"supmap-gis/internal/providers/valhalla"
 	"supmap-gis/internal/services"
 )
 
-// ErrResponse is here for the sole purpose of being used in swaggo annotations.
-type ErrResponse struct {
-	Message string `json:"message"`
-}
-
 // @Summary Géocode une adresse
-// @Description Convertit une adresse en coordonnées. Plusieurs résultats peuvent être renvoyés.
+// @Description Convertit une adresse en coordonnées. Plusieurs résultats peuvent être renvoyés. L'adresse peut être libre ('address') ou structurée ('street', 'city', 'postalcode'...), et la recherche peut être restreinte à des pays ou à une zone.
 // @Tags geocoding
 // @Produce json
-// @Param address query string true "Adresse dont on souhaite avoir les coordonnées GPS. Exemple: 'Abbaye aux Dames Caen'"
+// @Param address query string false "Adresse libre dont on souhaite avoir les coordonnées GPS. Exemple: 'Abbaye aux Dames Caen'. Obligatoire si aucun champ structuré n'est fourni."
+// @Param street query string false "Numéro et nom de rue (recherche structurée)"
+// @Param city query string false "Ville (recherche structurée)"
+// @Param county query string false "Département (recherche structurée)"
+// @Param state query string false "Région (recherche structurée)"
+// @Param postalcode query string false "Code postal (recherche structurée)"
+// @Param country query string false "Pays (recherche structurée)"
+// @Param countrycodes query string false "Codes pays ISO 3166-1 alpha-2 séparés par des virgules (ex: 'fr,be')"
+// @Param viewbox query string false "Zone privilégiée 'minLon,minLat,maxLon,maxLat' (ex: '-0.42,49.16,-0.33,49.21')"
+// @Param bounded query bool false "Exclut les résultats hors de 'viewbox'"
+// @Param limit query int false "Nombre maximal de résultats (1 à 40, défaut 10)"
+// @Param layer query string false "Thèmes séparés par des virgules: address, poi, railway, natural, manmade"
+// @Param featureType query string false "Type de lieu: country, state, city, settlement"
+// @Param dedupe query bool false "Supprime les doublons (défaut true)"
+// @Param Accept-Language header string false "Langue des résultats (ex: 'fr-BE, en;q=0.8'). Défaut: fr-FR"
 // @Success 200 {object} handler.Response[[]services.Place]
+// @Failure 400 {object} ErrResponse "Paramètre de requête manquant ou invalide"
 // @Failure 500 {object} ErrResponse "Erreur interne du serveur"
 // @Router /geocode [get]
 func (s *Server) geocodeHandler() http.HandlerFunc {
-	return handler.Handler(func(w http.ResponseWriter, r *http.Request) error {
-		address := r.URL.Query().Get("address")
-		if address == "" {
-			return handler.NewErrWithStatus(http.StatusBadRequest, fmt.Errorf("missing 'address' query parameter"))
+	return handle(func(w http.ResponseWriter, r *http.Request) error {
+		params, err := searchParamsFromQuery(r.URL.Query())
+		if err != nil {
+			return newStatusError(http.StatusBadRequest, err)
 		}
+		params.Language = negotiateLanguage(r)
 
-		result, err := s.geocodingService.Search(r.Context(), address)
+		result, err := s.geocodingService.Search(r.Context(), params)
 		if err != nil {
-			return handler.NewErrWithStatus(http.StatusInternalServerError, fmt.Errorf("geocoding address: %w", err))
+			return serviceError(w, fmt.Errorf("geocoding address: %w", err))
 		}
 
 		resp := handler.Response[[]services.Place]{
@@ -41,7 +55,157 @@ func (s *Server) geocodeHandler() http.HandlerFunc {
 		}
 
 		if err := handler.Encode[handler.Response[[]services.Place]](resp, http.StatusOK, w); err != nil {
-			return handler.NewErrWithStatus(http.StatusInternalServerError, err)
+			return newStatusError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+// searchParamsFromQuery builds and validates the search parameters of a /geocode request.
+func searchParamsFromQuery(query url.Values) (nominatim.SearchParams, error) {
+	params := nominatim.SearchParams{
+		Query:       query.Get("address"),
+		Street:      query.Get("street"),
+		City:        query.Get("city"),
+		County:      query.Get("county"),
+		State:       query.Get("state"),
+		PostalCode:  query.Get("postalcode"),
+		Country:     query.Get("country"),
+		FeatureType: query.Get("featureType"),
+	}
+
+	if params.Query == "" && !params.IsStructured() {
+		return params, errors.New("missing 'address' query parameter or structured address")
+	}
+	if params.Query != "" && params.IsStructured() {
+		return params, errors.New("'address' can't be combined with a structured address")
+	}
+
+	if query.Has("countrycodes") {
+		for _, code := range strings.Split(query.Get("countrycodes"), ",") {
+			code = strings.ToLower(strings.TrimSpace(code))
+			if len(code) != 2 {
+				return params, fmt.Errorf("invalid country code %q in 'countrycodes'", code)
+			}
+			params.CountryCodes = append(params.CountryCodes, code)
+		}
+	}
+
+	if query.Has("viewbox") {
+		var coords [4]float64
+		parts := strings.Split(query.Get("viewbox"), ",")
+		if len(parts) != 4 {
+			return params, errors.New("'viewbox' must be 'minLon,minLat,maxLon,maxLat'")
+		}
+		for i, part := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return params, errors.New("'viewbox' must be 'minLon,minLat,maxLon,maxLat'")
+			}
+			coords[i] = v
+		}
+		params.ViewBox = &nominatim.ViewBox{MinLon: coords[0], MinLat: coords[1], MaxLon: coords[2], MaxLat: coords[3]}
+	}
+
+	if query.Has("bounded") {
+		bounded, err := strconv.ParseBool(query.Get("bounded"))
+		if err != nil {
+			return params, errors.New("'bounded' must be a boolean")
+		}
+		if bounded && params.ViewBox == nil {
+			return params, errors.New("'bounded' requires a 'viewbox'")
+		}
+		params.Bounded = bounded
+	}
+
+	if query.Has("limit") {
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 1 || limit > 40 {
+			return params, errors.New("'limit' must be an integer between 1 and 40")
+		}
+		params.Limit = limit
+	}
+
+	if query.Has("layer") {
+		for _, layer := range strings.Split(query.Get("layer"), ",") {
+			switch layer = strings.TrimSpace(layer); layer {
+			case "address", "poi", "railway", "natural", "manmade":
+				params.Layers = append(params.Layers, layer)
+			default:
+				return params, fmt.Errorf("invalid layer %q", layer)
+			}
+		}
+	}
+
+	switch params.FeatureType {
+	case "", "country", "state", "city", "settlement":
+	default:
+		return params, fmt.Errorf("invalid featureType %q", params.FeatureType)
+	}
+
+	if query.Has("dedupe") {
+		dedupe, err := strconv.ParseBool(query.Get("dedupe"))
+		if err != nil {
+			return params, errors.New("'dedupe' must be a boolean")
+		}
+		params.Dedupe = &dedupe
+	}
+
+	return params, nil
+}
+
+// @Summary Autocomplétion d'adresses
+// @Description Suggère des lieux pendant la saisie d'une adresse. Les résultats peuvent être classés selon la proximité de la position de l'utilisateur.
+// @Tags geocoding
+// @Produce json
+// @Param q query string true "Début de l'adresse saisie. Aucune suggestion n'est renvoyée en dessous de la longueur minimale."
+// @Param lat query number false "Latitude de l'utilisateur (ex: 49.0677)"
+// @Param lon query number false "Longitude de l'utilisateur (ex: -0.6658)"
+// @Param limit query int false "Nombre maximal de suggestions (1 à 10, défaut 5)"
+// @Param Accept-Language header string false "Langue des résultats (ex: 'fr-BE, en;q=0.8'). Défaut: fr-FR"
+// @Success 200 {object} handler.Response[[]services.Suggestion]
+// @Failure 400 {object} ErrResponse "Paramètre de requête manquant ou invalide"
+// @Failure 500 {object} ErrResponse "Erreur interne du serveur"
+// @Router /autocomplete [get]
+func (s *Server) autocompleteHandler() http.HandlerFunc {
+	return handle(func(w http.ResponseWriter, r *http.Request) error {
+		query := r.URL.Query()
+		if !query.Has("q") {
+			return newStatusError(http.StatusBadRequest, fmt.Errorf("missing 'q' query parameter"))
+		}
+
+		limit := 5
+		if query.Has("limit") {
+			l, err := strconv.Atoi(query.Get("limit"))
+			if err != nil || l < 1 || l > 10 {
+				return newStatusError(http.StatusBadRequest, fmt.Errorf("'limit' must be an integer between 1 and 10"))
+			}
+			limit = l
+		}
+
+		var position *services.Point
+		if query.Has("lat") || query.Has("lon") {
+			lat, errLat := strconv.ParseFloat(query.Get("lat"), 64)
+			lon, errLon := strconv.ParseFloat(query.Get("lon"), 64)
+			if errLat != nil || errLon != nil {
+				return newStatusError(http.StatusBadRequest, fmt.Errorf("'lat' and 'lon' must both be valid numbers"))
+			}
+			position = &services.Point{Lat: lat, Lon: lon}
+		}
+
+		result, err := s.autocompleteService.Suggest(r.Context(), query.Get("q"), position, limit, negotiateLanguage(r))
+		if err != nil {
+			return serviceError(w, fmt.Errorf("autocomplete: %w", err))
+		}
+
+		resp := handler.Response[[]services.Suggestion]{
+			Data:    &result,
+			Message: "success",
+		}
+
+		if err := handler.Encode[handler.Response[[]services.Suggestion]](resp, http.StatusOK, w); err != nil {
+			return newStatusError(http.StatusInternalServerError, err)
 		}
 
 		return nil
@@ -54,7 +218,10 @@ type RouteRequest struct {
 	Costing          valhalla.Costing            `json:"costing"`
 	CostingOptions   *valhalla.CostingOptions    `json:"costing_options,omitempty"`
 	Language         *string                     `json:"language,omitempty"`
+	Units            *valhalla.Units             `json:"units,omitempty"`
 	Alternates       *int                        `json:"alternates,omitempty"`
+	Elevation        bool                        `json:"elevation,omitempty"`
+	VehicleProfile   services.VehicleProfileName `json:"vehicle_profile,omitempty"`
 }
 
 func (r RouteRequest) Validate() error {
@@ -64,18 +231,83 @@ func (r RouteRequest) Validate() error {
 	if !r.Costing.IsValid() {
 		return errors.New(fmt.Sprintf("costing %q is invalid", r.Costing))
 	}
+	if r.Units != nil && !r.Units.IsValid() {
+		return fmt.Errorf("units %q are invalid", *r.Units)
+	}
+	return nil
+}
+
+// RouteLimits bound the complexity of the route requests, so that a single request can't monopolize Valhalla.
+// A zero value disables the corresponding limit.
+type RouteLimits struct {
+	MaxLocations        int
+	MaxExcludeLocations int
+	MaxAlternates       int
+	// MaxLegDistance is the maximum straight-line distance in meters between consecutive locations.
+	MaxLegDistance float64
+}
+
+// defaultAlternates is the number of alternate routes computed when the request doesn't specify it.
+const defaultAlternates = 2
+
+// DefaultAlternates returns the number of alternates computed by default, within the limit.
+func (l RouteLimits) DefaultAlternates() int {
+	if l.MaxAlternates > 0 {
+		return min(defaultAlternates, l.MaxAlternates)
+	}
+	return defaultAlternates
+}
+
+// CheckLimits returns an error describing the first limit exceeded by the request.
+func (r RouteRequest) CheckLimits(limits RouteLimits) error {
+	if limits.MaxLocations > 0 && len(r.Locations) > limits.MaxLocations {
+		return fmt.Errorf("at most %d locations can be provided, got %d", limits.MaxLocations, len(r.Locations))
+	}
+	if limits.MaxExcludeLocations > 0 && len(r.ExcludeLocations) > limits.MaxExcludeLocations {
+		return fmt.Errorf("at most %d exclude_locations can be provided, got %d", limits.MaxExcludeLocations, len(r.ExcludeLocations))
+	}
+	if limits.MaxAlternates > 0 && r.Alternates != nil && *r.Alternates > limits.MaxAlternates {
+		return fmt.Errorf("at most %d alternates can be requested, got %d", limits.MaxAlternates, *r.Alternates)
+	}
+	if limits.MaxLegDistance > 0 {
+		for i := 1; i < len(r.Locations); i++ {
+			from := services.Point{Lat: r.Locations[i-1].Lat, Lon: r.Locations[i-1].Lon}
+			to := services.Point{Lat: r.Locations[i].Lat, Lon: r.Locations[i].Lon}
+			if distance := services.Distance(from, to); distance > limits.MaxLegDistance {
+				return fmt.Errorf("locations %d and %d are %.0f km apart, more than the maximum of %.0f km", i-1, i, distance/1000, limits.MaxLegDistance/1000)
+			}
+		}
+	}
 	return nil
 }
 
+// RouteOptions returns the options of the request that are handled by the routing service rather than Valhalla.
+func (r RouteRequest) RouteOptions() services.RouteOptions {
+	return services.RouteOptions{
+		Elevation:      r.Elevation,
+		VehicleProfile: r.VehicleProfile,
+	}
+}
+
 // ToValhallaRequest converts a API request to a [valhalla.RouteRequest],
-// and applies default values if necessary.
-func (r RouteRequest) ToValhallaRequest() valhalla.RouteRequest {
+// and applies default values if necessary. The language of the request is
+// matched to the closest one supported by Valhalla, the negotiated language
+// being used when the request doesn't specify one or none matches. Units
+// default to the ones commonly used with the resulting language.
+func (r RouteRequest) ToValhallaRequest(negotiated valhalla.Language) valhalla.RouteRequest {
 	// Default values
-	language := "fr-FR"
-	alternates := 2
+	language := negotiated
+	alternates := defaultAlternates
 
 	if r.Language != nil {
-		language = *r.Language
+		if matched, ok := matchLanguage(*r.Language); ok {
+			language = matched
+		}
+	}
+
+	units := defaultUnits(language)
+	if r.Units != nil {
+		units = *r.Units
 	}
 
 	if r.Alternates != nil {
@@ -88,65 +320,206 @@ func (r RouteRequest) ToValhallaRequest() valhalla.RouteRequest {
 		Costing:          r.Costing,
 		CostingOptions:   r.CostingOptions,
 		Language:         language,
+		Units:            units,
 		Alternates:       alternates,
 	}
 }
 
+// cacheStatusName identifies the cache of this service in Cache-Status headers.
+const cacheStatusName = "supmap-gis"
+
 // @Summary Calcul d'itinéraires.
-// @Description Calcule un ou plusieurs itinéraires à partir de plusieurs localisations.
+// @Description Calcule un ou plusieurs itinéraires à partir de plusieurs localisations. Les itinéraires sont mis en cache avec des coordonnées arrondies (4 décimales par défaut, soit ~11 m) : un itinéraire servi depuis le cache (en-tête 'Cache-Status: supmap-gis; hit') peut avoir été calculé pour des localisations légèrement différentes, dont il reprend le tracé et les localisations renvoyées.
 // @Tags routing
 // @Accept json
 // @Produce json
-// @Param routeRequest body RouteRequest true "Liste de localisation accompagnés d'options permettant de paramétrer le calcul d'itinéraire. Optionnels: 'language', 'costing_options', 'alternates', 'exclude_locations'."
+// @Param routeRequest body RouteRequest true "Liste de localisation accompagnés d'options permettant de paramétrer le calcul d'itinéraire. Optionnels: 'language', 'units', 'costing_options', 'alternates', 'exclude_locations', 'elevation', 'vehicle_profile'."
+// @Param Accept-Language header string false "Langue des instructions si 'language' n'est pas fourni (ex: 'en-GB'). Défaut: fr-FR"
 // @Success 200 {object} handler.Response[[]services.Trip]
 // @Failure 400 {object} ErrResponse "Corps de la requête invalide"
+// @Failure 413 {object} ErrResponse "Corps de la requête trop volumineux"
+// @Failure 422 {object} ErrResponse "Requête trop complexe : trop de localisations, d'exclusions ou d'alternatives, ou localisations trop éloignées"
 // @Failure 500 {object} ErrResponse "Erreur interne du serveur"
+// @Failure 501 {object} ErrResponse "Profil d'élévation demandé mais aucun fournisseur d'élévation n'est configuré"
 // @Router /route [post]
 func (s *Server) routeHandler() http.HandlerFunc {
-	return handler.Handler(func(w http.ResponseWriter, r *http.Request) error {
+	return handle(func(w http.ResponseWriter, r *http.Request) error {
 		req, err := handler.Decode[RouteRequest](r)
 		if err != nil {
-			return handler.NewErrWithStatus(http.StatusBadRequest, err)
+			return bodyError(err)
+		}
+		limits := s.routeLimits()
+		if err := req.CheckLimits(limits); err != nil {
+			return newStatusError(http.StatusUnprocessableEntity, err)
+		}
+		if req.Alternates == nil {
+			alternates := limits.DefaultAlternates()
+			req.Alternates = &alternates
 		}
 
-		valhallaReq := req.ToValhallaRequest()
+		valhallaReq := req.ToValhallaRequest(negotiateValhallaLanguage(r))
 
-		route, err := s.routingService.CalculateRoute(r.Context(), valhallaReq)
+		ctx, cacheStatus := services.WithCacheStatus(r.Context())
+		route, err := s.routingService.CalculateRoute(ctx, valhallaReq, req.RouteOptions())
+		if errors.Is(err, services.ErrUnknownVehicleProfile) {
+			return newStatusError(http.StatusBadRequest, err)
+		}
+		if errors.Is(err, services.ErrElevationUnavailable) {
+			return newStatusError(http.StatusNotImplemented, err)
+		}
 		if err != nil {
-			return handler.NewErrWithStatus(http.StatusInternalServerError, err)
+			return serviceError(w, err)
 		}
 
+		w.Header().Set("Cache-Status", cacheStatus.Header(cacheStatusName))
+
 		resp := handler.Response[[]services.Trip]{
 			Data:    route,
 			Message: "success",
 		}
 
 		if err := handler.Encode[handler.Response[[]services.Trip]](resp, http.StatusOK, w); err != nil {
-			return handler.NewErrWithStatus(http.StatusInternalServerError, err)
+			return newStatusError(http.StatusInternalServerError, err)
 		}
 
 		return nil
 	})
 }
 
+type ElevationRequest struct {
+	Shape           []services.Point `json:"shape,omitempty"`
+	EncodedPolyline string           `json:"encoded_polyline,omitempty"`
+	// Precision of the encoded polyline, from 1 to 10, 6 by default like Valhalla's shapes.
+	Precision int `json:"precision,omitempty"`
+}
+
+func (r ElevationRequest) Validate() error {
+	if len(r.Shape) == 0 && r.EncodedPolyline == "" {
+		return errors.New("either 'shape' or 'encoded_polyline' must be provided")
+	}
+	if len(r.Shape) > 0 && r.EncodedPolyline != "" {
+		return errors.New("'shape' and 'encoded_polyline' are mutually exclusive")
+	}
+	if r.Precision < 0 || r.Precision > services.MaxPolylinePrecision {
+		return fmt.Errorf("'precision' must be between 1 and %d", services.MaxPolylinePrecision)
+	}
+	return nil
+}
+
+// Points returns the shape of the request, decoding the polyline if necessary.
+func (r ElevationRequest) Points() ([]services.Point, error) {
+	if r.EncodedPolyline == "" {
+		return r.Shape, nil
+	}
+	return services.DecodePolyline(r.EncodedPolyline, r.Precision)
+}
+
+// @Summary Profil d'élévation
+// @Description Calcule le profil d'élévation (dénivelés, pente maximale, altitudes échantillonnées) d'une polyline.
+// @Tags elevation
+// @Accept json
+// @Produce json
+// @Param elevationRequest body ElevationRequest true "Tracé sous forme de liste de points ('shape') ou de polyline encodée ('encoded_polyline', précision 'precision' de 1 à 10, 6 par défaut)."
+// @Success 200 {object} handler.Response[services.ElevationProfile]
+// @Failure 400 {object} ErrResponse "Corps de la requête invalide"
+// @Failure 500 {object} ErrResponse "Erreur interne du serveur"
+// @Failure 501 {object} ErrResponse "Aucun fournisseur d'élévation n'est configuré"
+// @Router /elevation [post]
+func (s *Server) elevationHandler() http.HandlerFunc {
+	return handle(func(w http.ResponseWriter, r *http.Request) error {
+		if s.elevationService == nil {
+			return newStatusError(http.StatusNotImplemented, services.ErrElevationUnavailable)
+		}
+
+		req, err := handler.Decode[ElevationRequest](r)
+		if err != nil {
+			return newStatusError(http.StatusBadRequest, err)
+		}
+
+		points, err := req.Points()
+		if err != nil {
+			return newStatusError(http.StatusBadRequest, err)
+		}
+
+		profile, err := s.elevationService.Profile(r.Context(), points)
+		if err != nil {
+			return serviceError(w, err)
+		}
+
+		resp := handler.Response[services.ElevationProfile]{
+			Data:    profile,
+			Message: "success",
+		}
+
+		if err := handler.Encode[handler.Response[services.ElevationProfile]](resp, http.StatusOK, w); err != nil {
+			return newStatusError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+// AddressResponse is the body of a successful /address response.
 type AddressResponse struct {
-	DisplayName string `json:"display_name,omitempty"`
+	services.Address
+}
+
+// AddressFeatureCollection is the body of a successful /address response in GeoJSON format.
+type AddressFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []AddressFeature `json:"features"`
+}
+
+type AddressFeature struct {
+	Type       string           `json:"type"`
+	Properties services.Address `json:"properties"`
+	BBox       []float64        `json:"bbox,omitempty"`
+	Geometry   struct {
+		Type        string     `json:"type"`
+		Coordinates [2]float64 `json:"coordinates"`
+	} `json:"geometry"`
+}
+
+func newAddressFeatureCollection(address services.Address) AddressFeatureCollection {
+	feature := AddressFeature{
+		Type:       "Feature",
+		Properties: address,
+		BBox:       address.BoundingBox,
+	}
+	feature.Geometry.Type = "Point"
+	feature.Geometry.Coordinates = [2]float64{address.Lon, address.Lat}
+
+	return AddressFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: []AddressFeature{feature},
+	}
+}
+
+// reverseZoomLevels maps the levels of detail accepted by /address to Nominatim zoom levels.
+var reverseZoomLevels = map[string]int{
+	"building": 18,
+	"street":   17,
+	"city":     10,
 }
 
 // @Summary Adresse à partir de coordonnées
-// @Description Retourne l'adresse correspondante aux coordonnées géographiques fournies.
+// @Description Retourne l'adresse correspondante aux coordonnées géographiques fournies, avec ses composantes (numéro, rue, code postal, ville...).
 // @Tags geocoding
 // @Accept json
 // @Produce json
 // @Param lat query number true "Latitude (ex: 49.0677)"
 // @Param lon query number true "Longitude (ex: -0.6658)"
-// @Success 200 {object} AddressResponse "Adresse trouvée à partir des coordonnées"
+// @Param zoom query string false "Niveau de détail: building (défaut), street ou city"
+// @Param format query string false "Format de la réponse: json (défaut) ou geojson"
+// @Param Accept-Language header string false "Langue de l'adresse (ex: 'nl-BE'). Défaut: fr-FR"
+// @Success 200 {object} AddressResponse "Adresse trouvée à partir des coordonnées ('format=json')"
+// @Success 200 {object} AddressFeatureCollection "Adresse trouvée à partir des coordonnées ('format=geojson')"
 // @Failure 400 {object} ErrResponse "Paramètre de requête manquant ou invalide"
 // @Failure 404 {object} ErrResponse "Aucune adresse trouvée pour les coordonnées spécifiées"
 // @Failure 500 {object} ErrResponse "Erreur interne du serveur"
 // @Router /address [get]
 func (s *Server) addressHandler() http.HandlerFunc {
-	return handler.Handler(func(w http.ResponseWriter, r *http.Request) error {
+	return handle(func(w http.ResponseWriter, r *http.Request) error {
 		query := r.URL.Query()
 		if !query.Has("lat") {
 			w.WriteHeader(http.StatusBadRequest)
@@ -161,22 +534,42 @@ func (s *Server) addressHandler() http.HandlerFunc {
 		lat, _ := strconv.ParseFloat(r.URL.Query().Get("lat"), 64)
 		lon, _ := strconv.ParseFloat(r.URL.Query().Get("lon"), 64)
 
-		resp, err := s.geocodingService.Reverse(r.Context(), lat, lon)
+		params := nominatim.ReverseParams{
+			Lat:      lat,
+			Lon:      lon,
+			Language: negotiateLanguage(r),
+		}
+		if query.Has("zoom") {
+			zoom, ok := reverseZoomLevels[query.Get("zoom")]
+			if !ok {
+				return newStatusError(http.StatusBadRequest, fmt.Errorf("'zoom' must be one of 'building', 'street' or 'city'"))
+			}
+			params.Zoom = zoom
+		}
+
+		format := query.Get("format")
+		if format != "" && format != "json" && format != "geojson" {
+			return newStatusError(http.StatusBadRequest, fmt.Errorf("'format' must be 'json' or 'geojson'"))
+		}
+
+		address, err := s.geocodingService.Reverse(r.Context(), params)
 		if err != nil {
-			return handler.NewErrWithStatus(http.StatusInternalServerError, err)
+			return serviceError(w, err)
 		}
 
-		// Récupérer le premier élément de feature et retourner son displayname dans la struct
-		if resp == nil || len(resp.Features) == 0 || resp.Features[0].Properties.DisplayName == "" {
-			return handler.NewErrWithStatus(http.StatusNotFound, fmt.Errorf("failed to retrieve data"))
+		if address == nil {
+			return newStatusError(http.StatusNotFound, fmt.Errorf("failed to retrieve data"))
 		}
 
-		address := AddressResponse{
-			DisplayName: resp.Features[0].Properties.DisplayName,
+		if format == "geojson" {
+			if err := handler.Encode(newAddressFeatureCollection(*address), http.StatusOK, w); err != nil {
+				return newStatusError(http.StatusInternalServerError, err)
+			}
+			return nil
 		}
 
-		if err := handler.Encode(address, http.StatusOK, w); err != nil {
-			return handler.NewErrWithStatus(http.StatusInternalServerError, err)
+		if err := handler.Encode(AddressResponse{Address: *address}, http.StatusOK, w); err != nil {
+			return newStatusError(http.StatusInternalServerError, err)
 		}
 
 		return nil
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"supmap-gis/internal/config"
+	"supmap-gis/internal/metrics"
+	supmapIncidents "supmap-gis/internal/providers/supmap-incidents"
+	"supmap-gis/internal/providers/valhalla"
+	"supmap-gis/internal/services"
+	"sync"
+	"testing"
+)
+
+func TestCheckLimits(t *testing.T) {
+	limits := RouteLimits{MaxLocations: 3, MaxExcludeLocations: 2, MaxAlternates: 1, MaxLegDistance: 300000}
+	caen := valhalla.LocationRequest{Lat: 49.18, Lon: -0.37}
+	paris := valhalla.LocationRequest{Lat: 48.86, Lon: 2.35}
+	lyon := valhalla.LocationRequest{Lat: 45.76, Lon: 4.84}
+	alternates := func(n int) *int { return &n }
+
+	tests := []struct {
+		name    string
+		req     RouteRequest
+		limits  RouteLimits
+		wantErr string
+	}{
+		{"within limits", RouteRequest{Locations: []valhalla.LocationRequest{caen, paris, caen}, Alternates: alternates(1)}, limits, ""},
+		{"too many locations", RouteRequest{Locations: []valhalla.LocationRequest{caen, paris, caen, paris}}, limits, "at most 3 locations"},
+		{"too many exclusions", RouteRequest{
+			Locations:        []valhalla.LocationRequest{caen, paris},
+			ExcludeLocations: make([]valhalla.ExcludeLocations, 3),
+		}, limits, "at most 2 exclude_locations"},
+		{"too many alternates", RouteRequest{Locations: []valhalla.LocationRequest{caen, paris}, Alternates: alternates(2)}, limits, "at most 1 alternates"},
+		// Paris and Lyon are about 390 km apart.
+		{"leg too long", RouteRequest{Locations: []valhalla.LocationRequest{caen, paris, lyon}}, limits, "locations 1 and 2 are 39"},
+		{"no limits", RouteRequest{
+			Locations:        []valhalla.LocationRequest{caen, paris, lyon, caen},
+			ExcludeLocations: make([]valhalla.ExcludeLocations, 3),
+			Alternates:       alternates(5),
+		}, RouteLimits{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.CheckLimits(tt.limits)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("CheckLimits() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CheckLimits() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefaultAlternates(t *testing.T) {
+	for _, tt := range []struct{ maxAlternates, want int }{{0, 2}, {1, 1}, {2, 2}, {3, 2}} {
+		if got := (RouteLimits{MaxAlternates: tt.maxAlternates}).DefaultAlternates(); got != tt.want {
+			t.Errorf("DefaultAlternates() with a maximum of %d = %d, want %d", tt.maxAlternates, got, tt.want)
+		}
+	}
+}
+
+// fakeRoutingClient records the route requests sent to Valhalla.
+type fakeRoutingClient struct {
+	mu       sync.Mutex
+	requests []valhalla.RouteRequest
+}
+
+func (c *fakeRoutingClient) CalculateRoute(ctx context.Context, req valhalla.RouteRequest) (*valhalla.RouteResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, req)
+	return &valhalla.RouteResponse{}, nil
+}
+
+type noIncidentsClient struct{}
+
+func (noIncidentsClient) IncidentsInRadius(ctx context.Context, lat, lon float64, radius supmapIncidents.RadiusMeter) ([]supmapIncidents.Incident, error) {
+	return nil, nil
+}
+
+func TestRouteHandlerLimits(t *testing.T) {
+	client := &fakeRoutingClient{}
+	s := &Server{
+		Config: &config.Config{
+			RouteMaxBodyBytes:  512,
+			RouteMaxLocations:  3,
+			RouteMaxAlternates: 1,
+		},
+		metrics:        metrics.New(),
+		routingService: services.NewRoutingService(client, services.NewIncidentsService(noIncidentsClient{}), nil, nil),
+	}
+	mux := s.routes()
+
+	route := `{"costing": "auto", "locations": [{"lat": 49.18, "lon": -0.37}, {"lat": 48.86, "lon": 2.35}]%s}`
+	tests := []struct {
+		name       string
+		body       string
+		chunked    bool
+		wantStatus int
+	}{
+		{"valid", fmt.Sprintf(route, ""), false, http.StatusOK},
+		{"too many alternates", fmt.Sprintf(route, `, "alternates": 2`), false, http.StatusUnprocessableEntity},
+		{"too many locations", `{"costing": "auto", "locations": [{"lat": 49.18, "lon": -0.37}, {"lat": 49.18, "lon": -0.37}, {"lat": 49.18, "lon": -0.37}, {"lat": 49.18, "lon": -0.37}]}`, false, http.StatusUnprocessableEntity},
+		{"body too large", fmt.Sprintf(route, `, "costing_options": {"auto": {"pad": "`+strings.Repeat("x", 512)+`"}}`), false, http.StatusRequestEntityTooLarge},
+		{"chunked body too large", fmt.Sprintf(route, `, "costing_options": {"auto": {"pad": "`+strings.Repeat("x", 512)+`"}}`), true, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/route", strings.NewReader(tt.body))
+			if tt.chunked {
+				r.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus == http.StatusOK {
+				// The routing client isn't cached.
+				if got := w.Header().Get("Cache-Status"); got != "supmap-gis; fwd=bypass" {
+					t.Errorf("Cache-Status = %q, want a bypass", got)
+				}
+			} else {
+				var resp ErrResponse
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Message == "" {
+					t.Errorf("response = %+v, %v, want an explicit error message", resp, err)
+				}
+			}
+		})
+	}
+
+	// The valid request didn't specify alternates, whose default is lowered to the limit.
+	if len(client.requests) != 1 {
+		t.Fatalf("%d requests sent to Valhalla, want 1", len(client.requests))
+	}
+	if got := client.requests[0].Alternates; got != 1 {
+		t.Errorf("alternates = %d, want 1", got)
+	}
+}
+
+func TestElevationRequestPrecision(t *testing.T) {
+	tests := []struct {
+		precision int
+		wantErr   bool
+	}{
+		{0, false},
+		{1, false},
+		{10, false},
+		{11, true},
+		{400, true},
+		{-1, true},
+	}
+	for _, tt := range tests {
+		req := ElevationRequest{EncodedPolyline: "_p~iF~ps|U_ulLnnqC", Precision: tt.precision}
+		if err := req.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate() with precision %d error = %v, want error %v", tt.precision, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"github.com/matheodrd/httphelper/handler"
+	"net/http"
+	"supmap-gis/internal/services"
+)
+
+type LivenessResponse struct {
+	Status string `json:"status"`
+}
+
+// @Summary Vérifie que le serveur est démarré
+// @Description Sonde de liveness : répond 200 tant que le processus est en vie, sans interroger les dépendances.
+// @Tags health
+// @Produce json
+// @Success 200 {object} LivenessResponse
+// @Router /health/live [get]
+func (s *Server) liveHandler() http.HandlerFunc {
+	return handle(func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Cache-Control", "no-store")
+		if err := handler.Encode[LivenessResponse](LivenessResponse{Status: "up"}, http.StatusOK, w); err != nil {
+			return newStatusError(http.StatusInternalServerError, err)
+		}
+		return nil
+	})
+}
+
+// @Summary Vérifie que le serveur peut traiter des requêtes
+// @Description Sonde de readiness : interroge Valhalla, Nominatim et supmap-incidents en parallèle (résultat mis en cache quelques secondes) et renvoie l'état de chaque dépendance. Répond 503 si une dépendance critique est indisponible ; supmap-incidents n'est pas critique et ne fait que dégrader le service.
+// @Tags health
+// @Produce json
+// @Success 200 {object} services.HealthReport "Toutes les dépendances critiques sont disponibles"
+// @Failure 503 {object} services.HealthReport "Une dépendance critique est indisponible"
+// @Router /health/ready [get]
+func (s *Server) readyHandler() http.HandlerFunc {
+	return handle(func(w http.ResponseWriter, r *http.Request) error {
+		report := s.healthService.Readiness(r.Context())
+
+		status := http.StatusOK
+		if !report.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		if err := handler.Encode[services.HealthReport](report, status, w); err != nil {
+			return newStatusError(http.StatusInternalServerError, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"supmap-gis/internal/providers/valhalla"
+)
+
+// defaultLanguage is used when the client does not provide any supported language.
+const defaultLanguage valhalla.Language = "fr-FR"
+
+// negotiateLanguage returns the preferred language tag of the Accept-Language header of the
+// request, as is, or [defaultLanguage] if there is none. It's used for geocoding, whose providers
+// accept any language, so that e.g. "fr-BE" gets Belgian names and addresses.
+func negotiateLanguage(r *http.Request) string {
+	for _, tag := range parseAcceptLanguage(r.Header.Get("Accept-Language")) {
+		if validLanguageTag(tag) {
+			return tag
+		}
+	}
+	return string(defaultLanguage)
+}
+
+// negotiateValhallaLanguage returns the supported [valhalla.Language] that best matches the
+// Accept-Language header of the request, or [defaultLanguage] if none matches. It's only used
+// for the instructions of routes.
+func negotiateValhallaLanguage(r *http.Request) valhalla.Language {
+	for _, tag := range parseAcceptLanguage(r.Header.Get("Accept-Language")) {
+		if language, ok := matchLanguage(tag); ok {
+			return language
+		}
+	}
+	return defaultLanguage
+}
+
+// validLanguageTag reports whether tag has the syntax of a BCP 47 language tag: subtags of 1 to 8
+// letters or digits, separated by hyphens, the first being made of letters.
+func validLanguageTag(tag string) bool {
+	for i, subtag := range strings.Split(tag, "-") {
+		if len(subtag) == 0 || len(subtag) > 8 {
+			return false
+		}
+		for _, c := range subtag {
+			isLetter := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+			if !isLetter && (i == 0 || c < '0' || c > '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// parseAcceptLanguage returns the language tags of an Accept-Language header value,
+// ordered by decreasing quality. Tags with a quality of 0 are ignored.
+func parseAcceptLanguage(header string) []string {
+	type weightedTag struct {
+		tag     string
+		quality float64
+	}
+
+	var weighted []weightedTag
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		weighted = append(weighted, weightedTag{tag: tag, quality: quality})
+	}
+
+	sort.SliceStable(weighted, func(i, j int) bool {
+		return weighted[i].quality > weighted[j].quality
+	})
+
+	tags := make([]string, len(weighted))
+	for i, w := range weighted {
+		tags[i] = w.tag
+	}
+	return tags
+}
+
+// matchLanguage finds the supported language matching tag. An exact (case-insensitive) match
+// is preferred, otherwise the first supported language sharing the same primary subtag is
+// returned, so that "fr-BE" falls back to "fr-FR" and "nl-BE" to "nl-NL".
+func matchLanguage(tag string) (valhalla.Language, bool) {
+	for _, supported := range valhalla.SupportedLanguages {
+		if strings.EqualFold(string(supported), tag) {
+			return supported, true
+		}
+	}
+
+	primary, _, _ := strings.Cut(tag, "-")
+	for _, supported := range valhalla.SupportedLanguages {
+		supportedPrimary, _, _ := strings.Cut(string(supported), "-")
+		if strings.EqualFold(supportedPrimary, primary) {
+			return supported, true
+		}
+	}
+
+	return "", false
+}
+
+// defaultUnits returns the distance units commonly used by speakers of language.
+func defaultUnits(language valhalla.Language) valhalla.Units {
+	switch language {
+	case "en-GB", "en-US", "en-US-x-pirate":
+		return valhalla.UnitsMiles
+	default:
+		return valhalla.UnitsKilometers
+	}
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http/httptest"
+	"supmap-gis/internal/providers/valhalla"
+	"testing"
+)
+
+func TestNegotiateLanguage(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		want           string
+		wantValhalla   valhalla.Language
+	}{
+		{"", "fr-FR", "fr-FR"},
+		// Geocoding keeps the regional variant, routing falls back to the closest supported language.
+		{"fr-BE", "fr-BE", "fr-FR"},
+		{"nl-BE, fr;q=0.8", "nl-BE", "nl-NL"},
+		{"en;q=0.5, de-CH", "de-CH", "de-DE"},
+		// Valhalla doesn't support Breton, so routes use the next preferred language.
+		{"br-FR, en-GB;q=0.9", "br-FR", "en-GB"},
+		{"*, fr;q=0", "fr-FR", "fr-FR"},
+		{"fr_BE<script>, it", "it", "it-IT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.acceptLanguage, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/geocode", nil)
+			r.Header.Set("Accept-Language", tt.acceptLanguage)
+			if got := negotiateLanguage(r); got != tt.want {
+				t.Errorf("negotiateLanguage() = %q, want %q", got, tt.want)
+			}
+			if got := negotiateValhallaLanguage(r); got != tt.wantValhalla {
+				t.Errorf("negotiateValhallaLanguage() = %q, want %q", got, tt.wantValhalla)
+			}
+		})
+	}
+}
+
+func TestRouteRequestLanguage(t *testing.T) {
+	language := func(s string) *string { return &s }
+	tests := []struct {
+		name     string
+		language *string
+		want     valhalla.Language
+	}{
+		{"negotiated", nil, "en-GB"},
+		{"supported", language("de-DE"), "de-DE"},
+		{"regional variant", language("fr-CA"), "fr-FR"},
+		{"unsupported", language("xx"), "en-GB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := RouteRequest{
+				Locations: []valhalla.LocationRequest{{Lat: 49.18, Lon: -0.37}, {Lat: 48.85, Lon: 2.35}},
+				Costing:   valhalla.CostingAuto,
+				Language:  tt.language,
+			}
+			if err := req.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if got := req.ToValhallaRequest("en-GB").Language; got != tt.want {
+				t.Errorf("language = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
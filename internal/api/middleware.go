@@ -1,18 +1,197 @@
 package api
 
-import "net/http"
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"supmap-gis/internal/auth"
+	"supmap-gis/internal/logging"
+	"time"
+)
 
-func WithCORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+// WithRequestLogging assigns an ID to each request, or keeps the one sent by the client in the
+// X-Request-ID header, returns it in the same header, and logs the request once handled.
+// The request context carries the ID and a logger including it.
+func WithRequestLogging(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, incoming *http.Request) {
+		start := time.Now()
+		r := incoming
 
-		if r.Method == http.MethodOptions { // Ignore preflight requests because OPTIONS handler is not implemented
-			w.WriteHeader(http.StatusOK)
-			return
+		requestID := r.Header.Get(logging.RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
 		}
+		w.Header().Set(logging.RequestIDHeader, requestID)
+
+		requestLogger := logger.With("request_id", requestID)
+		ctx := logging.WithRequestID(r.Context(), requestID)
+		r = r.WithContext(logging.WithLogger(ctx, requestLogger))
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		// The ServeMux sets the pattern on the request it routes, which is a copy of the incoming one,
+		// while the outer middlewares read it from the incoming request.
+		incoming.Pattern = r.Pattern
+
+		requestLogger.Info("Request handled",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"route", r.Pattern,
+			"status", recorder.status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"bytes", recorder.bytes,
+			"client_ip", clientIP(r),
+		)
+	})
+}
+
+// WithAuthentication rejects the requests that authenticator can't authenticate with a 401 error,
+// and adds the authenticated client to the context of the others.
+func WithAuthentication(authenticator auth.Authenticator, next http.Handler) http.Handler {
+	return handle(func(w http.ResponseWriter, r *http.Request) error {
+		principal, err := authenticator.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="supmap-gis"`)
+			if errors.Is(err, auth.ErrNoCredentials) {
+				return newStatusError(http.StatusUnauthorized, errors.New("missing credentials"))
+			}
+			return newStatusError(http.StatusUnauthorized, err)
+		}
+
+		logging.FromContext(r.Context()).Debug("Request authenticated", "subject", principal.Subject, "method", principal.Method)
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		return nil
+	})
+}
 
+// WithMaxBodyBytes rejects with a 413 error the requests whose body exceeds limit bytes. The body of
+// the requests without a Content-Length is limited with [http.MaxBytesReader], whose error must be
+// converted by the handlers with bodyTooLarge.
+func WithMaxBodyBytes(limit int64, next http.Handler) http.Handler {
+	return handle(func(w http.ResponseWriter, r *http.Request) error {
+		if r.ContentLength > limit {
+			return bodyTooLarge(limit)
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
 		next.ServeHTTP(w, r)
+		return nil
+	})
+}
+
+// validRequestID reports whether an ID received from a client can be kept, which requires it
+// to be short and made of safe characters, since it's logged and sent to the upstreams.
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > 128 {
+		return false
+	}
+	for _, c := range requestID {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.:", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// clientIP returns the IP address of the client of r, resolved by [WithClientIP].
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+// remoteIP returns the IP address of the peer of r.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type clientIPKey struct{}
+
+// WithClientIP adds the IP address of the client to the context of the requests, read by clientIP.
+// It's the address of the peer or, if the peer is one of trustedProxies, the last address of the
+// X-Forwarded-For header that isn't a trusted proxy, so that clients can't spoof it.
+func WithClientIP(trustedProxies []netip.Prefix, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := forwardedClientIP(r, trustedProxies)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
 	})
 }
+
+// forwardedClientIP walks X-Forwarded-For from the peer, the last proxy, back to the client while
+// the addresses are trusted proxies.
+func forwardedClientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	peer := remoteIP(r)
+	addr, err := netip.ParseAddr(peer)
+	if err != nil || !trusted(addr, trustedProxies) {
+		return peer
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// The hops before an invalid one can't be trusted.
+			break
+		}
+		addr = hop.Unmap()
+		if !trusted(addr, trustedProxies) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+func trusted(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// responseRecorder records the status and the size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = status, true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
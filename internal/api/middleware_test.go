@@ -0,0 +1,175 @@
+package api
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"supmap-gis/internal/logging"
+	"supmap-gis/internal/tracing"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRequestLoggingKeepsRouteForTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /places/{osm_type}/{osm_id}", func(w http.ResponseWriter, r *http.Request) {
+		if logging.RequestID(r.Context()) == "" {
+			t.Error("request ID missing from the context of the handler")
+		}
+	})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	h := tracing.Middleware(WithRequestLogging(logger, mux))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/places/node/42", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	const route = "/places/{osm_type}/{osm_id}"
+	if got, want := spans[0].Name(), "GET "+route; got != want {
+		t.Errorf("span name = %q, want %q", got, want)
+	}
+	var gotRoute string
+	for _, attr := range spans[0].Attributes() {
+		if attr.Key == attribute.Key("http.route") {
+			gotRoute = attr.Value.AsString()
+		}
+	}
+	if gotRoute != route {
+		t.Errorf("http.route = %q, want %q", gotRoute, route)
+	}
+}
+
+func TestRequestLoggingRequestID(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	h := WithRequestLogging(logger, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"valid ID kept", "abc-123_x.y:z", true},
+		{"missing ID generated", "", false},
+		{"unsafe ID replaced", "abc\nforged=1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/geocode", nil)
+			if tt.incoming != "" {
+				r.Header.Set(logging.RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			got := w.Header().Get(logging.RequestIDHeader)
+			if tt.keep && got != tt.incoming {
+				t.Errorf("request ID = %q, want %q", got, tt.incoming)
+			}
+			if !tt.keep && (got == "" || got == tt.incoming) {
+				t.Errorf("request ID = %q, want a generated one", got)
+			}
+		})
+	}
+}
+
+func TestWithClientIP(t *testing.T) {
+	trustedProxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("2001:db8::/32")}
+
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		forwardedFor   []string
+		trustedProxies []netip.Prefix
+		want           string
+	}{
+		{"direct client", "192.0.2.1:1234", nil, trustedProxies, "192.0.2.1"},
+		{"header of an untrusted peer ignored", "192.0.2.1:1234", []string{"198.51.100.7"}, trustedProxies, "192.0.2.1"},
+		{"header ignored without trusted proxies", "10.0.0.2:1234", []string{"198.51.100.7"}, nil, "10.0.0.2"},
+		{"client behind a proxy", "10.0.0.2:1234", []string{"198.51.100.7"}, trustedProxies, "198.51.100.7"},
+		// The client may send its own X-Forwarded-For, the proxy appends to it.
+		{"spoofed address ignored", "10.0.0.2:1234", []string{"203.0.113.9, 198.51.100.7"}, trustedProxies, "198.51.100.7"},
+		{"client behind several proxies", "10.0.0.2:1234", []string{"198.51.100.7, 10.1.0.3", "10.2.0.4"}, trustedProxies, "198.51.100.7"},
+		{"invalid hop", "10.0.0.2:1234", []string{"198.51.100.7, unknown, 10.1.0.3"}, trustedProxies, "10.1.0.3"},
+		{"only proxies", "10.0.0.2:1234", []string{"10.1.0.3"}, trustedProxies, "10.1.0.3"},
+		{"proxy without header", "10.0.0.2:1234", nil, trustedProxies, "10.0.0.2"},
+		{"IPv6 proxy", "[2001:db8::1]:1234", []string{"2001:db8:ffff::5, 2a01:e0a::1"}, trustedProxies, "2a01:e0a::1"},
+		{"IPv4-mapped proxy", "[::ffff:10.0.0.2]:1234", []string{"::ffff:198.51.100.7"}, trustedProxies, "198.51.100.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := WithClientIP(tt.trustedProxies, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = clientIP(r)
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/geocode", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithMaxBodyBytes(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		chunked    bool
+		wantCalled bool
+		wantStatus int
+	}{
+		{"within the limit", "0123456789", false, true, http.StatusOK},
+		// A Content-Length over the limit is rejected without calling the handler.
+		{"Content-Length over the limit", "0123456789abcdef", false, false, http.StatusRequestEntityTooLarge},
+		// Otherwise, the limit is only hit while the handler reads the body.
+		{"chunked within the limit", "0123456789", true, true, http.StatusOK},
+		{"chunked over the limit", "0123456789abcdef", true, true, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			h := WithMaxBodyBytes(12, handle(func(w http.ResponseWriter, r *http.Request) error {
+				called = true
+				if _, err := io.ReadAll(r.Body); err != nil {
+					return bodyError(err)
+				}
+				return nil
+			}))
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.chunked {
+				r.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if called != tt.wantCalled {
+				t.Errorf("handler called = %v, want %v", called, tt.wantCalled)
+			}
+		})
+	}
+}
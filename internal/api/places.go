@@ -0,0 +1,203 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"supmap-gis/internal/providers/nominatim"
+	"supmap-gis/internal/providers/valhalla"
+	"supmap-gis/internal/services"
+
+	"github.com/matheodrd/httphelper/handler"
+)
+
+const (
+	defaultNearbyRadius         = 2000
+	defaultNearbyCorridorRadius = 500
+	maxNearbyRadius             = 50000
+)
+
+// @Summary Recherche de lieux à proximité
+// @Description Recherche les lieux d'une catégorie (stations-service, parkings, bornes de recharge, hôpitaux) autour d'une position, ou le long d'un itinéraire si 'polyline' est renseigné.
+// @Description Les lieux peuvent être triés par distance à vol d'oiseau, par temps de trajet depuis la position, ou par détour ajouté à l'itinéraire.
+// @Tags places
+// @Produce json
+// @Param lat query number true "Latitude de la position (ex: 49.0677)"
+// @Param lon query number true "Longitude de la position (ex: -0.6658)"
+// @Param category query string true "Catégorie de lieux" Enums(fuel, parking, charging_station, hospital)
+// @Param radius query number false "Rayon de recherche en mètres autour de la position (défaut 2000), ou distance maximale à l'itinéraire (défaut 500). Maximum 50000."
+// @Param polyline query string false "Tracé encodé de l'itinéraire restant, de la position à la destination"
+// @Param precision query int false "Précision du tracé encodé (1 à 10, défaut 6)"
+// @Param sort query string false "Tri des résultats (défaut distance). 'detour' nécessite un itinéraire." Enums(distance, route, detour)
+// @Param costing query string false "Mode de transport utilisé pour les temps de trajet (défaut auto)"
+// @Param limit query int false "Nombre maximal de résultats (1 à 40, défaut 10)"
+// @Param Accept-Language header string false "Langue des résultats (ex: 'fr-BE, en;q=0.8'). Défaut: fr-FR"
+// @Success 200 {object} handler.Response[[]services.NearbyPlace]
+// @Failure 400 {object} ErrResponse "Paramètre de requête manquant ou invalide"
+// @Failure 500 {object} ErrResponse "Erreur interne du serveur"
+// @Router /places/nearby [get]
+func (s *Server) nearbyPlacesHandler() http.HandlerFunc {
+	return handle(func(w http.ResponseWriter, r *http.Request) error {
+		params, err := nearbyParamsFromQuery(r.URL.Query())
+		if err != nil {
+			return newStatusError(http.StatusBadRequest, err)
+		}
+		params.Language = negotiateLanguage(r)
+
+		result, err := s.placesService.Nearby(r.Context(), params)
+		if err != nil {
+			return serviceError(w, fmt.Errorf("nearby places: %w", err))
+		}
+
+		resp := handler.Response[[]services.NearbyPlace]{
+			Data:    &result,
+			Message: "success",
+		}
+
+		if err := handler.Encode[handler.Response[[]services.NearbyPlace]](resp, http.StatusOK, w); err != nil {
+			return newStatusError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+// nearbyParamsFromQuery reads the parameters of a nearby search from the query string.
+func nearbyParamsFromQuery(query url.Values) (services.NearbyParams, error) {
+	params := services.NearbyParams{
+		Category: services.PlaceCategory(query.Get("category")),
+		Sort:     services.NearbySortDistance,
+		Costing:  valhalla.CostingAuto,
+		Limit:    10,
+	}
+
+	lat, errLat := strconv.ParseFloat(query.Get("lat"), 64)
+	lon, errLon := strconv.ParseFloat(query.Get("lon"), 64)
+	if errLat != nil || errLon != nil {
+		return params, errors.New("'lat' and 'lon' must both be valid numbers")
+	}
+	params.Position = services.Point{Lat: lat, Lon: lon}
+
+	if !params.Category.IsValid() {
+		return params, fmt.Errorf("invalid category %q", params.Category)
+	}
+
+	if query.Has("polyline") {
+		precision := 6
+		if query.Has("precision") {
+			p, err := strconv.Atoi(query.Get("precision"))
+			if err != nil || p < 1 || p > services.MaxPolylinePrecision {
+				return params, fmt.Errorf("'precision' must be an integer between 1 and %d", services.MaxPolylinePrecision)
+			}
+			precision = p
+		}
+		corridor, err := services.DecodePolyline(query.Get("polyline"), precision)
+		if err != nil {
+			return params, fmt.Errorf("invalid polyline: %w", err)
+		}
+		if len(corridor) < 2 {
+			return params, errors.New("'polyline' must contain at least 2 points")
+		}
+		params.Corridor = corridor
+	}
+
+	params.Radius = defaultNearbyRadius
+	if params.Corridor != nil {
+		params.Radius = defaultNearbyCorridorRadius
+	}
+	if query.Has("radius") {
+		radius, err := strconv.ParseFloat(query.Get("radius"), 64)
+		if err != nil || radius <= 0 || radius > maxNearbyRadius {
+			return params, fmt.Errorf("'radius' must be a number between 0 and %d", maxNearbyRadius)
+		}
+		params.Radius = radius
+	}
+
+	if query.Has("sort") {
+		params.Sort = services.NearbySort(query.Get("sort"))
+		if !params.Sort.IsValid() {
+			return params, fmt.Errorf("invalid sort %q", params.Sort)
+		}
+	}
+	if params.Sort == services.NearbySortDetour && params.Corridor == nil {
+		return params, services.ErrDetourWithoutCorridor
+	}
+
+	if query.Has("costing") {
+		params.Costing = valhalla.Costing(query.Get("costing"))
+		if !params.Costing.IsValid() {
+			return params, fmt.Errorf("invalid costing %q", params.Costing)
+		}
+	}
+
+	if query.Has("limit") {
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 1 || limit > 40 {
+			return params, errors.New("'limit' must be an integer between 1 and 40")
+		}
+		params.Limit = limit
+	}
+
+	return params, nil
+}
+
+// osmTypes maps the accepted OSM types to the letter expected by Nominatim.
+var osmTypes = map[string]string{
+	"node":     "N",
+	"way":      "W",
+	"relation": "R",
+	"n":        "N",
+	"w":        "W",
+	"r":        "R",
+}
+
+// @Summary Détails d'un lieu
+// @Description Récupère l'état actuel d'un objet OSM (nom, adresse, géométrie, tags additionnels comme les horaires d'ouverture ou le site web) à partir de son type et de son identifiant, tels que renvoyés par /geocode.
+// @Tags places
+// @Produce json
+// @Param osm_type path string true "Type de l'objet OSM" Enums(node, way, relation, N, W, R)
+// @Param osm_id path int true "Identifiant de l'objet OSM (ex: 123456)"
+// @Param Accept-Language header string false "Langue des résultats (ex: 'fr-BE, en;q=0.8'). Défaut: fr-FR"
+// @Success 200 {object} handler.Response[services.PlaceDetails]
+// @Failure 400 {object} ErrResponse "Paramètre invalide"
+// @Failure 404 {object} ErrResponse "Lieu introuvable"
+// @Failure 500 {object} ErrResponse "Erreur interne du serveur"
+// @Router /places/{osm_type}/{osm_id} [get]
+func (s *Server) placeDetailsHandler() http.HandlerFunc {
+	return handle(func(w http.ResponseWriter, r *http.Request) error {
+		osmType, ok := osmTypes[strings.ToLower(r.PathValue("osm_type"))]
+		if !ok {
+			return newStatusError(http.StatusBadRequest, fmt.Errorf("invalid OSM type %q", r.PathValue("osm_type")))
+		}
+		osmID, err := strconv.ParseInt(r.PathValue("osm_id"), 10, 64)
+		if err != nil || osmID <= 0 {
+			return newStatusError(http.StatusBadRequest, errors.New("'osm_id' must be a positive integer"))
+		}
+
+		result, err := s.placesService.Details(r.Context(), nominatim.LookupParams{
+			OSMType:  osmType,
+			OSMID:    osmID,
+			Language: negotiateLanguage(r),
+		})
+		if err != nil {
+			return serviceError(w, fmt.Errorf("place details: %w", err))
+		}
+		if result == nil {
+			return newStatusError(http.StatusNotFound, fmt.Errorf("place not found"))
+		}
+
+		resp := handler.Response[services.PlaceDetails]{
+			Data:    result,
+			Message: "success",
+		}
+
+		if err := handler.Encode[handler.Response[services.PlaceDetails]](resp, http.StatusOK, w); err != nil {
+			return newStatusError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNearbyParamsPrecision(t *testing.T) {
+	tests := []struct {
+		precision string
+		wantErr   string
+	}{
+		{"", ""},
+		{"5", ""},
+		{"10", ""},
+		{"0", "'precision' must be an integer between 1 and 10"},
+		{"11", "'precision' must be an integer between 1 and 10"},
+		{"400", "'precision' must be an integer between 1 and 10"},
+		{"five", "'precision' must be an integer between 1 and 10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.precision, func(t *testing.T) {
+			query := url.Values{"lat": {"49.18"}, "lon": {"-0.37"}, "category": {"fuel"}, "polyline": {"_p~iF~ps|U_ulLnnqC"}}
+			if tt.precision != "" {
+				query.Set("precision", tt.precision)
+			}
+			params, err := nearbyParamsFromQuery(query)
+			if tt.wantErr == "" {
+				if err != nil || len(params.Corridor) != 2 {
+					t.Errorf("nearbyParamsFromQuery() = %d points, %v, want 2 points", len(params.Corridor), err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("nearbyParamsFromQuery() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"supmap-gis/internal/auth"
+	"supmap-gis/internal/ratelimit"
+	"time"
+)
+
+// costClass groups the endpoints sharing a rate limit, by how much they cost to the upstreams.
+type costClass string
+
+const (
+	// costDefault is the class of the lookups, each costing a single token.
+	costDefault costClass = "default"
+	// costRoute is the class of /route, whose requests cost a token per leg and per alternate.
+	costRoute costClass = "route"
+	// costBatch is the class of the batch endpoints, whose requests cost a token per batchItemsPerToken items.
+	costBatch costClass = "batch"
+	// costIP is the class of every protected request before its authentication, keyed by IP
+	// address, which bounds the attempts with invalid credentials.
+	costIP costClass = "ip"
+)
+
+// costFunc returns the number of tokens taken by a request.
+type costFunc func(r *http.Request) int
+
+func unitCost(*http.Request) int {
+	return 1
+}
+
+// routeCost returns the number of legs of a route request, multiplied by the number of routes
+// requested, since Valhalla computes each of them. The body is restored for the handler, which
+// reports the reading and decoding errors; an unreadable request costs a single token.
+func routeCost(r *http.Request) int {
+	body, err := io.ReadAll(r.Body)
+	// A failed read is replayed after the data read before it.
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if err != nil {
+		return 1
+	}
+
+	var req struct {
+		Locations  []json.RawMessage `json:"locations"`
+		Alternates *int              `json:"alternates"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return 1
+	}
+
+	routes := 1
+	if req.Alternates != nil && *req.Alternates > 0 {
+		routes += *req.Alternates
+	}
+	return max(len(req.Locations)-1, 1) * routes
+}
+
+// batchItemsPerToken is the number of items of a batch request costing a token.
+const batchItemsPerToken = 100
+
+// batchCost returns a token per batchItemsPerToken items of a batch request, started ones
+// included. Like in routeCost, the body is restored for the handler, and an unreadable request
+// costs a single token.
+func batchCost(r *http.Request) int {
+	body, err := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if err != nil {
+		return 1
+	}
+
+	items := countBatchItems(r, body)
+	return max((items+batchItemsPerToken-1)/batchItemsPerToken, 1)
+}
+
+// countBatchItems returns the number of items of the batch request r, whose body is body,
+// in any of the formats read by the batch handlers, or 0 if it can't be read.
+func countBatchItems(r *http.Request, body []byte) int {
+	probe := r.Clone(r.Context())
+	probe.Body = io.NopCloser(bytes.NewReader(body))
+	input, isCSV, err := batchInput(probe)
+	if probe.MultipartForm != nil {
+		defer probe.MultipartForm.RemoveAll()
+	}
+	if err != nil {
+		return 0
+	}
+
+	if !isCSV {
+		var req struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		if err := json.NewDecoder(input).Decode(&req); err != nil {
+			return 0
+		}
+		return len(req.Items)
+	}
+
+	reader := csv.NewReader(input)
+	reader.FieldsPerRecord = -1
+	rows := 0
+	for {
+		if _, err := reader.Read(); err != nil {
+			break
+		}
+		rows++
+	}
+	// The first row is the header.
+	return max(rows-1, 0)
+}
+
+// WithRateLimit rejects with a 429 error the requests of the clients whose bucket of limiter doesn't
+// hold the cost of the request. Clients are identified by their API key or JWT subject if they're
+// authenticated, or else by their IP address, see [WithClientIP]. The state of the bucket is
+// returned in the RateLimit-Policy, RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers.
+func WithRateLimit(limiter *ratelimit.Limiter, cost costFunc, next http.Handler) http.Handler {
+	return withRateLimit(limiter, rateLimitKey, cost, next)
+}
+
+// WithIPRateLimit is like [WithRateLimit], but identifies clients by their IP address only and
+// charges a token per request. It is applied before authentication, which it doesn't require.
+func WithIPRateLimit(limiter *ratelimit.Limiter, next http.Handler) http.Handler {
+	return withRateLimit(limiter, ipRateLimitKey, unitCost, next)
+}
+
+func withRateLimit(limiter *ratelimit.Limiter, clientKey func(*http.Request) string, cost costFunc, next http.Handler) http.Handler {
+	limit := limiter.Limit()
+	policy := strconv.Itoa(limit.Burst) + ";w=" + strconv.Itoa(ceilSeconds(limit.Window()))
+
+	return handle(func(w http.ResponseWriter, r *http.Request) error {
+		key := clientKey(r)
+		result := limiter.Allow(key, cost(r))
+
+		w.Header().Set("RateLimit-Policy", policy)
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		if !result.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(max(ceilSeconds(result.RetryAfter), 1)))
+			return newStatusError(http.StatusTooManyRequests, fmt.Errorf("rate limit of %s exceeded", key))
+		}
+
+		next.ServeHTTP(w, r)
+		return nil
+	})
+}
+
+// rateLimitKey identifies the client of r.
+func rateLimitKey(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		return string(principal.Method) + ":" + principal.Subject
+	}
+	return ipRateLimitKey(r)
+}
+
+func ipRateLimitKey(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
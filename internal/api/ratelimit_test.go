@@ -0,0 +1,170 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"supmap-gis/internal/auth"
+	"supmap-gis/internal/ratelimit"
+	"testing"
+)
+
+func TestRouteCost(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"two locations", `{"locations":[{},{}]}`, 1},
+		{"five locations", `{"locations":[{},{},{},{},{}]}`, 4},
+		{"with alternates", `{"locations":[{},{},{}],"alternates":2}`, 6},
+		{"negative alternates", `{"locations":[{},{}],"alternates":-1}`, 1},
+		{"single location", `{"locations":[{}]}`, 1},
+		{"invalid body", `{`, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/route", strings.NewReader(tt.body))
+			if got := routeCost(r); got != tt.want {
+				t.Errorf("routeCost() = %d, want %d", got, tt.want)
+			}
+
+			// The handler reads the same body.
+			body, err := io.ReadAll(r.Body)
+			if err != nil || string(body) != tt.body {
+				t.Errorf("body after routeCost() = %q, %v, want %q", body, err, tt.body)
+			}
+		})
+	}
+}
+
+func TestBatchCost(t *testing.T) {
+	jsonItems := func(n int) string {
+		return `{"items":[` + strings.TrimSuffix(strings.Repeat(`{"address":"Caen"},`, n), ",") + `]}`
+	}
+	csvRows := func(n int) string {
+		return "address\n" + strings.Repeat("Caen\n", n)
+	}
+	multipartCSV := func(content string) (string, string) {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		file, _ := writer.CreateFormFile("file", "batch.csv")
+		file.Write([]byte(content))
+		writer.Close()
+		return body.String(), writer.FormDataContentType()
+	}
+	multipartBody, multipartType := multipartCSV(csvRows(450))
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"single item", "application/json", jsonItems(1), 1},
+		{"full chunk", "application/json", jsonItems(100), 1},
+		{"started chunk", "application/json", jsonItems(101), 2},
+		{"no content type", "", jsonItems(250), 3},
+		{"no items", "application/json", `{"items":[]}`, 1},
+		{"csv", "text/csv", csvRows(200), 2},
+		{"csv with the header only", "text/csv", "address\n", 1},
+		{"multipart csv", multipartType, multipartBody, 5},
+		{"invalid json", "application/json", `{"items":[{},`, 1},
+		{"multipart without file", "multipart/form-data; boundary=x", "--x--\r\n", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/geocode/batch", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			if got := batchCost(r); got != tt.want {
+				t.Errorf("batchCost() = %d, want %d", got, tt.want)
+			}
+
+			// The handler reads the same body.
+			body, err := io.ReadAll(r.Body)
+			if err != nil || string(body) != tt.body {
+				t.Errorf("body after batchCost() = %q, %v, want %q", body, err, tt.body)
+			}
+		})
+	}
+}
+
+func TestWithRateLimit(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Limit{Rate: 1, Burst: 2})
+	h := WithRateLimit(limiter, unitCost, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(remoteAddr, apiKeyName string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/geocode", nil)
+		r.RemoteAddr = remoteAddr
+		if apiKeyName != "" {
+			r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{Subject: apiKeyName, Method: auth.MethodAPIKey}))
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := request("192.0.2.1:1234", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	wantHeaders := map[string]string{
+		"RateLimit-Policy":    "2;w=2",
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "1",
+		"RateLimit-Reset":     "1",
+	}
+	for header, want := range wantHeaders {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	// The same IP address from another port shares the bucket.
+	request("192.0.2.1:5678", "")
+	w = request("192.0.2.1:1234", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want %q", got, "1")
+	}
+
+	// Authenticated clients have their own bucket, whatever their address.
+	if w := request("192.0.2.1:1234", "supmap-web"); w.Code != http.StatusOK {
+		t.Errorf("status of an authenticated client = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestWithRateLimitBehindProxy(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Limit{Rate: 1, Burst: 1})
+	trustedProxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	h := WithClientIP(trustedProxies, WithRateLimit(limiter, unitCost, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	request := func(forwardedFor string) int {
+		r := httptest.NewRequest(http.MethodGet, "/geocode", nil)
+		r.RemoteAddr = "10.0.0.2:1234"
+		r.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// The clients behind the proxy have their own bucket.
+	if got := request("192.0.2.1"); got != http.StatusOK {
+		t.Fatalf("status of the first client = %d, want %d", got, http.StatusOK)
+	}
+	if got := request("192.0.2.2"); got != http.StatusOK {
+		t.Errorf("status of the second client = %d, want %d", got, http.StatusOK)
+	}
+	// Prepending an address doesn't give a new bucket.
+	if got := request("198.51.100.1, 192.0.2.1"); got != http.StatusTooManyRequests {
+		t.Errorf("status of a spoofed address = %d, want %d", got, http.StatusTooManyRequests)
+	}
+}
@@ -8,28 +8,66 @@ import (
 	"net"
 	"net/http"
 	_ "supmap-gis/docs"
+	"supmap-gis/internal/auth"
 	"supmap-gis/internal/config"
+	"supmap-gis/internal/metrics"
+	"supmap-gis/internal/ratelimit"
 	"supmap-gis/internal/services"
+	"supmap-gis/internal/tracing"
 	"sync"
 	"time"
 )
 
 type Server struct {
-	Config           *config.Config
-	logger           *slog.Logger
-	geocodingService *services.GeocodingService
-	routingService   *services.RoutingService
+	Config              *config.Config
+	logger              *slog.Logger
+	geocodingService    *services.GeocodingService
+	autocompleteService *services.AutocompleteService
+	batchService        *services.BatchService
+	placesService       *services.PlacesService
+	routingService      *services.RoutingService
+	elevationService    *services.ElevationService // nil when no elevation provider is configured
+	healthService       *services.HealthService
+	metrics             *metrics.Metrics
+	authenticator       auth.Authenticator // nil when authentication is disabled
+	rateLimiters        map[costClass]*ratelimit.Limiter
 }
 
-func NewServer(config *config.Config, logger *slog.Logger, geocodingService *services.GeocodingService, routingService *services.RoutingService) *Server {
+func NewServer(config *config.Config, logger *slog.Logger, geocodingService *services.GeocodingService, autocompleteService *services.AutocompleteService, batchService *services.BatchService, placesService *services.PlacesService, routingService *services.RoutingService, elevationService *services.ElevationService, healthService *services.HealthService, metrics *metrics.Metrics, authenticator auth.Authenticator) *Server {
 	return &Server{
-		Config:           config,
-		logger:           logger,
-		geocodingService: geocodingService,
-		routingService:   routingService,
+		Config:              config,
+		logger:              logger,
+		geocodingService:    geocodingService,
+		autocompleteService: autocompleteService,
+		batchService:        batchService,
+		placesService:       placesService,
+		routingService:      routingService,
+		elevationService:    elevationService,
+		healthService:       healthService,
+		metrics:             metrics,
+		authenticator:       authenticator,
+		rateLimiters:        newRateLimiters(config),
 	}
 }
 
+// newRateLimiters returns the limiters of the cost classes, leaving out those without a rate.
+func newRateLimiters(config *config.Config) map[costClass]*ratelimit.Limiter {
+	limits := map[costClass]ratelimit.Limit{
+		costDefault: {Rate: config.RateLimitDefaultRate, Burst: config.RateLimitDefaultBurst},
+		costRoute:   {Rate: config.RateLimitRouteRate, Burst: config.RateLimitRouteBurst},
+		costBatch:   {Rate: config.RateLimitBatchRate, Burst: config.RateLimitBatchBurst},
+		costIP:      {Rate: config.RateLimitIPRate, Burst: config.RateLimitIPBurst},
+	}
+
+	limiters := make(map[costClass]*ratelimit.Limiter, len(limits))
+	for class, limit := range limits {
+		if limit.Rate > 0 {
+			limiters[class] = ratelimit.New(limit)
+		}
+	}
+	return limiters
+}
+
 func (s *Server) health(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Header().Add("Cache-Control", "no-cache, no-store, must-revalidate;")
@@ -38,17 +76,74 @@ func (s *Server) health(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
-func (s *Server) Start(ctx context.Context) error {
+// protect requires the requests handled by h to be authenticated, unless authentication is disabled.
+// The requests are first rate limited by IP address, so that failed authentications are too.
+// The health checks and the documentation aren't protected.
+func (s *Server) protect(h http.Handler) http.Handler {
+	if s.authenticator == nil {
+		return h
+	}
+	h = WithAuthentication(s.authenticator, h)
+	if limiter, ok := s.rateLimiters[costIP]; ok {
+		h = WithIPRateLimit(limiter, h)
+	}
+	return h
+}
+
+// limit applies the rate limit of class to the requests handled by h, unless it's disabled.
+// It must be wrapped by protect, so that authenticated clients are identified by their credentials.
+func (s *Server) limit(class costClass, cost costFunc, h http.Handler) http.Handler {
+	limiter, ok := s.rateLimiters[class]
+	if !ok {
+		return h
+	}
+	return WithRateLimit(limiter, cost, h)
+}
+
+func (s *Server) routeLimits() RouteLimits {
+	return RouteLimits{
+		MaxLocations:        s.Config.RouteMaxLocations,
+		MaxExcludeLocations: s.Config.RouteMaxExcludeLocations,
+		MaxAlternates:       s.Config.RouteMaxAlternates,
+		MaxLegDistance:      s.Config.RouteMaxLegDistance,
+	}
+}
+
+func (s *Server) corsPolicy() CORSPolicy {
+	return CORSPolicy{
+		AllowedOrigins:   s.Config.CORSAllowedOrigins,
+		AllowCredentials: s.Config.CORSAllowCredentials,
+		AllowedHeaders:   s.Config.CORSAllowedHeaders,
+		ExposedHeaders:   s.Config.CORSExposedHeaders,
+		MaxAge:           s.Config.CORSMaxAge,
+	}
+}
+
+// routes returns the ServeMux of the API routes.
+func (s *Server) routes() *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/docs/", httpSwagger.WrapHandler)
 	mux.HandleFunc("GET /health", s.health)
-	mux.HandleFunc("GET /geocode", s.geocodeHandler())
-	mux.HandleFunc("GET /address", s.addressHandler())
-	mux.HandleFunc("POST /route", s.routeHandler())
+	mux.HandleFunc("GET /health/live", s.liveHandler())
+	mux.HandleFunc("GET /health/ready", s.readyHandler())
+	mux.Handle("GET /metrics", s.protect(s.metrics.Handler()))
+	mux.Handle("GET /geocode", s.protect(s.limit(costDefault, unitCost, s.geocodeHandler())))
+	mux.Handle("GET /autocomplete", s.protect(s.limit(costDefault, unitCost, s.autocompleteHandler())))
+	mux.Handle("GET /address", s.protect(s.limit(costDefault, unitCost, s.addressHandler())))
+	mux.Handle("POST /geocode/batch", s.protect(WithMaxBodyBytes(s.Config.BatchMaxBodyBytes, s.limit(costBatch, batchCost, s.geocodeBatchHandler()))))
+	mux.Handle("POST /address/batch", s.protect(WithMaxBodyBytes(s.Config.BatchMaxBodyBytes, s.limit(costBatch, batchCost, s.addressBatchHandler()))))
+	mux.Handle("GET /jobs/{id}", s.protect(s.limit(costDefault, unitCost, s.jobHandler())))
+	mux.Handle("GET /places/nearby", s.protect(s.limit(costDefault, unitCost, s.nearbyPlacesHandler())))
+	mux.Handle("GET /places/{osm_type}/{osm_id}", s.protect(s.limit(costDefault, unitCost, s.placeDetailsHandler())))
+	mux.Handle("POST /route", s.protect(WithMaxBodyBytes(s.Config.RouteMaxBodyBytes, s.limit(costRoute, routeCost, s.routeHandler()))))
+	mux.Handle("POST /elevation", s.protect(s.limit(costDefault, unitCost, s.elevationHandler())))
+	return mux
+}
 
+func (s *Server) Start(ctx context.Context) error {
 	server := &http.Server{
 		Addr:    net.JoinHostPort(s.Config.APIServerHost, s.Config.APIServerPort),
-		Handler: WithCORS(mux),
+		Handler: WithClientIP(s.Config.TrustedProxies, tracing.Middleware(WithRequestLogging(s.logger, s.metrics.Middleware(WithCORS(s.corsPolicy(), s.routes()))))),
 	}
 
 	go func() {
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"supmap-gis/internal/auth"
+	"supmap-gis/internal/config"
+	"supmap-gis/internal/metrics"
+	"supmap-gis/internal/ratelimit"
+	"testing"
+)
+
+func TestProtectedRoutes(t *testing.T) {
+	const apiKey = "prom-0123456789abcdef"
+	s := &Server{
+		Config:        &config.Config{},
+		metrics:       metrics.New(),
+		authenticator: auth.NewAPIKeyAuthenticator(map[string]string{"prometheus": apiKey}),
+	}
+	mux := s.routes()
+
+	tests := []struct {
+		method     string
+		path       string
+		apiKey     string
+		wantStatus int
+	}{
+		// Health checks and documentation aren't protected.
+		{http.MethodGet, "/health", "", http.StatusOK},
+		{http.MethodGet, "/health/live", "", http.StatusOK},
+		{http.MethodGet, "/docs/index.html", "", http.StatusOK},
+		// Every other route requires credentials, including the metrics.
+		{http.MethodGet, "/metrics", "", http.StatusUnauthorized},
+		{http.MethodGet, "/metrics", "unknown", http.StatusUnauthorized},
+		{http.MethodGet, "/metrics", apiKey, http.StatusOK},
+		{http.MethodGet, "/geocode?q=Caen", "", http.StatusUnauthorized},
+		{http.MethodGet, "/autocomplete?q=Caen", "", http.StatusUnauthorized},
+		{http.MethodGet, "/address?lat=49.18&lon=-0.36", "", http.StatusUnauthorized},
+		{http.MethodPost, "/geocode/batch", "", http.StatusUnauthorized},
+		{http.MethodPost, "/address/batch", "", http.StatusUnauthorized},
+		{http.MethodGet, "/jobs/123", "", http.StatusUnauthorized},
+		{http.MethodGet, "/places/nearby", "", http.StatusUnauthorized},
+		{http.MethodGet, "/places/node/42", "", http.StatusUnauthorized},
+		{http.MethodPost, "/route", "", http.StatusUnauthorized},
+		{http.MethodPost, "/elevation", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.apiKey != "" {
+				r.Header.Set(auth.APIKeyHeader, tt.apiKey)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("missing WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestAuthenticationDisabled(t *testing.T) {
+	s := &Server{Config: &config.Config{}, metrics: metrics.New()}
+
+	w := httptest.NewRecorder()
+	s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestAuthenticatedPrincipalInContext(t *testing.T) {
+	authenticator := auth.NewAPIKeyAuthenticator(map[string]string{"supmap-web": "web-0123456789abcdef"})
+	var got *auth.Principal
+	h := WithAuthentication(authenticator, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = auth.FromContext(r.Context())
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/geocode", nil)
+	r.Header.Set(auth.APIKeyHeader, "web-0123456789abcdef")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if got == nil || got.Subject != "supmap-web" || got.Method != auth.MethodAPIKey {
+		t.Errorf("principal = %+v, want the supmap-web API key", got)
+	}
+}
+
+func TestFailedAuthenticationRateLimited(t *testing.T) {
+	const apiKey = "prom-0123456789abcdef"
+	s := &Server{
+		Config:        &config.Config{},
+		metrics:       metrics.New(),
+		authenticator: auth.NewAPIKeyAuthenticator(map[string]string{"prometheus": apiKey}),
+		rateLimiters:  map[costClass]*ratelimit.Limiter{costIP: ratelimit.New(ratelimit.Limit{Rate: 1, Burst: 2})},
+	}
+	mux := s.routes()
+
+	request := func(remoteAddr, key string) int {
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set(auth.APIKeyHeader, key)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	for range 2 {
+		if got := request("192.0.2.1:1234", "guess"); got != http.StatusUnauthorized {
+			t.Fatalf("status = %d, want %d", got, http.StatusUnauthorized)
+		}
+	}
+	// The address has used its bucket, whatever the credentials.
+	if got := request("192.0.2.1:1234", "guess"); got != http.StatusTooManyRequests {
+		t.Errorf("status of a third attempt = %d, want %d", got, http.StatusTooManyRequests)
+	}
+	if got := request("192.0.2.1:1234", apiKey); got != http.StatusTooManyRequests {
+		t.Errorf("status with a valid key = %d, want %d", got, http.StatusTooManyRequests)
+	}
+	if got := request("192.0.2.2:1234", apiKey); got != http.StatusOK {
+		t.Errorf("status from another address = %d, want %d", got, http.StatusOK)
+	}
+}
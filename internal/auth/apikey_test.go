@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	authenticator := NewAPIKeyAuthenticator(map[string]string{
+		"supmap-web": "web-0123456789abcdef",
+		"prometheus": "prom-0123456789abcdef",
+	})
+
+	tests := []struct {
+		name        string
+		header      string
+		query       string
+		wantSubject string
+		wantErr     error
+	}{
+		{name: "header", header: "web-0123456789abcdef", wantSubject: "supmap-web"},
+		{name: "query parameter", query: "prom-0123456789abcdef", wantSubject: "prometheus"},
+		{name: "header first", header: "web-0123456789abcdef", query: "unknown", wantSubject: "supmap-web"},
+		{name: "unknown key", header: "web-0123456789abcdeX", wantErr: ErrInvalidCredentials},
+		{name: "prefix of a key", header: "web-0123", wantErr: ErrInvalidCredentials},
+		{name: "no key", wantErr: ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/geocode", nil)
+			if tt.header != "" {
+				r.Header.Set(APIKeyHeader, tt.header)
+			}
+			if tt.query != "" {
+				r.URL.RawQuery = APIKeyQueryParam + "=" + tt.query
+			}
+
+			principal, err := authenticator.Authenticate(r)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+			if principal.Subject != tt.wantSubject || principal.Role != APIKeyRole || principal.Method != MethodAPIKey {
+				t.Errorf("Authenticate() = %+v, want subject %q", principal, tt.wantSubject)
+			}
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	options := DefaultJWTOptions()
+	options.Secret = testSecret
+	jwtAuthenticator, err := NewJWTAuthenticator(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := Chain{NewAPIKeyAuthenticator(map[string]string{"supmap-web": "web-0123456789abcdef"}), jwtAuthenticator}
+
+	// The API key authenticator finds no key, and the JWT authenticator rejects the token.
+	r := bearerRequest("not.a.jwt")
+	if _, err := chain.Authenticate(r); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Authenticate() error = %v, want ErrInvalidCredentials", err)
+	}
+
+	// An invalid API key isn't retried with a valid token.
+	r.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", validClaims()))
+	r.Header.Set(APIKeyHeader, "unknown")
+	if _, err := chain.Authenticate(r); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Authenticate() error = %v, want ErrInvalidCredentials", err)
+	}
+
+	r.Header.Del(APIKeyHeader)
+	principal, err := chain.Authenticate(r)
+	if err != nil || principal.Method != MethodJWT {
+		t.Errorf("Authenticate() = %+v, %v, want a JWT principal", principal, err)
+	}
+
+	if _, err := chain.Authenticate(httptest.NewRequest(http.MethodGet, "/geocode", nil)); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Authenticate() error = %v, want ErrNoCredentials", err)
+	}
+}
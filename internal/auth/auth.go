@@ -0,0 +1,64 @@
+// Package auth authenticates the API clients, with static API keys or JWT bearer tokens.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ErrNoCredentials is returned by an [Authenticator] when the request carries none of the credentials it checks.
+var ErrNoCredentials = errors.New("no credentials")
+
+// ErrInvalidCredentials is matched by the errors returned for credentials that can't be accepted.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+type Method string
+
+const (
+	MethodAPIKey Method = "api_key"
+	MethodJWT    Method = "jwt"
+)
+
+// Principal is the authenticated client of a request.
+type Principal struct {
+	// Subject identifies the client: the name of an API key, or the subject of a JWT, e.g. a user ID.
+	Subject string
+	// Role is the role claimed by a JWT, or the role of an API key.
+	Role   string
+	Method Method
+}
+
+// Authenticator authenticates a request with one kind of credentials.
+type Authenticator interface {
+	// Authenticate returns the principal of r, ErrNoCredentials if r doesn't carry the credentials
+	// the authenticator checks, or an error matching ErrInvalidCredentials if they're invalid.
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Chain tries each authenticator in turn, until one finds its credentials in the request.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, authenticator := range c {
+		principal, err := authenticator.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return principal, err
+	}
+	return nil, ErrNoCredentials
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the authenticated client of the request.
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the authenticated client of the request, if any.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
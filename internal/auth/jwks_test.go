@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+)
+
+func TestKeySetKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withAlg := func(jwk map[string]string, alg string) map[string]string {
+		jwk["alg"] = alg
+		return jwk
+	}
+
+	tests := []struct {
+		name   string
+		keys   []map[string]string
+		kid    string
+		alg    string
+		wantOK bool
+	}{
+		{"single key without kid", []map[string]string{rsaJWK("", &rsaKey.PublicKey)}, "", "RS256", true},
+		{"kid found", []map[string]string{rsaJWK("a", &rsaKey.PublicKey), ecJWK("b", &ecKey.PublicKey)}, "b", "ES256", true},
+		{"kid not found", []map[string]string{rsaJWK("a", &rsaKey.PublicKey)}, "b", "RS256", false},
+		{"ambiguous without kid", []map[string]string{rsaJWK("a", &rsaKey.PublicKey), ecJWK("b", &ecKey.PublicKey)}, "", "RS256", false},
+		{"unambiguous without kid thanks to alg", []map[string]string{withAlg(rsaJWK("a", &rsaKey.PublicKey), "RS256"), withAlg(ecJWK("b", &ecKey.PublicKey), "ES256")}, "", "ES256", true},
+		{"alg of the key mismatch", []map[string]string{withAlg(rsaJWK("a", &rsaKey.PublicKey), "RS256")}, "a", "RS512", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := LoadKeySet(writeJWKS(t, tt.keys...))
+			if err != nil {
+				t.Fatalf("LoadKeySet() error = %v", err)
+			}
+			if _, ok := set.Key(tt.kid, tt.alg); ok != tt.wantOK {
+				t.Errorf("Key(%q, %q) ok = %v, want %v", tt.kid, tt.alg, ok, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestLoadKeySetErrors(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offCurve := ecJWK("a", &ecKey.PublicKey)
+	offCurve["y"] = offCurve["x"]
+
+	tests := []struct {
+		name string
+		keys []map[string]string
+	}{
+		{"no keys", nil},
+		{"only encryption keys", []map[string]string{{"kty": "RSA", "use": "enc", "n": "AQAB", "e": "AQAB"}}},
+		{"unsupported key type", []map[string]string{{"kty": "oct", "k": "c2VjcmV0"}}},
+		{"unsupported curve", []map[string]string{{"kty": "EC", "crv": "P-192", "x": "AQ", "y": "AQ"}}},
+		{"point not on the curve", []map[string]string{offCurve}},
+		{"invalid Ed25519 key", []map[string]string{{"kty": "OKP", "crv": "Ed25519", "x": "AQID"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadKeySet(writeJWKS(t, tt.keys...)); err == nil {
+				t.Error("LoadKeySet() succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTAuthenticator authenticates the requests carrying a JWT in an "Authorization: Bearer" header,
+// signed with a shared HMAC secret or with one of the keys of a JWKS.
+type JWTAuthenticator struct {
+	secret []byte
+	keys   *KeySet
+	parser *jwt.Parser
+
+	options JWTOptions
+}
+
+type JWTOptions struct {
+	// Secret is the HMAC secret shared with the issuer of the tokens, which enables HS256, HS384 and HS512.
+	Secret string
+	// Keys are the public keys of the issuer of the tokens, which enable the asymmetric algorithms.
+	Keys *KeySet
+	// Issuer and Audience, if not empty, must match the iss and aud claims.
+	Issuer   string
+	Audience string
+	// SubjectClaim and RoleClaim are the claims holding the subject and the role of the client.
+	SubjectClaim string
+	RoleClaim    string
+	// Leeway tolerates clock skew when checking the expiration and validity times.
+	Leeway time.Duration
+}
+
+func DefaultJWTOptions() JWTOptions {
+	return JWTOptions{
+		SubjectClaim: "sub",
+		RoleClaim:    "role",
+		Leeway:       30 * time.Second,
+	}
+}
+
+var (
+	hmacMethods       = []string{"HS256", "HS384", "HS512"}
+	asymmetricMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+)
+
+// NewJWTAuthenticator returns an authenticator of the tokens signed with the secret or the keys of options,
+// at least one of which must be set.
+func NewJWTAuthenticator(options JWTOptions) (*JWTAuthenticator, error) {
+	var methods []string
+	if options.Secret != "" {
+		methods = append(methods, hmacMethods...)
+	}
+	if options.Keys != nil {
+		methods = append(methods, asymmetricMethods...)
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("a JWT authenticator requires a secret or keys")
+	}
+
+	parserOptions := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithLeeway(options.Leeway),
+		jwt.WithExpirationRequired(),
+	}
+	if options.Issuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(options.Issuer))
+	}
+	if options.Audience != "" {
+		parserOptions = append(parserOptions, jwt.WithAudience(options.Audience))
+	}
+
+	return &JWTAuthenticator{
+		secret:  []byte(options.Secret),
+		keys:    options.Keys,
+		parser:  jwt.NewParser(parserOptions...),
+		options: options,
+	}, nil
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, ErrNoCredentials
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(strings.TrimSpace(token), claims, a.key); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	subject := claimString(claims[a.options.SubjectClaim])
+	if subject == "" {
+		return nil, fmt.Errorf("%w: missing %s claim", ErrInvalidCredentials, a.options.SubjectClaim)
+	}
+
+	return &Principal{
+		Subject: subject,
+		Role:    claimString(claims[a.options.RoleClaim]),
+		Method:  MethodJWT,
+	}, nil
+}
+
+// key returns the key verifying the signature of token. The algorithm has already been checked against
+// the valid methods, so that a public key can't be used as an HMAC secret.
+func (a *JWTAuthenticator) key(token *jwt.Token) (any, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		return a.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := a.keys.Key(kid, token.Method.Alg())
+	if !ok {
+		return nil, fmt.Errorf("no key found for kid %q and alg %s", kid, token.Method.Alg())
+	}
+	return key, nil
+}
+
+// claimString returns a string or number claim as a string, since user IDs may be numbers.
+func claimString(claim any) string {
+	switch v := claim.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
@@ -0,0 +1,337 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testSecret   = "a-secret-shared-with-the-user-service"
+	testIssuer   = "supmap-users"
+	testAudience = "supmap-gis"
+)
+
+type testKeys struct {
+	rsa1, rsa2 *rsa.PrivateKey
+	ec         *ecdsa.PrivateKey
+	ed         ed25519.PrivateKey
+	jwksPath   string
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	var keys testKeys
+	var err error
+	if keys.rsa1, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	if keys.rsa2, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	if keys.ec, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if _, keys.ed, err = ed25519.GenerateKey(rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+
+	keys.jwksPath = writeJWKS(t,
+		rsaJWK("rsa-1", &keys.rsa1.PublicKey),
+		rsaJWK("rsa-2", &keys.rsa2.PublicKey),
+		ecJWK("ec-1", &keys.ec.PublicKey),
+		edJWK("ed-1", keys.ed.Public().(ed25519.PublicKey)),
+		// Encryption keys are ignored.
+		map[string]string{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": "invalid", "e": "AQAB"},
+	)
+	return keys
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": kid, "use": "sig", "n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{"kty": "EC", "kid": kid, "crv": "P-256", "x": b64(key.X.Bytes()), "y": b64(key.Y.Bytes())}
+}
+
+func edJWK(kid string, key ed25519.PublicKey) map[string]string {
+	return map[string]string{"kty": "OKP", "kid": kid, "crv": "Ed25519", "x": b64(key)}
+}
+
+func writeJWKS(t *testing.T, keys ...map[string]string) string {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func validClaims(changes ...func(jwt.MapClaims)) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"sub":  "user-42",
+		"role": "admin",
+		"iss":  testIssuer,
+		"aud":  testAudience,
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	}
+	for _, change := range changes {
+		change(claims)
+	}
+	return claims
+}
+
+func bearerRequest(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/geocode", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	keys := newTestKeys(t)
+	keySet, err := LoadKeySet(keys.jwksPath)
+	if err != nil {
+		t.Fatalf("LoadKeySet() error = %v", err)
+	}
+
+	options := DefaultJWTOptions()
+	options.Secret = testSecret
+	options.Keys = keySet
+	options.Issuer = testIssuer
+	options.Audience = testAudience
+	authenticator, err := NewJWTAuthenticator(options)
+	if err != nil {
+		t.Fatalf("NewJWTAuthenticator() error = %v", err)
+	}
+
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: must(x509.MarshalPKIXPublicKey(&keys.rsa1.PublicKey))})
+
+	tests := []struct {
+		name        string
+		token       string
+		wantSubject string
+		wantRole    string
+	}{
+		{
+			name:        "HS256 with the shared secret",
+			token:       sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", validClaims()),
+			wantSubject: "user-42",
+			wantRole:    "admin",
+		},
+		{
+			name:        "RS256 with a kid",
+			token:       sign(t, jwt.SigningMethodRS256, keys.rsa2, "rsa-2", validClaims()),
+			wantSubject: "user-42",
+			wantRole:    "admin",
+		},
+		{
+			name:        "ES256",
+			token:       sign(t, jwt.SigningMethodES256, keys.ec, "ec-1", validClaims()),
+			wantSubject: "user-42",
+			wantRole:    "admin",
+		},
+		{
+			name:        "EdDSA",
+			token:       sign(t, jwt.SigningMethodEdDSA, keys.ed, "ed-1", validClaims()),
+			wantSubject: "user-42",
+			wantRole:    "admin",
+		},
+		{
+			name:        "numeric subject",
+			token:       sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", validClaims(func(c jwt.MapClaims) { c["sub"] = 42 })),
+			wantSubject: "42",
+			wantRole:    "admin",
+		},
+		{
+			name:        "expired within the leeway",
+			token:       sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", validClaims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-10 * time.Second).Unix() })),
+			wantSubject: "user-42",
+			wantRole:    "admin",
+		},
+		{
+			name:  "alg none",
+			token: sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", validClaims()),
+		},
+		{
+			name:  "HS256 signed with the RSA public key",
+			token: sign(t, jwt.SigningMethodHS256, publicKeyPEM, "rsa-1", validClaims()),
+		},
+		{
+			name:  "HS256 with another secret",
+			token: sign(t, jwt.SigningMethodHS256, []byte("another-secret"), "", validClaims()),
+		},
+		{
+			name:  "RS256 signed with another key than its kid",
+			token: sign(t, jwt.SigningMethodRS256, keys.rsa1, "rsa-2", validClaims()),
+		},
+		{
+			name:  "unknown kid",
+			token: sign(t, jwt.SigningMethodRS256, keys.rsa1, "rsa-3", validClaims()),
+		},
+		{
+			name:  "no kid with several RSA keys",
+			token: sign(t, jwt.SigningMethodRS256, keys.rsa1, "", validClaims()),
+		},
+		{
+			name:  "expired",
+			token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", validClaims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() })),
+		},
+		{
+			name:  "missing exp",
+			token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", validClaims(func(c jwt.MapClaims) { delete(c, "exp") })),
+		},
+		{
+			name:  "not valid yet",
+			token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", validClaims(func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() })),
+		},
+		{
+			name:  "wrong issuer",
+			token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", validClaims(func(c jwt.MapClaims) { c["iss"] = "someone-else" })),
+		},
+		{
+			name:  "wrong audience",
+			token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", validClaims(func(c jwt.MapClaims) { c["aud"] = "another-service" })),
+		},
+		{
+			name:  "missing subject",
+			token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", validClaims(func(c jwt.MapClaims) { delete(c, "sub") })),
+		},
+		{
+			name:  "malformed token",
+			token: "not.a.jwt",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := authenticator.Authenticate(bearerRequest(tt.token))
+			if tt.wantSubject == "" {
+				if !errors.Is(err, ErrInvalidCredentials) {
+					t.Fatalf("Authenticate() error = %v, want ErrInvalidCredentials", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+			if principal.Subject != tt.wantSubject || principal.Role != tt.wantRole || principal.Method != MethodJWT {
+				t.Errorf("Authenticate() = %+v, want subject %q and role %q", principal, tt.wantSubject, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticatorWithoutKeys(t *testing.T) {
+	keys := newTestKeys(t)
+	options := DefaultJWTOptions()
+	options.Secret = testSecret
+	authenticator, err := NewJWTAuthenticator(options)
+	if err != nil {
+		t.Fatalf("NewJWTAuthenticator() error = %v", err)
+	}
+
+	// Asymmetric algorithms are only accepted with a JWKS.
+	token := sign(t, jwt.SigningMethodRS256, keys.rsa1, "rsa-1", validClaims())
+	if _, err := authenticator.Authenticate(bearerRequest(token)); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Authenticate() error = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestJWTAuthenticatorWithoutSecret(t *testing.T) {
+	keys := newTestKeys(t)
+	keySet, err := LoadKeySet(keys.jwksPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := DefaultJWTOptions()
+	options.Keys = keySet
+	authenticator, err := NewJWTAuthenticator(options)
+	if err != nil {
+		t.Fatalf("NewJWTAuthenticator() error = %v", err)
+	}
+
+	// Without a secret, HMAC algorithms are rejected, so that the public key can't be used as one.
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: must(x509.MarshalPKIXPublicKey(&keys.rsa1.PublicKey))})
+	for _, key := range [][]byte{publicKeyPEM, keys.rsa1.PublicKey.N.Bytes(), nil} {
+		token := sign(t, jwt.SigningMethodHS256, key, "rsa-1", validClaims())
+		if _, err := authenticator.Authenticate(bearerRequest(token)); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("Authenticate() error = %v, want ErrInvalidCredentials", err)
+		}
+	}
+
+	token := sign(t, jwt.SigningMethodRS256, keys.rsa1, "rsa-1", validClaims())
+	if _, err := authenticator.Authenticate(bearerRequest(token)); err != nil {
+		t.Errorf("Authenticate() error = %v", err)
+	}
+}
+
+func TestJWTAuthenticatorNoCredentials(t *testing.T) {
+	options := DefaultJWTOptions()
+	options.Secret = testSecret
+	authenticator, err := NewJWTAuthenticator(options)
+	if err != nil {
+		t.Fatalf("NewJWTAuthenticator() error = %v", err)
+	}
+
+	for _, header := range []string{"", "Basic dXNlcjpwYXNz", "Bearer"} {
+		r := httptest.NewRequest(http.MethodGet, "/geocode", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		if _, err := authenticator.Authenticate(r); !errors.Is(err, ErrNoCredentials) {
+			t.Errorf("Authenticate() with %q error = %v, want ErrNoCredentials", header, err)
+		}
+	}
+}
+
+func TestNewJWTAuthenticatorRequiresAKey(t *testing.T) {
+	if _, err := NewJWTAuthenticator(DefaultJWTOptions()); err == nil {
+		t.Error("NewJWTAuthenticator() without secret nor keys succeeded")
+	}
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
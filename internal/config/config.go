@@ -3,17 +3,122 @@ package config
 import (
 	"fmt"
 	"github.com/caarlos0/env/v11"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	APIServerHost       string `env:"API_SERVER_HOST"`
-	APIServerPort       string `env:"API_SERVER_PORT"`
-	NominatimHost       string `env:"NOMINATIM_HOST"`
-	NominatimPort       string `env:"NOMINATIM_PORT"`
-	ValhallaHost        string `env:"VALHALLA_HOST"`
-	ValhallaPort        string `env:"VALHALLA_PORT"`
-	SupmapIncidentsHost string `env:"SUPMAP_INCIDENTS_HOST"`
-	SupmapIncidentsPort string `env:"SUPMAP_INCIDENTS_PORT"`
+	APIServerHost string `env:"API_SERVER_HOST"`
+	APIServerPort string `env:"API_SERVER_PORT"`
+
+	// CORSAllowedOrigins are "*", origins, or origins with a wildcard subdomain such as "https://*.supmap.fr".
+	CORSAllowedOrigins   []string      `env:"CORS_ALLOWED_ORIGINS" envDefault:"*" envSeparator:","`
+	CORSAllowCredentials bool          `env:"CORS_ALLOW_CREDENTIALS" envDefault:"false"`
+	CORSAllowedHeaders   []string      `env:"CORS_ALLOWED_HEADERS" envDefault:"Content-Type,Authorization,X-API-Key,X-Request-ID" envSeparator:","`
+	CORSExposedHeaders   []string      `env:"CORS_EXPOSED_HEADERS" envDefault:"X-Request-ID,Retry-After,Cache-Status,RateLimit-Policy,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset" envSeparator:","`
+	CORSMaxAge           time.Duration `env:"CORS_MAX_AGE" envDefault:"10m"`
+
+	// AuthAPIKeys are the static API keys indexed by the name of their client, as "name:key" pairs
+	// separated by commas. Authentication is disabled if neither API keys nor a JWT secret or JWKS are set.
+	AuthAPIKeys map[string]string `env:"AUTH_API_KEYS"`
+	// AuthJWTSecret is the HMAC secret shared with the user service, and AuthJWTJWKSFile a JWKS file
+	// of the public keys of the tokens issuer.
+	AuthJWTSecret       string        `env:"AUTH_JWT_SECRET"`
+	AuthJWTJWKSFile     string        `env:"AUTH_JWT_JWKS_FILE"`
+	AuthJWTIssuer       string        `env:"AUTH_JWT_ISSUER"`
+	AuthJWTAudience     string        `env:"AUTH_JWT_AUDIENCE"`
+	AuthJWTSubjectClaim string        `env:"AUTH_JWT_SUBJECT_CLAIM" envDefault:"sub"`
+	AuthJWTRoleClaim    string        `env:"AUTH_JWT_ROLE_CLAIM" envDefault:"role"`
+	AuthJWTLeeway       time.Duration `env:"AUTH_JWT_LEEWAY" envDefault:"30s"`
+
+	// RateLimit*Rate is the number of tokens per second refilling the bucket of each client for a class
+	// of endpoints, 0 disabling the limit, and RateLimit*Burst the capacity of the bucket.
+	RateLimitDefaultRate  float64 `env:"RATE_LIMIT_DEFAULT_RATE" envDefault:"10"`
+	RateLimitDefaultBurst int     `env:"RATE_LIMIT_DEFAULT_BURST" envDefault:"20"`
+	RateLimitRouteRate    float64 `env:"RATE_LIMIT_ROUTE_RATE" envDefault:"2"`
+	RateLimitRouteBurst   int     `env:"RATE_LIMIT_ROUTE_BURST" envDefault:"20"`
+	RateLimitBatchRate    float64 `env:"RATE_LIMIT_BATCH_RATE" envDefault:"1"`
+	RateLimitBatchBurst   int     `env:"RATE_LIMIT_BATCH_BURST" envDefault:"100"`
+	// RateLimitIP* limit the requests of each IP address before their authentication, if enabled.
+	RateLimitIPRate  float64 `env:"RATE_LIMIT_IP_RATE" envDefault:"50"`
+	RateLimitIPBurst int     `env:"RATE_LIMIT_IP_BURST" envDefault:"100"`
+
+	// TrustedProxies are the networks of the reverse proxies whose X-Forwarded-For header gives the
+	// address of the client. The header is ignored if empty.
+	TrustedProxies []netip.Prefix `env:"TRUSTED_PROXIES" envSeparator:","`
+
+	Nominatim       Upstream `envPrefix:"NOMINATIM_"`
+	Valhalla        Upstream `envPrefix:"VALHALLA_"`
+	SupmapIncidents Upstream `envPrefix:"SUPMAP_INCIDENTS_"`
+
+	// GeocodingBackends are the geocoding backends in priority order: "nominatim", "photon", "pelias"
+	// or "gazetteer". GeocodingMode is how they are combined: "failover" or "merge".
+	GeocodingBackends []string `env:"GEOCODING_BACKENDS" envDefault:"nominatim" envSeparator:","`
+	GeocodingMode     string   `env:"GEOCODING_MODE" envDefault:"failover"`
+	Photon            Upstream `envPrefix:"PHOTON_"`
+	Pelias            Upstream `envPrefix:"PELIAS_"`
+	PeliasAPIKey      string   `env:"PELIAS_API_KEY"`
+	// GazetteerFile is the path of a CSV or GeoJSON file of places, for offline geocoding.
+	GazetteerFile               string  `env:"GAZETTEER_FILE"`
+	GazetteerReverseMaxDistance float64 `env:"GAZETTEER_REVERSE_MAX_DISTANCE" envDefault:"1000"`
+
+	// GeocodingCacheSize is the maximum number of cached geocoding results, 0 disabling the cache.
+	GeocodingCacheSize             int           `env:"GEOCODING_CACHE_SIZE" envDefault:"10000"`
+	GeocodingCacheTTL              time.Duration `env:"GEOCODING_CACHE_TTL" envDefault:"24h"`
+	GeocodingCacheNegativeTTL      time.Duration `env:"GEOCODING_CACHE_NEGATIVE_TTL" envDefault:"10m"`
+	GeocodingCacheReversePrecision int           `env:"GEOCODING_CACHE_REVERSE_PRECISION" envDefault:"4"`
+
+	RouteAlternatesMaxOverlap float64 `env:"ROUTE_ALTERNATES_MAX_OVERLAP" envDefault:"0.9"`
+
+	// RouteMax* bound the size and the complexity of the route requests, 0 disabling a limit except
+	// the body size. RouteMaxLegDistance is the maximum straight-line distance in meters between
+	// consecutive locations.
+	RouteMaxBodyBytes        int64   `env:"ROUTE_MAX_BODY_BYTES" envDefault:"65536"`
+	RouteMaxLocations        int     `env:"ROUTE_MAX_LOCATIONS" envDefault:"20"`
+	RouteMaxExcludeLocations int     `env:"ROUTE_MAX_EXCLUDE_LOCATIONS" envDefault:"50"`
+	RouteMaxAlternates       int     `env:"ROUTE_MAX_ALTERNATES" envDefault:"3"`
+	RouteMaxLegDistance      float64 `env:"ROUTE_MAX_LEG_DISTANCE" envDefault:"1500000"`
+
+	// RouteCacheSize is the maximum number of cached routes, 0 disabling the cache.
+	RouteCacheSize      int           `env:"ROUTE_CACHE_SIZE" envDefault:"1000"`
+	RouteCacheTTL       time.Duration `env:"ROUTE_CACHE_TTL" envDefault:"5m"`
+	RouteCachePrecision int           `env:"ROUTE_CACHE_PRECISION" envDefault:"4"`
+
+	// ElevationProvider can be "srtm", "valhalla", or empty to disable elevation profiles.
+	ElevationProvider       string  `env:"ELEVATION_PROVIDER"`
+	ElevationSRTMDir        string  `env:"ELEVATION_SRTM_DIR"`
+	ElevationSRTMCacheSize  int     `env:"ELEVATION_SRTM_CACHE_SIZE" envDefault:"16"`
+	ElevationSampleInterval float64 `env:"ELEVATION_SAMPLE_INTERVAL" envDefault:"100"`
+
+	AutocompleteMinLength int `env:"AUTOCOMPLETE_MIN_LENGTH" envDefault:"3"`
+	// AutocompleteCacheSize is the maximum number of cached autocomplete queries, 0 disabling the cache.
+	AutocompleteCacheSize int           `env:"AUTOCOMPLETE_CACHE_SIZE" envDefault:"1000"`
+	AutocompleteCacheTTL  time.Duration `env:"AUTOCOMPLETE_CACHE_TTL" envDefault:"5m"`
+
+	BatchConcurrency  int           `env:"BATCH_CONCURRENCY" envDefault:"4"`
+	BatchSyncMaxItems int           `env:"BATCH_SYNC_MAX_ITEMS" envDefault:"100"`
+	BatchMaxItems     int           `env:"BATCH_MAX_ITEMS" envDefault:"10000"`
+	BatchMaxBodyBytes int64         `env:"BATCH_MAX_BODY_BYTES" envDefault:"5242880"`
+	BatchMaxJobs      int           `env:"BATCH_MAX_JOBS" envDefault:"4"`
+	BatchJobTTL       time.Duration `env:"BATCH_JOB_TTL" envDefault:"1h"`
+
+	// HealthCheckTimeout bounds the probe of each dependency by /health/ready, whose report is
+	// reused for HealthCheckCacheTTL.
+	HealthCheckTimeout  time.Duration `env:"HEALTH_CHECK_TIMEOUT" envDefault:"2s"`
+	HealthCheckCacheTTL time.Duration `env:"HEALTH_CHECK_CACHE_TTL" envDefault:"5s"`
+
+	// TracingExporter is where the spans are exported: "none", "stdout" or "otlp". The OTLP exporter
+	// is configured by the standard OTEL_EXPORTER_OTLP_* variables.
+	TracingExporter    string  `env:"TRACING_EXPORTER" envDefault:"none"`
+	TracingSampleRatio float64 `env:"TRACING_SAMPLE_RATIO" envDefault:"1"`
+
+	// TollTariffsFile is the path of a JSON file of toll tariffs. Toll costs aren't estimated if empty.
+	TollTariffsFile string `env:"TOLL_TARIFFS_FILE"`
+	// TollTruckClass is the toll vehicle class of the truck costing: 3 for 2 axles, 4 for 3 axles or more.
+	TollTruckClass int `env:"TOLL_TRUCK_CLASS" envDefault:"4"`
 }
 
 func New() (*Config, error) {
@@ -21,5 +126,208 @@ func New() (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
+	if err := validateCORS(&cfg); err != nil {
+		return nil, err
+	}
+	if err := validateAuth(&cfg); err != nil {
+		return nil, err
+	}
+	if err := validateRateLimits(&cfg); err != nil {
+		return nil, err
+	}
+	if err := validateUpstreams(&cfg); err != nil {
+		return nil, err
+	}
+	if err := validateGeocodingBackends(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.GeocodingCacheSize < 0 {
+		return nil, fmt.Errorf("GEOCODING_CACHE_SIZE must not be negative, got %d", cfg.GeocodingCacheSize)
+	}
+	if cfg.GeocodingCacheReversePrecision < 0 || cfg.GeocodingCacheReversePrecision > 7 {
+		return nil, fmt.Errorf("GEOCODING_CACHE_REVERSE_PRECISION must be between 0 and 7, got %d", cfg.GeocodingCacheReversePrecision)
+	}
+	if cfg.RouteAlternatesMaxOverlap < 0 || cfg.RouteAlternatesMaxOverlap > 1 {
+		return nil, fmt.Errorf("ROUTE_ALTERNATES_MAX_OVERLAP must be between 0 and 1, got %v", cfg.RouteAlternatesMaxOverlap)
+	}
+	if cfg.RouteMaxBodyBytes <= 0 {
+		return nil, fmt.Errorf("ROUTE_MAX_BODY_BYTES must be positive, got %d", cfg.RouteMaxBodyBytes)
+	}
+	if cfg.RouteMaxLocations < 0 || cfg.RouteMaxLocations == 1 {
+		return nil, fmt.Errorf("ROUTE_MAX_LOCATIONS must be 0 or at least 2, got %d", cfg.RouteMaxLocations)
+	}
+	if cfg.RouteMaxExcludeLocations < 0 {
+		return nil, fmt.Errorf("ROUTE_MAX_EXCLUDE_LOCATIONS must not be negative, got %d", cfg.RouteMaxExcludeLocations)
+	}
+	if cfg.RouteMaxAlternates < 0 {
+		return nil, fmt.Errorf("ROUTE_MAX_ALTERNATES must not be negative, got %d", cfg.RouteMaxAlternates)
+	}
+	if cfg.RouteMaxLegDistance < 0 {
+		return nil, fmt.Errorf("ROUTE_MAX_LEG_DISTANCE must not be negative, got %v", cfg.RouteMaxLegDistance)
+	}
+	if cfg.RouteCacheSize < 0 {
+		return nil, fmt.Errorf("ROUTE_CACHE_SIZE must not be negative, got %d", cfg.RouteCacheSize)
+	}
+	if cfg.RouteCachePrecision < 0 || cfg.RouteCachePrecision > 7 {
+		return nil, fmt.Errorf("ROUTE_CACHE_PRECISION must be between 0 and 7, got %d", cfg.RouteCachePrecision)
+	}
+	switch cfg.ElevationProvider {
+	case "", "valhalla":
+	case "srtm":
+		if cfg.ElevationSRTMDir == "" {
+			return nil, fmt.Errorf("ELEVATION_SRTM_DIR is required when ELEVATION_PROVIDER is %q", cfg.ElevationProvider)
+		}
+		if cfg.ElevationSRTMCacheSize <= 0 {
+			return nil, fmt.Errorf("ELEVATION_SRTM_CACHE_SIZE must be positive, got %d", cfg.ElevationSRTMCacheSize)
+		}
+	default:
+		return nil, fmt.Errorf("ELEVATION_PROVIDER %q is invalid", cfg.ElevationProvider)
+	}
+	if cfg.AutocompleteCacheSize < 0 {
+		return nil, fmt.Errorf("AUTOCOMPLETE_CACHE_SIZE must not be negative, got %d", cfg.AutocompleteCacheSize)
+	}
+	if cfg.BatchMaxBodyBytes <= 0 {
+		return nil, fmt.Errorf("BATCH_MAX_BODY_BYTES must be positive, got %d", cfg.BatchMaxBodyBytes)
+	}
+	if cfg.BatchMaxJobs <= 0 {
+		return nil, fmt.Errorf("BATCH_MAX_JOBS must be positive, got %d", cfg.BatchMaxJobs)
+	}
+	if cfg.BatchConcurrency <= 0 {
+		return nil, fmt.Errorf("BATCH_CONCURRENCY must be positive, got %d", cfg.BatchConcurrency)
+	}
+	if cfg.ElevationSampleInterval <= 0 {
+		return nil, fmt.Errorf("ELEVATION_SAMPLE_INTERVAL must be positive, got %v", cfg.ElevationSampleInterval)
+	}
+	if cfg.HealthCheckTimeout <= 0 {
+		return nil, fmt.Errorf("HEALTH_CHECK_TIMEOUT must be positive, got %s", cfg.HealthCheckTimeout)
+	}
+	if cfg.HealthCheckCacheTTL < 0 {
+		return nil, fmt.Errorf("HEALTH_CHECK_CACHE_TTL must not be negative, got %s", cfg.HealthCheckCacheTTL)
+	}
+	if cfg.TollTruckClass != 3 && cfg.TollTruckClass != 4 {
+		return nil, fmt.Errorf("TOLL_TRUCK_CLASS must be 3 or 4, got %d", cfg.TollTruckClass)
+	}
+	switch cfg.TracingExporter {
+	case "none", "stdout", "otlp":
+	default:
+		return nil, fmt.Errorf("TRACING_EXPORTER %q is invalid", cfg.TracingExporter)
+	}
+	if cfg.TracingSampleRatio < 0 || cfg.TracingSampleRatio > 1 {
+		return nil, fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1, got %v", cfg.TracingSampleRatio)
+	}
 	return &cfg, nil
 }
+
+func validateGeocodingBackends(cfg *Config) error {
+	if len(cfg.GeocodingBackends) == 0 {
+		return fmt.Errorf("GEOCODING_BACKENDS must contain at least one backend")
+	}
+	switch cfg.GeocodingMode {
+	case "failover", "merge":
+	default:
+		return fmt.Errorf("GEOCODING_MODE %q is invalid", cfg.GeocodingMode)
+	}
+
+	seen := make(map[string]bool, len(cfg.GeocodingBackends))
+	for _, backend := range cfg.GeocodingBackends {
+		if seen[backend] {
+			return fmt.Errorf("GEOCODING_BACKENDS contains %q twice", backend)
+		}
+		seen[backend] = true
+
+		switch backend {
+		case "nominatim":
+		case "photon":
+			if !cfg.Photon.Configured() {
+				return fmt.Errorf("PHOTON_URL or PHOTON_HOST is required when GEOCODING_BACKENDS contains %q", backend)
+			}
+		case "pelias":
+			if !cfg.Pelias.Configured() {
+				return fmt.Errorf("PELIAS_URL or PELIAS_HOST is required when GEOCODING_BACKENDS contains %q", backend)
+			}
+		case "gazetteer":
+			if cfg.GazetteerFile == "" {
+				return fmt.Errorf("GAZETTEER_FILE is required when GEOCODING_BACKENDS contains %q", backend)
+			}
+			if cfg.GazetteerReverseMaxDistance <= 0 {
+				return fmt.Errorf("GAZETTEER_REVERSE_MAX_DISTANCE must be positive, got %v", cfg.GazetteerReverseMaxDistance)
+			}
+		default:
+			return fmt.Errorf("GEOCODING_BACKENDS contains an invalid backend %q", backend)
+		}
+	}
+	return nil
+}
+
+func validateCORS(cfg *Config) error {
+	if len(cfg.CORSAllowedOrigins) == 0 {
+		return fmt.Errorf("CORS_ALLOWED_ORIGINS must contain at least one origin")
+	}
+	for _, origin := range cfg.CORSAllowedOrigins {
+		if origin == "*" {
+			// Browsers refuse credentials with a wildcard origin, and echoing any origin would let any site use them.
+			if cfg.CORSAllowCredentials {
+				return fmt.Errorf("CORS_ALLOWED_ORIGINS must list the origins when CORS_ALLOW_CREDENTIALS is true")
+			}
+			continue
+		}
+
+		parsed, err := url.Parse(strings.Replace(origin, "://*.", "://", 1))
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" ||
+			parsed.Path != "" || parsed.RawQuery != "" || parsed.Fragment != "" || strings.Contains(parsed.Host, "*") {
+			return fmt.Errorf("CORS_ALLOWED_ORIGINS contains an invalid origin %q", origin)
+		}
+	}
+	if cfg.CORSMaxAge < 0 {
+		return fmt.Errorf("CORS_MAX_AGE must not be negative, got %s", cfg.CORSMaxAge)
+	}
+	return nil
+}
+
+// minAPIKeyLength is the minimum length of the API keys, so that they can't be guessed.
+const minAPIKeyLength = 16
+
+func validateAuth(cfg *Config) error {
+	for name, key := range cfg.AuthAPIKeys {
+		if name == "" {
+			return fmt.Errorf("AUTH_API_KEYS contains a key without a name")
+		}
+		if len(key) < minAPIKeyLength {
+			return fmt.Errorf("AUTH_API_KEYS: the key of %q must be at least %d characters long", name, minAPIKeyLength)
+		}
+	}
+	if cfg.AuthJWTJWKSFile != "" {
+		if _, err := os.Stat(cfg.AuthJWTJWKSFile); err != nil {
+			return fmt.Errorf("AUTH_JWT_JWKS_FILE is invalid: %w", err)
+		}
+	}
+	if cfg.AuthJWTSubjectClaim == "" {
+		return fmt.Errorf("AUTH_JWT_SUBJECT_CLAIM must not be empty")
+	}
+	if cfg.AuthJWTLeeway < 0 {
+		return fmt.Errorf("AUTH_JWT_LEEWAY must not be negative, got %s", cfg.AuthJWTLeeway)
+	}
+	return nil
+}
+
+func validateRateLimits(cfg *Config) error {
+	limits := []struct {
+		class string
+		rate  float64
+		burst int
+	}{
+		{"DEFAULT", cfg.RateLimitDefaultRate, cfg.RateLimitDefaultBurst},
+		{"ROUTE", cfg.RateLimitRouteRate, cfg.RateLimitRouteBurst},
+		{"BATCH", cfg.RateLimitBatchRate, cfg.RateLimitBatchBurst},
+		{"IP", cfg.RateLimitIPRate, cfg.RateLimitIPBurst},
+	}
+	for _, limit := range limits {
+		if limit.rate < 0 {
+			return fmt.Errorf("RATE_LIMIT_%s_RATE must not be negative, got %v", limit.class, limit.rate)
+		}
+		if limit.rate > 0 && limit.burst < 1 {
+			return fmt.Errorf("RATE_LIMIT_%s_BURST must be positive, got %d", limit.class, limit.burst)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+// setRequiredEnv sets the variables without which the configuration is invalid.
+func setRequiredEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("NOMINATIM_URL", "http://nominatim:8080")
+	t.Setenv("VALHALLA_URL", "http://valhalla:8002")
+	t.Setenv("SUPMAP_INCIDENTS_URL", "http://incidents:8080")
+}
+
+func TestUpstreamHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:  "values with commas, colons and equal signs",
+			value: "User-Agent=supmap-gis/1.0 (contact: ops@example.com);Accept=application/json, */*;X-Token=a=b",
+			want: map[string]string{
+				"User-Agent": "supmap-gis/1.0 (contact: ops@example.com)",
+				"Accept":     "application/json, */*",
+				"X-Token":    "a=b",
+			},
+		},
+		{name: "single header", value: "User-Agent=supmap-gis/1.0", want: map[string]string{"User-Agent": "supmap-gis/1.0"}},
+		{name: "missing value", value: "User-Agent", wantErr: `"User-Agent" should be in "key=value" format`},
+		{name: "former format", value: "User-Agent:supmap-gis/1.0", wantErr: "should be in"},
+		{name: "space in the name", value: "User-Agent=supmap-gis/1.0; Accept=*/*", wantErr: `NOMINATIM_HEADERS contains an invalid header name " Accept"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			t.Setenv("NOMINATIM_HEADERS", tt.value)
+
+			cfg, err := New()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("New() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if !maps.Equal(cfg.Nominatim.Headers, tt.want) {
+				t.Errorf("headers = %q, want %q", cfg.Nominatim.Headers, tt.want)
+			}
+			if cfg.Valhalla.Headers != nil {
+				t.Errorf("headers of another upstream = %q, want none", cfg.Valhalla.Headers)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Upstream is the configuration of the client of an upstream service, read from the variables
+// prefixed by its name, e.g. NOMINATIM_URL.
+type Upstream struct {
+	// URL is the base URL of the upstream, scheme and path prefix included, e.g. https://example.com/nominatim.
+	// It takes precedence over Host and Port, which give a base URL of http://host:port.
+	URL  string `env:"URL"`
+	Host string `env:"HOST"`
+	Port string `env:"PORT"`
+
+	// Timeout bounds each call, retries included.
+	Timeout time.Duration `env:"TIMEOUT" envDefault:"7s"`
+	// MaxIdleConns is the maximum number of idle connections kept open, 0 using Go's defaults.
+	MaxIdleConns int `env:"MAX_IDLE_CONNS" envDefault:"100"`
+
+	// TLSCAFile is a PEM file of certificate authorities trusted in addition to the system ones.
+	TLSCAFile string `env:"TLS_CA_FILE"`
+	// TLSCertFile and TLSKeyFile are the PEM files of a client certificate, for mutual TLS.
+	TLSCertFile string `env:"TLS_CERT_FILE"`
+	TLSKeyFile  string `env:"TLS_KEY_FILE"`
+
+	// Headers are sent with each request, as "Name=value" pairs separated by semicolons,
+	// e.g. "User-Agent=supmap-gis/1.0;Accept=application/json, text/plain". Values may contain
+	// commas, colons and equal signs, but not semicolons.
+	Headers map[string]string `env:"HEADERS" envSeparator:";" envKeyValSeparator:"="`
+}
+
+// BaseURL returns the base URL of the upstream, without trailing slash.
+func (u Upstream) BaseURL() string {
+	if u.URL != "" {
+		return strings.TrimSuffix(u.URL, "/")
+	}
+	return fmt.Sprintf("http://%s:%s", u.Host, u.Port)
+}
+
+// Configured reports whether the location of the upstream is set.
+func (u Upstream) Configured() bool {
+	return u.URL != "" || u.Host != ""
+}
+
+// validate checks the configuration of the upstream read from the variables prefixed by prefix.
+func (u Upstream) validate(prefix string) error {
+	if u.URL != "" {
+		parsed, err := url.Parse(u.URL)
+		if err != nil {
+			return fmt.Errorf("%sURL is invalid: %w", prefix, err)
+		}
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			return fmt.Errorf("%sURL must be an http or https URL, got %q", prefix, u.URL)
+		}
+		if parsed.Host == "" {
+			return fmt.Errorf("%sURL must contain a host, got %q", prefix, u.URL)
+		}
+		if parsed.RawQuery != "" || parsed.Fragment != "" {
+			return fmt.Errorf("%sURL must not contain a query or a fragment, got %q", prefix, u.URL)
+		}
+	} else {
+		if u.Host == "" {
+			return fmt.Errorf("%sURL or %sHOST is required", prefix, prefix)
+		}
+		if u.Port == "" {
+			return fmt.Errorf("%sPORT is required when %sURL isn't set", prefix, prefix)
+		}
+	}
+
+	if u.Timeout <= 0 {
+		return fmt.Errorf("%sTIMEOUT must be positive, got %s", prefix, u.Timeout)
+	}
+	if u.MaxIdleConns < 0 {
+		return fmt.Errorf("%sMAX_IDLE_CONNS must not be negative, got %d", prefix, u.MaxIdleConns)
+	}
+
+	if (u.TLSCertFile == "") != (u.TLSKeyFile == "") {
+		return fmt.Errorf("%sTLS_CERT_FILE and %sTLS_KEY_FILE must be set together", prefix, prefix)
+	}
+	for _, file := range []struct{ name, path string }{
+		{"TLS_CA_FILE", u.TLSCAFile},
+		{"TLS_CERT_FILE", u.TLSCertFile},
+		{"TLS_KEY_FILE", u.TLSKeyFile},
+	} {
+		if file.path == "" {
+			continue
+		}
+		if _, err := os.Stat(file.path); err != nil {
+			return fmt.Errorf("%s%s is invalid: %w", prefix, file.name, err)
+		}
+	}
+
+	for name := range u.Headers {
+		if name == "" || strings.ContainsAny(name, " \t\r\n") {
+			return fmt.Errorf("%sHEADERS contains an invalid header name %q", prefix, name)
+		}
+	}
+	return nil
+}
+
+// validateUpstreams checks the configuration of the required upstreams and of the optional
+// ones which are configured.
+func validateUpstreams(cfg *Config) error {
+	upstreams := []struct {
+		prefix   string
+		upstream Upstream
+		required bool
+	}{
+		{"NOMINATIM_", cfg.Nominatim, true},
+		{"VALHALLA_", cfg.Valhalla, true},
+		{"SUPMAP_INCIDENTS_", cfg.SupmapIncidents, true},
+		{"PHOTON_", cfg.Photon, false},
+		{"PELIAS_", cfg.Pelias, false},
+	}
+
+	var errs []error
+	for _, u := range upstreams {
+		if !u.required && !u.upstream.Configured() {
+			continue
+		}
+		if err := u.upstream.validate(u.prefix); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
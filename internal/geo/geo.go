@@ -0,0 +1,19 @@
+// Package geo provides the geometry helpers shared by the services and the providers.
+package geo
+
+import "math"
+
+// earthRadius is the mean radius of the Earth, in meters.
+const earthRadius = 6371000
+
+// Haversine returns the great-circle distance in meters between two coordinates.
+func Haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return earthRadius * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
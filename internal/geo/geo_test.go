@@ -0,0 +1,29 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHaversine(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		{"same point", 49.18, -0.37, 49.18, -0.37, 0},
+		{"one degree of latitude", 49, 0, 50, 0, 111195},
+		{"Caen to Paris", 49.1829, -0.3707, 48.8566, 2.3522, 201000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Haversine(tt.lat1, tt.lon1, tt.lat2, tt.lon2)
+			if math.Abs(got-tt.want) > tt.want*0.01+1 {
+				t.Errorf("Haversine() = %.0f m, want about %.0f m", got, tt.want)
+			}
+			if back := Haversine(tt.lat2, tt.lon2, tt.lat1, tt.lon1); back != got {
+				t.Errorf("Haversine() isn't symmetric: %v and %v", got, back)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Package lru provides a size-bounded in-memory cache, shared by the caches of the services and
+// of the providers.
+package lru
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cache is a size-bounded cache with a TTL per entry, evicting the least recently used entries.
+// It's safe for concurrent use.
+type Cache struct {
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// lru holds the cache entries, from the most to the least recently used.
+	lru *list.List
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// New returns a cache of at most size entries. A cache of size 0 stores nothing.
+func New(size int) *Cache {
+	return &Cache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+type entry struct {
+	key       string
+	value     any
+	expiresAt time.Time
+}
+
+// Stats are the metrics of a cache.
+type Stats struct {
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Entries int    `json:"entries"`
+}
+
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	entries := c.lru.Len()
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Entries: entries,
+	}
+}
+
+// Get returns the value of key and its remaining TTL, if it is cached and not expired.
+func (c *Cache) Get(key string) (any, time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, 0, false
+	}
+
+	e := element.Value.(*entry)
+	ttl := time.Until(e.expiresAt)
+	if ttl <= 0 {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+		c.misses.Add(1)
+		return nil, 0, false
+	}
+
+	c.lru.MoveToFront(element)
+	c.hits.Add(1)
+	return e.value, ttl, true
+}
+
+// Set caches value for ttl, evicting the least recently used entry if the cache is full.
+func (c *Cache) Set(key string, value any, ttl time.Duration) {
+	if ttl <= 0 || c.size <= 0 {
+		return
+	}
+	expiresAt := time.Now().Add(ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		e := element.Value.(*entry)
+		e.value, e.expiresAt = value, expiresAt
+		c.lru.MoveToFront(element)
+		return
+	}
+
+	for c.lru.Len() >= c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).key)
+	}
+
+	c.entries[key] = c.lru.PushFront(&entry{key: key, value: value, expiresAt: expiresAt})
+}
@@ -0,0 +1,47 @@
+package lru
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	c := New(2)
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Minute)
+
+	// Reading a makes b the least recently used entry, evicted by c.
+	if v, ttl, ok := c.Get("a"); !ok || v != 1 || ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("Get(a) = %v, %s, %v", v, ttl, ok)
+	}
+	c.Set("c", 3, time.Minute)
+	if _, _, ok := c.Get("b"); ok {
+		t.Error("b not evicted")
+	}
+	if v, _, ok := c.Get("c"); !ok || v != 3 {
+		t.Errorf("Get(c) = %v, %v", v, ok)
+	}
+
+	// Expired entries are misses, and a zero TTL stores nothing.
+	c.Set("a", 1, time.Nanosecond)
+	c.Set("d", 4, 0)
+	time.Sleep(time.Millisecond)
+	if _, _, ok := c.Get("a"); ok {
+		t.Error("expired a returned")
+	}
+	if _, _, ok := c.Get("d"); ok {
+		t.Error("d stored without TTL")
+	}
+
+	if got, want := c.Stats(), (Stats{Hits: 2, Misses: 3, Entries: 1}); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCacheWithoutSize(t *testing.T) {
+	c := New(0)
+	c.Set("a", 1, time.Minute)
+	if _, _, ok := c.Get("a"); ok {
+		t.Error("cache of size 0 stored a")
+	}
+}
@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"supmap-gis/internal/lru"
+	"supmap-gis/internal/providers/transport"
+	"testing"
+)
+
+// scrape returns the metrics exposed by m.
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+	return string(body)
+}
+
+func TestMiddlewareRouteLabels(t *testing.T) {
+	m := New()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /places/{osm_type}/{osm_id}", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/docs/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	h := m.Middleware(mux)
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodGet, "/places/node/42"},
+		{http.MethodGet, "/places/way/7"},
+		{http.MethodPost, "/docs/index.html"},
+		{http.MethodGet, "/unknown/1"},
+		{http.MethodGet, "/unknown/2"},
+		// The route exists, but not for this method.
+		{http.MethodDelete, "/places/node/42"},
+	} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	metrics := scrape(t, m)
+	for _, want := range []string{
+		// Path values and the method of the pattern aren't part of the route label.
+		`supmap_gis_http_requests_total{method="GET",route="/places/{osm_type}/{osm_id}",status="200"} 2`,
+		`supmap_gis_http_requests_total{method="POST",route="/docs/",status="418"} 1`,
+		`supmap_gis_http_requests_total{method="GET",route="unmatched",status="404"} 2`,
+		`supmap_gis_http_requests_total{method="DELETE",route="unmatched",status="405"} 1`,
+		`supmap_gis_http_request_duration_seconds_count{method="GET",route="/places/{osm_type}/{osm_id}",status="200"} 2`,
+		"supmap_gis_http_requests_in_flight 0",
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics don't contain %q", want)
+		}
+	}
+	if strings.Contains(metrics, "/places/node/42") || strings.Contains(metrics, "/unknown") {
+		t.Error("request paths are used as labels")
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ net.Error = timeoutError{}
+
+func TestFailureReason(t *testing.T) {
+	status := func(code int) *http.Response {
+		return &http.Response{StatusCode: code}
+	}
+
+	tests := []struct {
+		name string
+		resp *http.Response
+		err  error
+		want string
+	}{
+		{"success", status(http.StatusOK), nil, ""},
+		// Client errors are the caller's, not the upstream's.
+		{"not found", status(http.StatusNotFound), nil, ""},
+		{"server error", status(http.StatusBadGateway), nil, "status_5xx"},
+		{"circuit open", nil, fmt.Errorf("valhalla: %w", transport.ErrCircuitOpen), "circuit_open"},
+		{"canceled", nil, fmt.Errorf("get: %w", context.Canceled), "canceled"},
+		{"deadline", nil, fmt.Errorf("get: %w", context.DeadlineExceeded), "timeout"},
+		{"network timeout", nil, &net.OpError{Op: "dial", Err: timeoutError{}}, "timeout"},
+		{"connection refused", nil, &net.OpError{Op: "dial", Err: errors.New("connection refused")}, "connection"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failureReason(tt.resp, tt.err); got != tt.want {
+				t.Errorf("failureReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstrumentUpstream(t *testing.T) {
+	m := New()
+	responses := []struct {
+		resp *http.Response
+		err  error
+	}{
+		{&http.Response{StatusCode: http.StatusOK}, nil},
+		{&http.Response{StatusCode: http.StatusServiceUnavailable}, nil},
+		{nil, transport.ErrCircuitOpen},
+	}
+	next := roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		r := responses[0]
+		responses = responses[1:]
+		return r.resp, r.err
+	})
+	rt := m.InstrumentUpstream("valhalla", next)
+	for range 3 {
+		rt.RoundTrip(httptest.NewRequest(http.MethodGet, "http://valhalla/route", nil))
+	}
+
+	metrics := scrape(t, m)
+	for _, want := range []string{
+		`supmap_gis_upstream_request_duration_seconds_count{outcome="success",upstream="valhalla"} 1`,
+		`supmap_gis_upstream_request_duration_seconds_count{outcome="error",upstream="valhalla"} 2`,
+		`supmap_gis_upstream_errors_total{reason="status_5xx",upstream="valhalla"} 1`,
+		`supmap_gis_upstream_errors_total{reason="circuit_open",upstream="valhalla"} 1`,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics don't contain %q", want)
+		}
+	}
+}
+
+func TestRegisterCache(t *testing.T) {
+	m := New()
+	geocoding := lru.Stats{Hits: 3, Misses: 2, Entries: 1}
+	m.RegisterCache("geocoding", func() lru.Stats { return geocoding })
+	m.RegisterCache("route", func() lru.Stats { return lru.Stats{Misses: 1} })
+
+	// The statistics are read at each scrape.
+	geocoding.Hits = 5
+	metrics := scrape(t, m)
+	for _, want := range []string{
+		`supmap_gis_cache_hits_total{cache="geocoding"} 5`,
+		`supmap_gis_cache_misses_total{cache="geocoding"} 2`,
+		`supmap_gis_cache_entries{cache="geocoding"} 1`,
+		`supmap_gis_cache_hits_total{cache="route"} 0`,
+		`supmap_gis_cache_misses_total{cache="route"} 1`,
+		"# TYPE supmap_gis_cache_hits_total counter",
+		"# TYPE supmap_gis_cache_entries gauge",
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics don't contain %q", want)
+		}
+	}
+
+	// A cache can't be registered twice.
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a cache twice didn't panic")
+		}
+	}()
+	m.RegisterCache("route", func() lru.Stats { return lru.Stats{} })
+}
@@ -0,0 +1,209 @@
+package gazetteer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"supmap-gis/internal/geo"
+	"supmap-gis/internal/words"
+)
+
+// Load reads a gazetteer from a CSV file with a header row, or from a GeoJSON file of points.
+// The columns, or the properties of the features, are the JSON names of the fields of [Entry].
+// In a CSV file, the name, lat and lon columns are required.
+func Load(path string) (*Gazetteer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gazetteer file: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		entries, err = readCSV(f)
+	case ".geojson", ".json":
+		entries, err = readGeoJSON(f)
+	default:
+		return nil, fmt.Errorf("unsupported gazetteer file extension %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gazetteer file: %w", err)
+	}
+
+	for i, entry := range entries {
+		if entry.Name == "" {
+			return nil, fmt.Errorf("entry %d: missing name", i+1)
+		}
+	}
+
+	return New(entries), nil
+}
+
+func New(entries []Entry) *Gazetteer {
+	g := &Gazetteer{
+		entries:   entries,
+		nameWords: make([][]string, len(entries)),
+		words:     make([][]string, len(entries)),
+	}
+	for i, entry := range entries {
+		g.nameWords[i] = words.Split(entry.Name)
+		g.words[i] = words.Split(strings.Join([]string{
+			entry.Name, entry.HouseNumber, entry.Street, entry.Postcode, entry.City, entry.County, entry.State, entry.Country,
+		}, " "))
+	}
+	return g
+}
+
+func readCSV(r io.Reader) ([]Entry, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"name", "lat", "lon"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %q column", required)
+		}
+	}
+
+	var entries []Entry
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		get := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		lat, errLat := strconv.ParseFloat(get("lat"), 64)
+		lon, errLon := strconv.ParseFloat(get("lon"), 64)
+		if errLat != nil || errLon != nil {
+			return nil, fmt.Errorf("line %d: invalid coordinates", line)
+		}
+
+		entries = append(entries, Entry{
+			Name:        get("name"),
+			Type:        get("type"),
+			Lat:         lat,
+			Lon:         lon,
+			HouseNumber: get("housenumber"),
+			Street:      get("street"),
+			Postcode:    get("postcode"),
+			City:        get("city"),
+			County:      get("county"),
+			State:       get("state"),
+			Country:     get("country"),
+			CountryCode: get("country_code"),
+		})
+	}
+}
+
+func readGeoJSON(r io.Reader) ([]Entry, error) {
+	var collection struct {
+		Features []struct {
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties Entry `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.NewDecoder(r).Decode(&collection); err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, len(collection.Features))
+	for i, feature := range collection.Features {
+		// The coordinates of other geometries aren't a single position, they are only decoded
+		// once the geometry is known to be a point.
+		var coordinates []float64
+		if feature.Geometry.Type != "Point" ||
+			json.Unmarshal(feature.Geometry.Coordinates, &coordinates) != nil || len(coordinates) < 2 {
+			return nil, fmt.Errorf("feature %d: geometry must be a point", i)
+		}
+		entries[i] = feature.Properties
+		entries[i].Lon, entries[i].Lat = coordinates[0], coordinates[1]
+	}
+	return entries, nil
+}
+
+// Search returns at most limit entries matching query: each word of the query must be the prefix
+// of a word of the name or of the address of the entry. Entries whose name matches more words
+// of the query come first.
+func (g *Gazetteer) Search(query string, limit int) []Entry {
+	queryWords := words.Split(query)
+	if len(queryWords) == 0 {
+		return []Entry{}
+	}
+
+	type match struct {
+		index int
+		score int
+	}
+	var matches []match
+	for i := range g.entries {
+		if !words.MatchAll(g.words[i], queryWords) {
+			continue
+		}
+		score := 0
+		for _, q := range queryWords {
+			if words.MatchAll(g.nameWords[i], []string{q}) {
+				score++
+			}
+		}
+		if len(g.nameWords[i]) == len(queryWords) && score == len(queryWords) {
+			// Exact name matches come first.
+			score += len(queryWords)
+		}
+		matches = append(matches, match{index: i, score: score})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	entries := make([]Entry, len(matches))
+	for i, m := range matches {
+		entries[i] = g.entries[m.index]
+	}
+	return entries
+}
+
+// Nearest returns the entry closest to the given coordinates, or nil if there is none within
+// maxDistance meters. Entries are scanned linearly, which is fine for gazetteers of a few
+// thousands of places.
+func (g *Gazetteer) Nearest(lat, lon, maxDistance float64) *Entry {
+	var nearest *Entry
+	nearestDistance := maxDistance
+	for i := range g.entries {
+		if d := geo.Haversine(lat, lon, g.entries[i].Lat, g.entries[i].Lon); d <= nearestDistance {
+			nearest, nearestDistance = &g.entries[i], d
+		}
+	}
+	return nearest
+}
@@ -0,0 +1,164 @@
+package gazetteer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []Entry
+		wantErr string
+	}{
+		{
+			name: "csv",
+			file: "places.csv",
+			content: "Name, lat, lon, city, country_code\n" +
+				"Gare de Caen, 49.1767, -0.3481, Caen, fr\n" +
+				"\"Hôtel de Ville, Caen\", 49.1829, -0.3707, , \n",
+			want: []Entry{
+				{Name: "Gare de Caen", Lat: 49.1767, Lon: -0.3481, City: "Caen", CountryCode: "fr"},
+				{Name: "Hôtel de Ville, Caen", Lat: 49.1829, Lon: -0.3707},
+			},
+		},
+		{
+			name: "geojson",
+			file: "places.geojson",
+			content: `{"type": "FeatureCollection", "features": [
+				{"type": "Feature", "geometry": {"type": "Point", "coordinates": [-0.3481, 49.1767]}, "properties": {"name": "Gare de Caen", "type": "station"}}
+			]}`,
+			want: []Entry{{Name: "Gare de Caen", Type: "station", Lat: 49.1767, Lon: -0.3481}},
+		},
+		{name: "unsupported extension", file: "places.txt", content: "", wantErr: `unsupported gazetteer file extension ".txt"`},
+		{name: "empty csv", file: "places.csv", content: "", wantErr: "failed to read header"},
+		{name: "missing column", file: "places.csv", content: "name,lat\nCaen,49.18\n", wantErr: `missing "lon" column`},
+		{name: "invalid coordinates", file: "places.csv", content: "name,lat,lon\nCaen,49.18,-0.37\nRouen,49.44,east\n", wantErr: "line 3: invalid coordinates"},
+		{name: "missing coordinates", file: "places.csv", content: "name,lat,lon\nCaen,49.18\n", wantErr: "wrong number of fields"},
+		{name: "csv missing name", file: "places.csv", content: "name,lat,lon\nCaen,49.18,-0.37\n,49.44,1.09\n", wantErr: "entry 2: missing name"},
+		{name: "invalid json", file: "places.json", content: `{"features": [`, wantErr: "failed to read gazetteer file"},
+		{
+			name:    "not a point",
+			file:    "places.geojson",
+			content: `{"features": [{"geometry": {"type": "LineString", "coordinates": [[-0.37, 49.18], [1.09, 49.44]]}, "properties": {"name": "A13"}}]}`,
+			wantErr: "feature 0: geometry must be a point",
+		},
+		{
+			name:    "geojson missing name",
+			file:    "places.geojson",
+			content: `{"features": [{"geometry": {"type": "Point", "coordinates": [-0.37, 49.18]}, "properties": {}}]}`,
+			wantErr: "entry 1: missing name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := Load(writeFile(t, tt.file, tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if len(g.entries) != len(tt.want) {
+				t.Fatalf("entries = %+v, want %+v", g.entries, tt.want)
+			}
+			for i := range tt.want {
+				if g.entries[i] != tt.want[i] {
+					t.Errorf("entry %d = %+v, want %+v", i, g.entries[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.csv")); err == nil || !strings.Contains(err.Error(), "failed to open") {
+		t.Errorf("Load() of a missing file error = %v", err)
+	}
+}
+
+var testEntries = []Entry{
+	{Name: "Rue de Caen", Street: "Rue de Caen", City: "Rouen", Lat: 49.4431, Lon: 1.0993},
+	{Name: "Gare de Caen", City: "Caen", Lat: 49.1767, Lon: -0.3481},
+	{Name: "Caen", City: "Caen", Lat: 49.1829, Lon: -0.3707},
+	{Name: "Hôtel de Ville", Postcode: "14000", City: "Caen", Lat: 49.1829, Lon: -0.3707},
+}
+
+func names(entries []Entry) []string {
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name
+	}
+	return names
+}
+
+func TestSearch(t *testing.T) {
+	g := New(testEntries)
+
+	tests := []struct {
+		query string
+		limit int
+		want  []string
+	}{
+		// The exact name match comes first, then names matching the query, then addresses.
+		{"caen", 0, []string{"Caen", "Rue de Caen", "Gare de Caen", "Hôtel de Ville"}},
+		{"caen", 2, []string{"Caen", "Rue de Caen"}},
+		{"GARE ca", 0, []string{"Gare de Caen"}},
+		// Accents are not ignored.
+		{"hotel de ville 14000", 0, nil},
+		{"hôtel de ville 140", 0, []string{"Hôtel de Ville"}},
+		{"rouen", 0, []string{"Rue de Caen"}},
+		{"paris", 0, nil},
+		{"  -  ", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got := names(g.Search(tt.query, tt.limit))
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("Search(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNearest(t *testing.T) {
+	g := New(testEntries)
+
+	tests := []struct {
+		name        string
+		lat, lon    float64
+		maxDistance float64
+		want        string // "" if no entry is found
+	}{
+		{"same position", 49.1767, -0.3481, 0, "Gare de Caen"},
+		// About 25 m from the station.
+		{"near the station", 49.1769, -0.3479, 50, "Gare de Caen"},
+		{"too far", 49.1769, -0.3479, 20, ""},
+		{"no maximum distance", 48.86, 2.35, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if entry := g.Nearest(tt.lat, tt.lon, tt.maxDistance); entry != nil {
+				got = entry.Name
+			}
+			if got != tt.want {
+				t.Errorf("Nearest() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package gazetteer
+
+// Entry is a place of the gazetteer.
+type Entry struct {
+	Name        string  `json:"name"`
+	Type        string  `json:"type"`
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
+	HouseNumber string  `json:"housenumber"`
+	Street      string  `json:"street"`
+	Postcode    string  `json:"postcode"`
+	City        string  `json:"city"`
+	County      string  `json:"county"`
+	State       string  `json:"state"`
+	Country     string  `json:"country"`
+	CountryCode string  `json:"country_code"`
+}
+
+// Gazetteer is an in-memory list of places, searched by name and by position.
+type Gazetteer struct {
+	entries []Entry
+	// nameWords and words are the normalized words of the name of each entry,
+	// and of its name and address.
+	nameWords [][]string
+	words     [][]string
+}
@@ -7,21 +7,31 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"supmap-gis/internal/providers/transport"
 	"time"
 )
 
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	// probeClient is used by Status.
+	probeClient *http.Client
 }
 
 type ClientOptions struct {
+	// Timeout bounds each call, retries included.
 	Timeout time.Duration
+	// HTTP configures the connections to the upstream.
+	HTTP transport.HTTPOptions
+	// Resilience configures the retries and the circuit breaker of the client.
+	Resilience transport.Options
 }
 
 func DefaultClientOptions() ClientOptions {
 	return ClientOptions{
-		Timeout: 7 * time.Second,
+		Timeout:    7 * time.Second,
+		Resilience: transport.DefaultOptions(),
 	}
 }
 
@@ -32,19 +42,55 @@ func NewClient(baseURL string, options ...ClientOptions) *Client {
 	}
 
 	return &Client{
-		baseURL:    baseURL,
-		httpClient: &http.Client{Timeout: opts.Timeout},
+		baseURL:     baseURL,
+		httpClient:  transport.NewHTTPClient("nominatim", opts.Timeout, opts.HTTP, opts.Resilience),
+		probeClient: transport.NewProbeClient("nominatim", opts.Timeout, opts.HTTP),
 	}
 }
 
-func (c *Client) Search(ctx context.Context, address string) ([]GeocodeResult, error) {
+// Search geocodes a free-form or structured address.
+func (c *Client) Search(ctx context.Context, params SearchParams) ([]GeocodeResult, error) {
 	reqURL, err := url.Parse(c.baseURL + "/search")
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse URL: %w", err)
 	}
 
 	query := reqURL.Query()
-	query.Set("q", address)
+	if params.IsStructured() {
+		setIfNotEmpty(query, "amenity", params.Amenity)
+		setIfNotEmpty(query, "street", params.Street)
+		setIfNotEmpty(query, "city", params.City)
+		setIfNotEmpty(query, "county", params.County)
+		setIfNotEmpty(query, "state", params.State)
+		setIfNotEmpty(query, "postalcode", params.PostalCode)
+		setIfNotEmpty(query, "country", params.Country)
+	} else {
+		query.Set("q", params.Query)
+	}
+	setIfNotEmpty(query, "countrycodes", strings.Join(params.CountryCodes, ","))
+	if params.ViewBox != nil {
+		query.Set("viewbox", fmt.Sprintf("%s,%s,%s,%s",
+			strconv.FormatFloat(params.ViewBox.MinLon, 'g', -1, 64),
+			strconv.FormatFloat(params.ViewBox.MinLat, 'g', -1, 64),
+			strconv.FormatFloat(params.ViewBox.MaxLon, 'g', -1, 64),
+			strconv.FormatFloat(params.ViewBox.MaxLat, 'g', -1, 64),
+		))
+		if params.Bounded {
+			query.Set("bounded", "1")
+		}
+	}
+	if params.Limit > 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	setIfNotEmpty(query, "layer", strings.Join(params.Layers, ","))
+	setIfNotEmpty(query, "featureType", params.FeatureType)
+	if params.Dedupe != nil {
+		if *params.Dedupe {
+			query.Set("dedupe", "1")
+		} else {
+			query.Set("dedupe", "0")
+		}
+	}
 	reqURL.RawQuery = query.Encode()
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL.String(), nil)
@@ -53,7 +99,7 @@ func (c *Client) Search(ctx context.Context, address string) ([]GeocodeResult, e
 	}
 
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Accept-Language", "fr-FR")
+	req.Header.Set("Accept-Language", params.Language)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -73,7 +119,8 @@ func (c *Client) Search(ctx context.Context, address string) ([]GeocodeResult, e
 	return result, nil
 }
 
-func (c *Client) Reverse(ctx context.Context, lat, lon float64) (*ReverseResult, error) {
+// Reverse finds the address at the given coordinates, with its details.
+func (c *Client) Reverse(ctx context.Context, params ReverseParams) (*ReverseResult, error) {
 	reqURL, err := url.Parse(c.baseURL + "/reverse")
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse URL: %w", err)
@@ -81,8 +128,12 @@ func (c *Client) Reverse(ctx context.Context, lat, lon float64) (*ReverseResult,
 
 	query := reqURL.Query()
 	query.Set("format", "geojson")
-	query.Set("lat", strconv.FormatFloat(lat, 'g', -1, 64))
-	query.Set("lon", strconv.FormatFloat(lon, 'g', -1, 64))
+	query.Set("addressdetails", "1")
+	query.Set("lat", strconv.FormatFloat(params.Lat, 'g', -1, 64))
+	query.Set("lon", strconv.FormatFloat(params.Lon, 'g', -1, 64))
+	if params.Zoom > 0 {
+		query.Set("zoom", strconv.Itoa(params.Zoom))
+	}
 	reqURL.RawQuery = query.Encode()
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL.String(), nil)
@@ -90,6 +141,8 @@ func (c *Client) Reverse(ctx context.Context, lat, lon float64) (*ReverseResult,
 		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
 
+	req.Header.Set("Accept-Language", params.Language)
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
@@ -107,3 +160,128 @@ func (c *Client) Reverse(ctx context.Context, lat, lon float64) (*ReverseResult,
 
 	return &result, nil
 }
+
+// Lookup returns the OSM object identified by params, with its address, extra tags and geometry.
+// The result is empty if the object doesn't exist.
+func (c *Client) Lookup(ctx context.Context, params LookupParams) ([]GeocodeResult, error) {
+	reqURL, err := url.Parse(c.baseURL + "/lookup")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	query := reqURL.Query()
+	query.Set("format", "jsonv2")
+	query.Set("osm_ids", params.OSMType+strconv.FormatInt(params.OSMID, 10))
+	query.Set("addressdetails", "1")
+	query.Set("extratags", "1")
+	query.Set("polygon_geojson", "1")
+	// Simplifies the geometry of large objects, such as countries.
+	query.Set("polygon_threshold", "0.0001")
+	reqURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Accept-Language", params.Language)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var result []GeocodeResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return result, nil
+}
+
+// Details returns the details of the OSM object identified by params, or nil if it doesn't exist.
+func (c *Client) Details(ctx context.Context, params LookupParams) (*DetailsResult, error) {
+	reqURL, err := url.Parse(c.baseURL + "/details")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	query := reqURL.Query()
+	query.Set("format", "json")
+	query.Set("osmtype", params.OSMType)
+	query.Set("osmid", strconv.FormatInt(params.OSMID, 10))
+	query.Set("extratags", "1")
+	reqURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Accept-Language", params.Language)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var result DetailsResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// Status checks that Nominatim is up and its database is reachable.
+func (c *Client) Status(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/status?format=json", nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+
+	resp, err := c.probeClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Nominatim answers 500 with a status and a message when it isn't working.
+	var status StatusResult
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil && resp.StatusCode == http.StatusOK {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		statusErr := &transport.StatusError{StatusCode: resp.StatusCode}
+		if status.Message == "" {
+			return statusErr
+		}
+		return fmt.Errorf("%w: %s", statusErr, status.Message)
+	}
+	if status.Status != 0 {
+		return fmt.Errorf("unexpected status %d: %s", status.Status, status.Message)
+	}
+
+	return nil
+}
+
+func setIfNotEmpty(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
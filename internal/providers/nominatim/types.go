@@ -1,5 +1,51 @@
 package nominatim
 
+import "encoding/json"
+
+// SearchParams are the parameters of a search. Either Query or the structured fields
+// (Amenity, Street, City, County, State, PostalCode, Country) must be set, but not both.
+type SearchParams struct {
+	Query string
+
+	// Amenity is the name or the type of a POI, e.g. "fuel".
+	Amenity    string
+	Street     string
+	City       string
+	County     string
+	State      string
+	PostalCode string
+	Country    string
+
+	// CountryCodes restricts results to the given ISO 3166-1 alpha-2 country codes.
+	CountryCodes []string
+	// ViewBox focuses the search on an area. Results outside of it are only excluded if Bounded is true.
+	ViewBox *ViewBox
+	Bounded bool
+	// Limit is the maximum number of results, 10 by default and at most 40.
+	Limit int
+	// Layers restricts results to the given themes: "address", "poi", "railway", "natural" or "manmade".
+	Layers []string
+	// FeatureType restricts results to a type of place: "country", "state", "city" or "settlement".
+	FeatureType string
+	// Dedupe removes results that refer to the same place. Nominatim enables it by default.
+	Dedupe *bool
+	// Language is a BCP 47 language tag used to localize results.
+	Language string
+}
+
+// IsStructured reports whether the search uses structured fields instead of a free-form query.
+func (p SearchParams) IsStructured() bool {
+	return p.Amenity != "" || p.Street != "" || p.City != "" || p.County != "" || p.State != "" || p.PostalCode != "" || p.Country != ""
+}
+
+// ViewBox is a rectangle delimited by two corners, in decimal degrees.
+type ViewBox struct {
+	MinLon float64
+	MinLat float64
+	MaxLon float64
+	MaxLat float64
+}
+
 type GeocodeResult struct {
 	PlaceID     int64    `json:"place_id"`
 	Licence     string   `json:"licence"`
@@ -15,12 +61,119 @@ type GeocodeResult struct {
 	Name        string   `json:"name"`
 	DisplayName string   `json:"display_name"`
 	BoundingBox []string `json:"boundingbox"`
+
+	// Address, ExtraTags and GeoJSON are only set by Lookup.
+	Address   *Address  `json:"address,omitempty"`
+	ExtraTags Tags      `json:"extratags,omitempty"`
+	GeoJSON   *Geometry `json:"geojson,omitempty"`
+}
+
+// Geometry is a GeoJSON geometry.
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// Tags are OSM tags. Nominatim encodes empty tags as an empty array, which is decoded as nil.
+type Tags map[string]string
+
+func (t *Tags) UnmarshalJSON(data []byte) error {
+	if string(data) == "[]" {
+		*t = nil
+		return nil
+	}
+	var tags map[string]string
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return err
+	}
+	*t = tags
+	return nil
 }
 
+// LookupParams identify an OSM object.
+type LookupParams struct {
+	// OSMType is "N" (node), "W" (way) or "R" (relation).
+	OSMType string
+	OSMID   int64
+	// Language is a BCP 47 language tag used to localize results.
+	Language string
+}
+
+// DetailsResult contains the details of a place that Lookup doesn't return.
+type DetailsResult struct {
+	PlaceID    int64  `json:"place_id"`
+	OSMType    string `json:"osm_type"`
+	OSMID      int64  `json:"osm_id"`
+	Category   string `json:"category"`
+	Type       string `json:"type"`
+	AdminLevel int    `json:"admin_level"`
+	LocalName  string `json:"localname"`
+	// Names contains all the names of the place, e.g. "name:en" or "alt_name".
+	Names               Tags   `json:"names"`
+	ExtraTags           Tags   `json:"extratags"`
+	CalculatedWikipedia string `json:"calculated_wikipedia"`
+	// IndexedDate is the date of the last update of the place in the database.
+	IndexedDate string `json:"indexed_date"`
+}
+
+// ReverseParams are the parameters of a reverse geocoding.
+type ReverseParams struct {
+	Lat float64
+	Lon float64
+	// Zoom is the level of detail of the address, from 0 (country) to 18 (building). 18 by default.
+	Zoom int
+	// Language is a BCP 47 language tag used to localize results.
+	Language string
+}
+
+// ReverseResult is a GeoJSON feature collection, containing at most one feature.
 type ReverseResult struct {
-	Features []struct {
-		Properties struct {
-			DisplayName string `json:"display_name"`
-		} `json:"properties"`
-	} `json:"features"`
+	Features []ReverseFeature `json:"features"`
+}
+
+type ReverseFeature struct {
+	Properties ReverseProperties `json:"properties"`
+	// BoundingBox is [minLon, minLat, maxLon, maxLat].
+	BoundingBox []float64 `json:"bbox"`
+	Geometry    struct {
+		Type string `json:"type"`
+		// Coordinates is [lon, lat] for points.
+		Coordinates []float64 `json:"coordinates"`
+	} `json:"geometry"`
+}
+
+type ReverseProperties struct {
+	PlaceID     int64   `json:"place_id"`
+	OSMType     string  `json:"osm_type"`
+	OSMID       int64   `json:"osm_id"`
+	PlaceRank   uint8   `json:"place_rank"`
+	Category    string  `json:"category"`
+	Type        string  `json:"type"`
+	Importance  float64 `json:"importance"`
+	AddressType string  `json:"addresstype"`
+	Name        string  `json:"name"`
+	DisplayName string  `json:"display_name"`
+	Address     Address `json:"address"`
+}
+
+// Address contains the address details of a place. Only the relevant fields are set.
+type Address struct {
+	HouseNumber  string `json:"house_number,omitempty"`
+	Road         string `json:"road,omitempty"`
+	Suburb       string `json:"suburb,omitempty"`
+	Village      string `json:"village,omitempty"`
+	Town         string `json:"town,omitempty"`
+	City         string `json:"city,omitempty"`
+	Municipality string `json:"municipality,omitempty"`
+	County       string `json:"county,omitempty"`
+	State        string `json:"state,omitempty"`
+	Postcode     string `json:"postcode,omitempty"`
+	Country      string `json:"country,omitempty"`
+	CountryCode  string `json:"country_code,omitempty"`
+}
+
+// StatusResult is the response of the /status endpoint. Status is 0 when Nominatim is working.
+type StatusResult struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
 }
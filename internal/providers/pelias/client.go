@@ -0,0 +1,143 @@
+package pelias
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"supmap-gis/internal/providers/transport"
+	"time"
+)
+
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+type ClientOptions struct {
+	// Timeout bounds each call, retries included.
+	Timeout time.Duration
+	// HTTP configures the connections to the upstream.
+	HTTP transport.HTTPOptions
+	// Resilience configures the retries and the circuit breaker of the client.
+	Resilience transport.Options
+	// APIKey is sent with each request if set, as required by hosted Pelias instances.
+	APIKey string
+}
+
+func DefaultClientOptions() ClientOptions {
+	return ClientOptions{
+		Timeout:    7 * time.Second,
+		Resilience: transport.DefaultOptions(),
+	}
+}
+
+func NewClient(baseURL string, options ...ClientOptions) *Client {
+	opts := DefaultClientOptions()
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	return &Client{
+		baseURL:    baseURL,
+		apiKey:     opts.APIKey,
+		httpClient: transport.NewHTTPClient("pelias", opts.Timeout, opts.HTTP, opts.Resilience),
+	}
+}
+
+// Search calls the Pelias search API, or the structured search API if params are structured.
+func (c *Client) Search(ctx context.Context, params SearchParams) (*FeatureCollection, error) {
+	path := "/v1/search"
+	if params.IsStructured() {
+		path = "/v1/search/structured"
+	}
+	reqURL, err := url.Parse(c.baseURL + path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	query := reqURL.Query()
+	if params.IsStructured() {
+		setIfNotEmpty(query, "address", params.Address)
+		setIfNotEmpty(query, "locality", params.Locality)
+		setIfNotEmpty(query, "county", params.County)
+		setIfNotEmpty(query, "region", params.Region)
+		setIfNotEmpty(query, "postalcode", params.PostalCode)
+		setIfNotEmpty(query, "country", params.Country)
+	} else {
+		query.Set("text", params.Text)
+	}
+	if params.Size > 0 {
+		query.Set("size", strconv.Itoa(params.Size))
+	}
+	setIfNotEmpty(query, "boundary.country", strings.Join(params.Countries, ","))
+	if len(params.BoundingBox) == 4 {
+		query.Set("boundary.rect.min_lon", strconv.FormatFloat(params.BoundingBox[0], 'g', -1, 64))
+		query.Set("boundary.rect.min_lat", strconv.FormatFloat(params.BoundingBox[1], 'g', -1, 64))
+		query.Set("boundary.rect.max_lon", strconv.FormatFloat(params.BoundingBox[2], 'g', -1, 64))
+		query.Set("boundary.rect.max_lat", strconv.FormatFloat(params.BoundingBox[3], 'g', -1, 64))
+	}
+	if len(params.Focus) == 2 {
+		query.Set("focus.point.lon", strconv.FormatFloat(params.Focus[0], 'g', -1, 64))
+		query.Set("focus.point.lat", strconv.FormatFloat(params.Focus[1], 'g', -1, 64))
+	}
+	setIfNotEmpty(query, "lang", params.Lang)
+	setIfNotEmpty(query, "api_key", c.apiKey)
+	reqURL.RawQuery = query.Encode()
+
+	return c.get(ctx, reqURL)
+}
+
+// Reverse calls the Pelias reverse geocoding API.
+func (c *Client) Reverse(ctx context.Context, params ReverseParams) (*FeatureCollection, error) {
+	reqURL, err := url.Parse(c.baseURL + "/v1/reverse")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	query := reqURL.Query()
+	query.Set("point.lat", strconv.FormatFloat(params.Lat, 'g', -1, 64))
+	query.Set("point.lon", strconv.FormatFloat(params.Lon, 'g', -1, 64))
+	query.Set("size", "1")
+	setIfNotEmpty(query, "lang", params.Lang)
+	setIfNotEmpty(query, "api_key", c.apiKey)
+	reqURL.RawQuery = query.Encode()
+
+	return c.get(ctx, reqURL)
+}
+
+func (c *Client) get(ctx context.Context, reqURL *url.URL) (*FeatureCollection, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var result FeatureCollection
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &result, nil
+}
+
+func setIfNotEmpty(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
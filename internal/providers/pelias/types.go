@@ -0,0 +1,69 @@
+package pelias
+
+// SearchParams are the parameters of a search. Either Text or the structured fields
+// (Address, Locality, County, Region, PostalCode, Country) must be set.
+type SearchParams struct {
+	Text string
+
+	Address    string
+	Locality   string
+	County     string
+	Region     string
+	PostalCode string
+	Country    string
+
+	Size int
+	// Countries restricts results to ISO 3166-1 country codes.
+	Countries []string
+	// BoundingBox restricts results to [minLon, minLat, maxLon, maxLat] if set.
+	BoundingBox []float64
+	// Focus favors the results close to [lon, lat] if set.
+	Focus []float64
+	// Lang is a BCP 47 language tag used to localize results.
+	Lang string
+}
+
+func (p SearchParams) IsStructured() bool {
+	return p.Address != "" || p.Locality != "" || p.County != "" || p.Region != "" ||
+		p.PostalCode != "" || p.Country != ""
+}
+
+type ReverseParams struct {
+	Lat  float64
+	Lon  float64
+	Lang string
+}
+
+// FeatureCollection is the GeoJSON response of the search and reverse APIs.
+type FeatureCollection struct {
+	Features []Feature `json:"features"`
+}
+
+type Feature struct {
+	Geometry struct {
+		// Coordinates is [lon, lat].
+		Coordinates []float64 `json:"coordinates"`
+	} `json:"geometry"`
+	Properties Properties `json:"properties"`
+	// BoundingBox is [minLon, minLat, maxLon, maxLat], only set for areas.
+	BoundingBox []float64 `json:"bbox"`
+}
+
+type Properties struct {
+	// Source is the dataset of the place, e.g. "openstreetmap" or "openaddresses".
+	Source string `json:"source"`
+	// SourceID is the ID of the place in its dataset, e.g. "node/123" for OpenStreetMap.
+	SourceID    string  `json:"source_id"`
+	Layer       string  `json:"layer"`
+	Name        string  `json:"name"`
+	Label       string  `json:"label"`
+	HouseNumber string  `json:"housenumber"`
+	Street      string  `json:"street"`
+	PostalCode  string  `json:"postalcode"`
+	Locality    string  `json:"locality"`
+	County      string  `json:"county"`
+	Region      string  `json:"region"`
+	Country     string  `json:"country"`
+	CountryCode string  `json:"country_code"`
+	Confidence  float64 `json:"confidence"`
+}
@@ -0,0 +1,122 @@
+package photon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"supmap-gis/internal/providers/transport"
+	"time"
+)
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+type ClientOptions struct {
+	// Timeout bounds each call, retries included.
+	Timeout time.Duration
+	// HTTP configures the connections to the upstream.
+	HTTP transport.HTTPOptions
+	// Resilience configures the retries and the circuit breaker of the client.
+	Resilience transport.Options
+}
+
+func DefaultClientOptions() ClientOptions {
+	return ClientOptions{
+		Timeout:    7 * time.Second,
+		Resilience: transport.DefaultOptions(),
+	}
+}
+
+func NewClient(baseURL string, options ...ClientOptions) *Client {
+	opts := DefaultClientOptions()
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	return &Client{
+		baseURL:    baseURL,
+		httpClient: transport.NewHTTPClient("photon", opts.Timeout, opts.HTTP, opts.Resilience),
+	}
+}
+
+// Search calls the Photon search API.
+func (c *Client) Search(ctx context.Context, params SearchParams) (*FeatureCollection, error) {
+	reqURL, err := url.Parse(c.baseURL + "/api")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	query := reqURL.Query()
+	query.Set("q", params.Query)
+	if params.Limit > 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if len(params.BoundingBox) == 4 {
+		query.Set("bbox", fmt.Sprintf("%s,%s,%s,%s",
+			strconv.FormatFloat(params.BoundingBox[0], 'g', -1, 64),
+			strconv.FormatFloat(params.BoundingBox[1], 'g', -1, 64),
+			strconv.FormatFloat(params.BoundingBox[2], 'g', -1, 64),
+			strconv.FormatFloat(params.BoundingBox[3], 'g', -1, 64),
+		))
+	}
+	if len(params.Focus) == 2 {
+		query.Set("lon", strconv.FormatFloat(params.Focus[0], 'g', -1, 64))
+		query.Set("lat", strconv.FormatFloat(params.Focus[1], 'g', -1, 64))
+	}
+	if params.Lang != "" {
+		query.Set("lang", params.Lang)
+	}
+	reqURL.RawQuery = query.Encode()
+
+	return c.get(ctx, reqURL)
+}
+
+// Reverse calls the Photon reverse geocoding API.
+func (c *Client) Reverse(ctx context.Context, params ReverseParams) (*FeatureCollection, error) {
+	reqURL, err := url.Parse(c.baseURL + "/reverse")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	query := reqURL.Query()
+	query.Set("lat", strconv.FormatFloat(params.Lat, 'g', -1, 64))
+	query.Set("lon", strconv.FormatFloat(params.Lon, 'g', -1, 64))
+	query.Set("limit", "1")
+	if params.Lang != "" {
+		query.Set("lang", params.Lang)
+	}
+	reqURL.RawQuery = query.Encode()
+
+	return c.get(ctx, reqURL)
+}
+
+func (c *Client) get(ctx context.Context, reqURL *url.URL) (*FeatureCollection, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var result FeatureCollection
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &result, nil
+}
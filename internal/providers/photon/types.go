@@ -0,0 +1,53 @@
+package photon
+
+// SearchParams are the parameters of a search.
+type SearchParams struct {
+	Query string
+	Limit int
+	// BoundingBox restricts results to [minLon, minLat, maxLon, maxLat] if set.
+	BoundingBox []float64
+	// Focus favors the results close to [lon, lat] if set.
+	Focus []float64
+	// Lang is the language of results. Photon only supports a few languages, such as "fr", "en" or "de".
+	Lang string
+}
+
+type ReverseParams struct {
+	Lat  float64
+	Lon  float64
+	Lang string
+}
+
+// FeatureCollection is the GeoJSON response of the search and reverse APIs.
+type FeatureCollection struct {
+	Features []Feature `json:"features"`
+}
+
+type Feature struct {
+	Geometry struct {
+		// Coordinates is [lon, lat].
+		Coordinates []float64 `json:"coordinates"`
+	} `json:"geometry"`
+	Properties Properties `json:"properties"`
+}
+
+type Properties struct {
+	OSMID int64 `json:"osm_id"`
+	// OSMType is "N" (node), "W" (way) or "R" (relation).
+	OSMType     string `json:"osm_type"`
+	OSMKey      string `json:"osm_key"`
+	OSMValue    string `json:"osm_value"`
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	HouseNumber string `json:"housenumber"`
+	Street      string `json:"street"`
+	Postcode    string `json:"postcode"`
+	District    string `json:"district"`
+	City        string `json:"city"`
+	County      string `json:"county"`
+	State       string `json:"state"`
+	Country     string `json:"country"`
+	CountryCode string `json:"countrycode"`
+	// Extent is [minLon, maxLat, maxLon, minLat], only set for areas.
+	Extent []float64 `json:"extent"`
+}
@@ -0,0 +1,202 @@
+package srtm
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"supmap-gis/internal/lru"
+	"sync"
+	"time"
+)
+
+// Client reads elevations from SRTM .hgt tiles stored in a local directory.
+// Tiles are loaded lazily and the most recently used ones are kept in memory.
+type Client struct {
+	dir   string
+	tiles *lru.Cache
+
+	mu sync.Mutex
+	// loading holds the tiles being read, so that concurrent requests for a tile wait for the
+	// same read.
+	loading map[string]*tileLoad
+
+	// readFile reads the tiles, replaced by the tests.
+	readFile func(name string) ([]byte, error)
+}
+
+type ClientOptions struct {
+	// CacheSize is the maximum number of tiles kept in memory. A tile takes about 26 MB at
+	// 1 arc-second, and 3 MB at 3 arc-seconds.
+	CacheSize int
+}
+
+func DefaultClientOptions() ClientOptions {
+	return ClientOptions{
+		CacheSize: 16,
+	}
+}
+
+// tileTTL is how long a tile is kept in memory. Tiles don't change, but are reloaded from time
+// to time in case the file was replaced.
+const tileTTL = 24 * time.Hour
+
+type tileLoad struct {
+	done chan struct{}
+	tile *tile
+	err  error
+}
+
+func NewClient(dir string, options ...ClientOptions) *Client {
+	opts := DefaultClientOptions()
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	return &Client{
+		dir:      dir,
+		tiles:    lru.New(opts.CacheSize),
+		loading:  make(map[string]*tileLoad),
+		readFile: os.ReadFile,
+	}
+}
+
+func (c *Client) Stats() lru.Stats {
+	return c.tiles.Stats()
+}
+
+// Elevations returns the elevation in meters of each coordinate, using a bilinear interpolation
+// of the surrounding samples. Coordinates without a tile (usually the sea) have an elevation of 0.
+func (c *Client) Elevations(ctx context.Context, coordinates []Coordinate) ([]float64, error) {
+	heights := make([]float64, len(coordinates))
+	for i, coord := range coordinates {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		h, err := c.elevation(ctx, coord.Lat, coord.Lon)
+		if err != nil {
+			return nil, err
+		}
+		heights[i] = h
+	}
+	return heights, nil
+}
+
+func (c *Client) elevation(ctx context.Context, lat, lon float64) (float64, error) {
+	baseLat, baseLon := math.Floor(lat), math.Floor(lon)
+	t, err := c.tile(ctx, int(baseLat), int(baseLon))
+	if err != nil {
+		return 0, err
+	}
+	if t == nil {
+		return 0, nil
+	}
+
+	last := float64(t.size - 1)
+	row := (1 - (lat - baseLat)) * last
+	col := (lon - baseLon) * last
+
+	r0, c0 := int(math.Floor(row)), int(math.Floor(col))
+	r1, c1 := min(r0+1, t.size-1), min(c0+1, t.size-1)
+	dr, dc := row-float64(r0), col-float64(c0)
+
+	// Bilinear interpolation, ignoring void samples.
+	var sum, weights float64
+	for _, s := range []struct {
+		r, c   int
+		weight float64
+	}{
+		{r0, c0, (1 - dr) * (1 - dc)},
+		{r0, c1, (1 - dr) * dc},
+		{r1, c0, dr * (1 - dc)},
+		{r1, c1, dr * dc},
+	} {
+		h := t.heights[s.r*t.size+s.c]
+		if h == voidValue {
+			continue
+		}
+		sum += float64(h) * s.weight
+		weights += s.weight
+	}
+	if weights == 0 {
+		return 0, nil
+	}
+	return sum / weights, nil
+}
+
+// tile returns the tile whose south-west corner is (lat, lon), or nil if the tile doesn't exist.
+func (c *Client) tile(ctx context.Context, lat, lon int) (*tile, error) {
+	name := tileName(lat, lon)
+	if value, _, ok := c.tiles.Get(name); ok {
+		return value.(*tile), nil
+	}
+
+	c.mu.Lock()
+	load, ok := c.loading[name]
+	if !ok {
+		load = &tileLoad{done: make(chan struct{})}
+		c.loading[name] = load
+	}
+	c.mu.Unlock()
+
+	if ok {
+		select {
+		case <-load.done:
+			return load.tile, load.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	// The tile is read without holding the lock, so that the other tiles stay available.
+	load.tile, load.err = c.load(name)
+	if load.err == nil {
+		c.tiles.Set(name, load.tile, tileTTL)
+	}
+
+	c.mu.Lock()
+	delete(c.loading, name)
+	c.mu.Unlock()
+	close(load.done)
+
+	return load.tile, load.err
+}
+
+// load reads the named tile, or returns nil if it doesn't exist.
+func (c *Client) load(name string) (*tile, error) {
+	data, err := c.readFile(filepath.Join(c.dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tile %s: %w", name, err)
+	}
+
+	size := int(math.Sqrt(float64(len(data) / 2)))
+	if size*size*2 != len(data) {
+		return nil, fmt.Errorf("invalid tile %s: unexpected size of %d bytes", name, len(data))
+	}
+
+	heights := make([]int16, size*size)
+	for i := range heights {
+		heights[i] = int16(binary.BigEndian.Uint16(data[i*2:]))
+	}
+
+	return &tile{size: size, heights: heights}, nil
+}
+
+// tileName returns the file name of the tile whose south-west corner is (lat, lon), e.g. "N49W001.hgt".
+func tileName(lat, lon int) string {
+	ns, ew := 'N', 'E'
+	if lat < 0 {
+		ns, lat = 'S', -lat
+	}
+	if lon < 0 {
+		ew, lon = 'W', -lon
+	}
+	return fmt.Sprintf("%c%02d%c%03d.hgt", ns, lat, ew, lon)
+}
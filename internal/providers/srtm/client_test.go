@@ -0,0 +1,139 @@
+package srtm
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writeTile writes a tile of 3x3 samples, given from north to south and west to east.
+func writeTile(t *testing.T, dir, name string, heights [9]int16) {
+	t.Helper()
+	data := make([]byte, 0, 18)
+	for _, h := range heights {
+		data = binary.BigEndian.AppendUint16(data, uint16(h))
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// countReads counts the tiles read by c.
+func countReads(c *Client) *atomic.Int32 {
+	var reads atomic.Int32
+	readFile := c.readFile
+	c.readFile = func(name string) ([]byte, error) {
+		reads.Add(1)
+		return readFile(name)
+	}
+	return &reads
+}
+
+func TestElevations(t *testing.T) {
+	dir := t.TempDir()
+	writeTile(t, dir, "N49W001.hgt", [9]int16{
+		100, 200, 300,
+		40, 100, 200,
+		voidValue, 0, 100,
+	})
+	c := NewClient(dir)
+
+	got, err := c.Elevations(context.Background(), []Coordinate{
+		{Lat: 49.5, Lon: -0.5},  // center sample
+		{Lat: 49.75, Lon: -0.5}, // between two rows
+		{Lat: 49.75, Lon: -1},   // on the west edge
+		{Lat: 49.125, Lon: -1},  // next to a void sample, which is ignored
+		{Lat: 12.5, Lon: -30.5}, // no tile
+	})
+	if err != nil {
+		t.Fatalf("Elevations() error = %v", err)
+	}
+	want := []float64{100, 150, 70, 40, 0}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("elevation %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTileCache(t *testing.T) {
+	dir := t.TempDir()
+	writeTile(t, dir, "N49W001.hgt", [9]int16{})
+	writeTile(t, dir, "N49E000.hgt", [9]int16{})
+	writeTile(t, dir, "N48W001.hgt", [9]int16{})
+	c := NewClient(dir, ClientOptions{CacheSize: 2})
+	reads := countReads(c)
+	ctx := context.Background()
+
+	for _, coord := range []Coordinate{
+		{Lat: 49.1, Lon: -0.9}, {Lat: 49.2, Lon: -0.8}, // N49W001 is read once
+		{Lat: 49.1, Lon: 0.1},  // N49E000
+		{Lat: 48.1, Lon: -0.9}, // N48W001 evicts N49W001
+		{Lat: 49.1, Lon: -0.9}, // N49W001 is read again
+	} {
+		if _, err := c.Elevations(ctx, []Coordinate{coord}); err != nil {
+			t.Fatalf("Elevations() error = %v", err)
+		}
+	}
+
+	if got := reads.Load(); got != 4 {
+		t.Errorf("%d tiles read, want 4", got)
+	}
+	if got := c.Stats().Entries; got != 2 {
+		t.Errorf("%d tiles cached, want at most 2", got)
+	}
+}
+
+func TestTileLoadedOnce(t *testing.T) {
+	dir := t.TempDir()
+	writeTile(t, dir, "N49W001.hgt", [9]int16{})
+	c := NewClient(dir)
+
+	// The read is slowed down, so that the requests wait for it.
+	var reads atomic.Int32
+	c.readFile = func(name string) ([]byte, error) {
+		reads.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return os.ReadFile(name)
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Elevations(context.Background(), []Coordinate{{Lat: 49.5, Lon: -0.5}}); err != nil {
+				t.Errorf("Elevations() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := reads.Load(); got != 1 {
+		t.Errorf("tile read %d times, want 1", got)
+	}
+}
+
+func TestInvalidTile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "N49W001.hgt"), []byte{1, 2, 3}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(dir)
+	reads := countReads(c)
+
+	// Errors aren't cached.
+	for range 2 {
+		if _, err := c.Elevations(context.Background(), []Coordinate{{Lat: 49.5, Lon: -0.5}}); err == nil {
+			t.Fatal("Elevations() error = nil, want an error")
+		}
+	}
+	if got := reads.Load(); got != 2 {
+		t.Errorf("tile read %d times, want 2", got)
+	}
+}
@@ -0,0 +1,16 @@
+package srtm
+
+type Coordinate struct {
+	Lat float64
+	Lon float64
+}
+
+// tile holds the heights of a 1°x1° SRTM tile.
+// Rows go from north to south and columns from west to east.
+type tile struct {
+	size    int // number of rows and columns: 1201 for SRTM3, 3601 for SRTM1
+	heights []int16
+}
+
+// voidValue marks a missing height in an SRTM tile.
+const voidValue = -32768
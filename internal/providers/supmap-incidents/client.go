@@ -6,21 +6,30 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"supmap-gis/internal/providers/transport"
 	"time"
 )
 
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	// probeClient is used by Status.
+	probeClient *http.Client
 }
 
 type ClientOptions struct {
+	// Timeout bounds each call, retries included.
 	Timeout time.Duration
+	// HTTP configures the connections to the upstream.
+	HTTP transport.HTTPOptions
+	// Resilience configures the retries and the circuit breaker of the client.
+	Resilience transport.Options
 }
 
 func DefaultClientOptions() ClientOptions {
 	return ClientOptions{
-		Timeout: 7 * time.Second,
+		Timeout:    7 * time.Second,
+		Resilience: transport.DefaultOptions(),
 	}
 }
 
@@ -31,14 +40,19 @@ func NewClient(baseURL string, options ...ClientOptions) *Client {
 	}
 
 	return &Client{
-		baseURL:    baseURL,
-		httpClient: &http.Client{Timeout: opts.Timeout},
+		baseURL:     baseURL,
+		httpClient:  transport.NewHTTPClient("supmap-incidents", opts.Timeout, opts.HTTP, opts.Resilience),
+		probeClient: transport.NewProbeClient("supmap-incidents", opts.Timeout, opts.HTTP),
 	}
 }
 
 type RadiusMeter uint
 
 func (c *Client) IncidentsInRadius(ctx context.Context, lat, lon float64, radius RadiusMeter) ([]Incident, error) {
+	return c.incidentsInRadius(ctx, c.httpClient, lat, lon, radius)
+}
+
+func (c *Client) incidentsInRadius(ctx context.Context, httpClient *http.Client, lat, lon float64, radius RadiusMeter) ([]Incident, error) {
 	reqURL, err := url.Parse(c.baseURL + "/internal/incidents")
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse URL: %w", err)
@@ -55,14 +69,14 @@ func (c *Client) IncidentsInRadius(ctx context.Context, lat, lon float64, radius
 		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, &transport.StatusError{StatusCode: resp.StatusCode}
 	}
 
 	var result []Incident
@@ -72,3 +86,10 @@ func (c *Client) IncidentsInRadius(ctx context.Context, lat, lon float64, radius
 
 	return result, nil
 }
+
+// Status checks that supmap-incidents is up. It has no status endpoint, so it searches
+// for incidents in a tiny radius, which is cheap.
+func (c *Client) Status(ctx context.Context) error {
+	_, err := c.incidentsInRadius(ctx, c.probeClient, 0, 0, 1)
+	return err
+}
@@ -0,0 +1,32 @@
+package tolls
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Load reads a JSON toll tariffs data file.
+func Load(path string) (*Tariffs, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tariffs file: %w", err)
+	}
+	defer f.Close()
+
+	var tariffs Tariffs
+	if err := json.NewDecoder(f).Decode(&tariffs); err != nil {
+		return nil, fmt.Errorf("failed to decode tariffs file: %w", err)
+	}
+
+	for i, section := range tariffs.Sections {
+		if section.ID == "" {
+			return nil, fmt.Errorf("sections[%d]: missing id", i)
+		}
+		if len(section.Roads) == 0 {
+			return nil, fmt.Errorf("section %q: at least one road is required", section.ID)
+		}
+	}
+
+	return &tariffs, nil
+}
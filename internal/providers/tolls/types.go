@@ -0,0 +1,34 @@
+package tolls
+
+// VehicleClass is a French motorway toll vehicle class.
+type VehicleClass int
+
+const (
+	VehicleClassCar          VehicleClass = 1 // light vehicles, height < 2m
+	VehicleClassIntermediate VehicleClass = 2 // light vehicles with a height between 2m and 3m
+	VehicleClassTruck        VehicleClass = 3 // heavy vehicles with 2 axles
+	VehicleClassHeavyTruck   VehicleClass = 4 // heavy vehicles with 3 axles or more
+	VehicleClassMotorcycle   VehicleClass = 5
+)
+
+// Tariffs is the content of a toll tariffs data file.
+type Tariffs struct {
+	Currency string    `json:"currency"`
+	Sections []Section `json:"sections"`
+}
+
+// Section is a tolled motorway section, with its price for a full traversal.
+type Section struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Operator string `json:"operator,omitempty"`
+	// Roads are the references of the roads of the section, e.g. "A13".
+	Roads []string `json:"roads"`
+	// Signs are the texts of the signs (exit names, directions) found on the section.
+	// They are used to choose between several sections of the same road.
+	Signs []string `json:"signs,omitempty"`
+	// LengthKm is the tolled length of the section, used to prorate the price when only part of it is driven.
+	// If zero, the full price is always charged.
+	LengthKm float64                  `json:"length_km,omitempty"`
+	Prices   map[VehicleClass]float64 `json:"prices"`
+}
@@ -0,0 +1,93 @@
+package transport
+
+import (
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	stateClosed breakerState = iota
+	stateOpen
+	// stateHalfOpen lets a single probe request through, which closes the breaker if it succeeds.
+	stateHalfOpen
+)
+
+// breaker is a circuit breaker opening after consecutive failures.
+type breaker struct {
+	threshold   int
+	openTimeout time.Duration
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// allow reports whether a request can be sent, or else how long before the breaker lets one through.
+func (b *breaker) allow() (time.Duration, bool) {
+	if b.threshold <= 0 {
+		return 0, true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if remaining := b.openTimeout - time.Since(b.openedAt); remaining > 0 {
+			return remaining, false
+		}
+		b.state = stateHalfOpen
+		b.probing = true
+		return 0, true
+	case stateHalfOpen:
+		if b.probing {
+			return b.openTimeout, false
+		}
+		b.probing = true
+		return 0, true
+	default:
+		return 0, true
+	}
+}
+
+// record updates the breaker with the outcome of a request let through by allow,
+// and reports whether it opened the breaker.
+func (b *breaker) record(success bool) bool {
+	if b.threshold <= 0 {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if success {
+		b.state = stateClosed
+		b.failures = 0
+		return false
+	}
+
+	b.failures++
+	if b.state == stateHalfOpen || b.failures >= b.threshold {
+		opened := b.state != stateOpen
+		b.state = stateOpen
+		b.openedAt = time.Now()
+		return opened
+	}
+	return false
+}
+
+// release gives back a request let through by allow without recording its outcome.
+func (b *breaker) release() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
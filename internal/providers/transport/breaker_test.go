@@ -0,0 +1,188 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreakerTransitions(t *testing.T) {
+	const openTimeout = 20 * time.Millisecond
+	b := &breaker{threshold: 3, openTimeout: openTimeout}
+
+	mustAllow := func(step string) {
+		t.Helper()
+		if _, ok := b.allow(); !ok {
+			t.Fatalf("%s: request not allowed", step)
+		}
+	}
+	mustReject := func(step string) {
+		t.Helper()
+		if retryAfter, ok := b.allow(); ok || retryAfter <= 0 {
+			t.Fatalf("%s: allow() = %s, %v, want a rejection with a delay", step, retryAfter, ok)
+		}
+	}
+
+	// Failures below the threshold, or interrupted by a success, keep the breaker closed.
+	for range 2 {
+		mustAllow("closed")
+		b.record(false)
+	}
+	mustAllow("closed")
+	b.record(true)
+	for range 2 {
+		mustAllow("closed after a success")
+		if b.record(false) {
+			t.Fatal("breaker opened below the threshold")
+		}
+	}
+
+	// The failure reaching the threshold opens the breaker.
+	mustAllow("closed")
+	if !b.record(false) {
+		t.Fatal("breaker not opened at the threshold")
+	}
+	mustReject("open")
+
+	// Once the timeout is over, a single probe is let through.
+	time.Sleep(openTimeout + 10*time.Millisecond)
+	mustAllow("half-open probe")
+	mustReject("half-open during the probe")
+
+	// A failed probe opens the breaker again, for another timeout.
+	if !b.record(false) {
+		t.Fatal("failed probe didn't reopen the breaker")
+	}
+	mustReject("reopened")
+
+	time.Sleep(openTimeout + 10*time.Millisecond)
+	mustAllow("second probe")
+	// A probe released without an outcome, e.g. canceled, lets another probe through.
+	b.release()
+	mustAllow("probe after release")
+
+	// A successful probe closes the breaker.
+	b.record(true)
+	mustAllow("closed after a successful probe")
+	mustAllow("closed after a successful probe")
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b := &breaker{threshold: 0}
+	for range 10 {
+		if _, ok := b.allow(); !ok {
+			t.Fatal("disabled breaker rejected a request")
+		}
+		if b.record(false) {
+			t.Fatal("disabled breaker opened")
+		}
+	}
+}
+
+func TestTransportOpensCircuit(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: New("test", nil, Options{
+		MaxRetries:       1,
+		BaseDelay:        time.Millisecond,
+		MaxDelay:         time.Millisecond,
+		FailureThreshold: 4,
+		OpenTimeout:      time.Minute,
+	})}
+
+	// Each request is retried once, so the breaker opens after the second request.
+	for range 2 {
+		resp, err := client.Get(upstream.URL)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		resp.Body.Close()
+	}
+	if got := calls.Load(); got != 4 {
+		t.Fatalf("upstream called %d times, want 4", got)
+	}
+
+	_, err := client.Get(upstream.URL)
+	var circuitErr *CircuitOpenError
+	if !errors.As(err, &circuitErr) || !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Get() error = %v, want a CircuitOpenError", err)
+	}
+	if circuitErr.Upstream != "test" || circuitErr.RetryAfter <= 0 || circuitErr.RetryAfter > time.Minute {
+		t.Errorf("CircuitOpenError = %+v", circuitErr)
+	}
+	if got := calls.Load(); got != 4 {
+		t.Errorf("upstream called %d times while the circuit is open, want 4", got)
+	}
+}
+
+func TestTransportOpensCircuitOnTimeout(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer upstream.Close()
+
+	client := NewHTTPClient("test", 20*time.Millisecond, HTTPOptions{}, Options{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+	})
+
+	for range 2 {
+		if _, err := client.Get(upstream.URL); err == nil {
+			t.Fatal("Get() error = nil, want a timeout")
+		}
+	}
+
+	// The upstream timed out twice, so the breaker fails fast instead of waiting for it again.
+	start := time.Now()
+	if _, err := client.Get(upstream.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Get() error = %v, want ErrCircuitOpen", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 20*time.Millisecond {
+		t.Errorf("Get() took %s with an open circuit", elapsed)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("upstream called %d times, want 2", got)
+	}
+}
+
+func TestTransportIgnoresCanceledRequests(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer upstream.Close()
+
+	client := NewHTTPClient("test", time.Second, HTTPOptions{}, Options{
+		FailureThreshold: 1,
+		OpenTimeout:      time.Minute,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("Do() error = nil, want a cancellation")
+	}
+
+	// The caller left, which doesn't count against the upstream.
+	if _, ok := client.Transport.(*Transport).breaker.allow(); !ok {
+		t.Error("breaker opened by a canceled request")
+	}
+}
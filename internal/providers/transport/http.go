@@ -0,0 +1,134 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"supmap-gis/internal/logging"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HTTPOptions configure the connections to an upstream.
+type HTTPOptions struct {
+	// MaxIdleConns is the maximum number of idle connections kept open to the upstream.
+	// If zero, the defaults of [http.DefaultTransport] are used.
+	MaxIdleConns int
+	// TLSConfig is used for HTTPS connections if not nil.
+	TLSConfig *tls.Config
+	// Headers are added to each request that doesn't already set them, e.g. a User-Agent or an API key.
+	Headers http.Header
+	// Instrument, if not nil, wraps the transport of the client, e.g. to record metrics.
+	// It sees each call once, retries included.
+	Instrument func(upstream string, next http.RoundTripper) http.RoundTripper
+}
+
+// NewHTTPClient returns an HTTP client for the named upstream, using a [Transport] with the
+// given resilience options on top of connections configured by httpOptions.
+func NewHTTPClient(upstream string, timeout time.Duration, httpOptions HTTPOptions, resilience Options) *http.Client {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	if httpOptions.MaxIdleConns > 0 {
+		base.MaxIdleConns = httpOptions.MaxIdleConns
+		// A client only talks to one host.
+		base.MaxIdleConnsPerHost = httpOptions.MaxIdleConns
+	}
+	if httpOptions.TLSConfig != nil {
+		base.TLSClientConfig = httpOptions.TLSConfig
+	}
+
+	var roundTripper http.RoundTripper = &headersTransport{base: base, headers: httpOptions.Headers}
+	// Each attempt gets its own client span, and propagates the trace context to the upstream.
+	roundTripper = otelhttp.NewTransport(roundTripper,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return upstream + " " + r.Method
+		}),
+		otelhttp.WithSpanOptions(trace.WithAttributes(attribute.String("peer.service", upstream))),
+	)
+
+	var clientTransport http.RoundTripper = New(upstream, roundTripper, resilience)
+	if httpOptions.Instrument != nil {
+		clientTransport = httpOptions.Instrument(upstream, clientTransport)
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: clientTransport,
+	}
+}
+
+// NewProbeClient returns an HTTP client for the health checks of the named upstream. Unlike the
+// client returned by [NewHTTPClient], it doesn't retry and has no circuit breaker, so that a probe
+// promptly reports the actual state of the upstream. It isn't instrumented either, so that the
+// probes don't count as calls of the service to the upstream.
+func NewProbeClient(upstream string, timeout time.Duration, httpOptions HTTPOptions) *http.Client {
+	httpOptions.Instrument = nil
+	return NewHTTPClient(upstream, timeout, httpOptions, Options{})
+}
+
+// headersTransport adds the configured headers and the ID of the request being handled to the upstream requests.
+type headersTransport struct {
+	base    http.RoundTripper
+	headers http.Header
+}
+
+func (t *headersTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestID := logging.RequestID(req.Context())
+	if len(t.headers) == 0 && requestID == "" {
+		return t.base.RoundTrip(req)
+	}
+
+	// A RoundTripper must not modify the request it is given.
+	req = req.Clone(req.Context())
+	for name, values := range t.headers {
+		if req.Header.Get(name) == "" {
+			req.Header[name] = values
+		}
+	}
+	// The ID of the request being handled lets the upstream logs be correlated with ours.
+	if requestID != "" && req.Header.Get(logging.RequestIDHeader) == "" {
+		req.Header.Set(logging.RequestIDHeader, requestID)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// LoadTLSConfig returns a TLS configuration trusting the certificate authorities of caFile,
+// in addition to the system ones, and presenting the client certificate of certFile and keyFile.
+// Each file is optional, but certFile and keyFile must be given together.
+func LoadTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("a client certificate requires both a certificate and a key file")
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in CA file %q", caFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if certFile != "" {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
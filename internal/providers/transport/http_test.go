@@ -0,0 +1,41 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProbeClientNotInstrumented(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("User-Agent"); got != "supmap-gis/test" {
+			t.Errorf("User-Agent = %q, want the configured header", got)
+		}
+	}))
+	defer server.Close()
+
+	var instrumented []string
+	options := HTTPOptions{
+		Headers: http.Header{"User-Agent": {"supmap-gis/test"}},
+		Instrument: func(upstream string, next http.RoundTripper) http.RoundTripper {
+			instrumented = append(instrumented, upstream)
+			return next
+		},
+	}
+
+	for _, client := range []*http.Client{
+		NewHTTPClient("test", time.Second, options, Options{}),
+		NewProbeClient("probe", time.Second, options),
+	} {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if len(instrumented) != 1 || instrumented[0] != "test" {
+		t.Errorf("instrumented upstreams = %v, want only the client of the service", instrumented)
+	}
+}
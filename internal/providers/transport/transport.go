@@ -0,0 +1,190 @@
+// Package transport provides the resilient HTTP transport shared by the provider clients:
+// retries with jittered exponential backoff, and a circuit breaker per upstream.
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"supmap-gis/internal/logging"
+	"time"
+)
+
+// ErrCircuitOpen is matched by the errors returned while the circuit breaker of an upstream is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitOpenError is returned without calling the upstream while its circuit breaker is open.
+type CircuitOpenError struct {
+	Upstream string
+	// RetryAfter is the time left before the breaker lets a request through again.
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s: %s, retry after %s", e.Upstream, ErrCircuitOpen, e.RetryAfter.Round(time.Second))
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// StatusError is returned when an upstream answers with an unexpected status code.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+type Options struct {
+	// MaxRetries is the number of times a failed request is retried, 0 disabling retries.
+	MaxRetries int
+	// BaseDelay and MaxDelay bound the backoff between retries, which doubles after each attempt
+	// and is randomized between 0 and its current value.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// RetryPost also retries POST requests, for upstreams whose POST endpoints have no side effects.
+	RetryPost bool
+
+	// FailureThreshold is the number of consecutive failures that opens the circuit breaker,
+	// 0 disabling the breaker.
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before letting a probe request through.
+	OpenTimeout time.Duration
+}
+
+func DefaultOptions() Options {
+	return Options{
+		MaxRetries:       2,
+		BaseDelay:        100 * time.Millisecond,
+		MaxDelay:         time.Second,
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+	}
+}
+
+// Transport is an [http.RoundTripper] retrying the idempotent requests that fail with a connection
+// error or a 5xx status, and failing fast while the upstream is considered down.
+// A Transport must be used for a single upstream, since its circuit breaker is shared by all its requests.
+type Transport struct {
+	upstream string
+	base     http.RoundTripper
+	options  Options
+	breaker  *breaker
+}
+
+// New returns a transport for the named upstream, sending requests with base, or
+// [http.DefaultTransport] if nil.
+func New(upstream string, base http.RoundTripper, options ...Options) *Transport {
+	opts := DefaultOptions()
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &Transport{
+		upstream: upstream,
+		base:     base,
+		options:  opts,
+		breaker:  &breaker{threshold: opts.FailureThreshold, openTimeout: opts.OpenTimeout},
+	}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retries := 0
+	if t.retryable(req) {
+		retries = t.options.MaxRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		if retryAfter, ok := t.breaker.allow(); !ok {
+			return nil, &CircuitOpenError{Upstream: t.upstream, RetryAfter: retryAfter}
+		}
+
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+		// A request canceled by the caller says nothing about the health of the upstream. A timed
+		// out one does: the timeout of an [http.Client] is a deadline on the request context.
+		if err != nil && errors.Is(req.Context().Err(), context.Canceled) {
+			t.breaker.release()
+			return nil, err
+		}
+		if t.breaker.record(!failed) {
+			logging.FromContext(req.Context()).Error("Circuit breaker opened", "upstream", t.upstream, "open_timeout", t.options.OpenTimeout)
+		}
+
+		if !failed || attempt >= retries {
+			return resp, err
+		}
+
+		logger := logging.FromContext(req.Context())
+		if err != nil {
+			logger.Warn("Retrying upstream request", "upstream", t.upstream, "attempt", attempt+1, "error", err)
+		} else {
+			logger.Warn("Retrying upstream request", "upstream", t.upstream, "attempt", attempt+1, "status", resp.StatusCode)
+		}
+
+		if resp != nil {
+			// Drains the body so that the connection can be reused.
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := sleep(req.Context(), t.backoff(attempt)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryable reports whether req can be sent again, which requires it to be idempotent
+// and its body, if any, to be replayable.
+func (t *Transport) retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		return t.options.RetryPost
+	}
+	return false
+}
+
+// backoff returns the delay before the retry following the given attempt, with full jitter.
+func (t *Transport) backoff(attempt int) time.Duration {
+	delay := t.options.BaseDelay << attempt
+	if delay <= 0 || delay > t.options.MaxDelay {
+		delay = t.options.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return rand.N(delay)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -7,21 +7,34 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"supmap-gis/internal/providers/transport"
 	"time"
 )
 
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	// probeClient is used by Status.
+	probeClient *http.Client
 }
 
 type ClientOptions struct {
+	// Timeout bounds each call, retries included.
 	Timeout time.Duration
+	// HTTP configures the connections to the upstream.
+	HTTP transport.HTTPOptions
+	// Resilience configures the retries and the circuit breaker of the client.
+	Resilience transport.Options
 }
 
 func DefaultClientOptions() ClientOptions {
+	resilience := transport.DefaultOptions()
+	// Valhalla's POST endpoints only compute routes, so they can be retried.
+	resilience.RetryPost = true
+
 	return ClientOptions{
-		Timeout: 7 * time.Second,
+		Timeout:    7 * time.Second,
+		Resilience: resilience,
 	}
 }
 
@@ -32,8 +45,9 @@ func NewClient(baseURL string, options ...ClientOptions) *Client {
 	}
 
 	return &Client{
-		baseURL:    baseURL,
-		httpClient: &http.Client{Timeout: opts.Timeout},
+		baseURL:     baseURL,
+		httpClient:  transport.NewHTTPClient("valhalla", opts.Timeout, opts.HTTP, opts.Resilience),
+		probeClient: transport.NewProbeClient("valhalla", opts.Timeout, opts.HTTP),
 	}
 }
 
@@ -74,3 +88,100 @@ func (c *Client) CalculateRoute(ctx context.Context, routeRequest RouteRequest)
 
 	return &routeResponse, nil
 }
+
+// Height calls the Valhalla elevation API, which returns the height of each point of a shape.
+func (c *Client) Height(ctx context.Context, heightRequest HeightRequest) (*HeightResponse, error) {
+	reqURL, err := url.Parse(c.baseURL + "/height")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	body, err := json.Marshal(heightRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var heightResponse HeightResponse
+	if err := json.NewDecoder(resp.Body).Decode(&heightResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &heightResponse, nil
+}
+
+// Matrix calls the Valhalla time-distance matrix API, which computes the travel time and distance
+// from each source to each target.
+func (c *Client) Matrix(ctx context.Context, matrixRequest MatrixRequest) (*MatrixResponse, error) {
+	reqURL, err := url.Parse(c.baseURL + "/sources_to_targets")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	body, err := json.Marshal(matrixRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var matrixResponse MatrixResponse
+	if err := json.NewDecoder(resp.Body).Decode(&matrixResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &matrixResponse, nil
+}
+
+// Status checks that Valhalla is up.
+func (c *Client) Status(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/status", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.probeClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &transport.StatusError{StatusCode: resp.StatusCode}
+	}
+
+	return nil
+}
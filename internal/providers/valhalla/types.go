@@ -5,7 +5,8 @@ type RouteRequest struct {
 	ExcludeLocations []ExcludeLocations `json:"exclude_locations"`
 	Costing          Costing            `json:"costing"`
 	CostingOptions   *CostingOptions    `json:"costing_options,omitempty"`
-	Language         string             `json:"language"`
+	Language         Language           `json:"language"`
+	Units            Units              `json:"units"`
 	Alternates       int                `json:"alternates"`
 	ID               *string            `json:"id,omitempty"`
 }
@@ -16,6 +17,45 @@ type RouteResponse struct {
 	ID         *string     `json:"id,omitempty"`
 }
 
+// HeightRequest is the body of a request to the elevation service.
+type HeightRequest struct {
+	Shape []Coordinate `json:"shape"`
+	// Range also returns the cumulative distance along the shape for each height.
+	Range bool `json:"range"`
+}
+
+type HeightResponse struct {
+	Shape  []Coordinate `json:"shape"`
+	Height []*float64   `json:"height"` // null when no data is available for the point
+}
+
+// MatrixRequest is the body of a request to the time-distance matrix service.
+type MatrixRequest struct {
+	Sources []Coordinate `json:"sources"`
+	Targets []Coordinate `json:"targets"`
+	Costing Costing      `json:"costing"`
+	Units   Units        `json:"units,omitempty"`
+}
+
+type MatrixResponse struct {
+	// SourcesToTargets contains a row per source, with a cell per target.
+	SourcesToTargets [][]MatrixCell `json:"sources_to_targets"`
+	Units            Units          `json:"units"`
+}
+
+type MatrixCell struct {
+	// Distance and Time are null when the target can't be reached from the source.
+	Distance  *float64 `json:"distance"`
+	Time      *float64 `json:"time"`
+	FromIndex int      `json:"from_index"`
+	ToIndex   int      `json:"to_index"`
+}
+
+type Coordinate struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
 //
 // Common types for requests and responses :
 //
@@ -77,6 +117,34 @@ func (c Costing) IsValid() bool {
 	}
 }
 
+// Units corresponds to the distance units used by Valhalla for lengths in the response.
+// Can be "kilometers" or "miles".
+type Units string
+
+const (
+	UnitsKilometers Units = "kilometers"
+	UnitsMiles      Units = "miles"
+)
+
+func (u Units) IsValid() bool {
+	switch u {
+	case UnitsKilometers, UnitsMiles:
+		return true
+	default:
+		return false
+	}
+}
+
+// Language corresponds to a BCP 47 language tag supported by Valhalla for narrative instructions.
+type Language string
+
+// SupportedLanguages lists the languages Valhalla can generate narrative instructions in.
+var SupportedLanguages = []Language{
+	"bg-BG", "ca-ES", "cs-CZ", "da-DK", "de-DE", "el-GR", "en-GB", "en-US", "en-US-x-pirate",
+	"es-ES", "et-EE", "fi-FI", "fr-FR", "hi-IN", "hu-HU", "it-IT", "ja-JP", "nb-NO", "nl-NL",
+	"pl-PL", "pt-BR", "pt-PT", "ro-RO", "ru-RU", "sk-SK", "sl-SI", "sv-SE", "tr-TR", "uk-UA",
+}
+
 // Ratio represents a float between 0 and 1.
 type Ratio float64
 
@@ -218,8 +286,8 @@ type Trip struct {
 	Summary       Summary            `json:"summary"`
 	StatusMessage string             `json:"status_message"`
 	Status        int                `json:"status"`
-	Units         string             `json:"units"`
-	Language      string             `json:"language"`
+	Units         Units              `json:"units"`
+	Language      Language           `json:"language"`
 }
 
 type Alternate struct {
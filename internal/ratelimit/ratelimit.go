@@ -0,0 +1,112 @@
+// Package ratelimit limits the rate of the requests of each client with token buckets.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is the token bucket of each client: it holds up to Burst tokens, refilled at Rate tokens per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Window is the time taken to refill an empty bucket.
+func (l Limit) Window() time.Duration {
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+// Result is the outcome of a call to [Limiter.Allow].
+type Result struct {
+	Allowed bool
+	// Remaining is the number of tokens left in the bucket.
+	Remaining int
+	// Reset is the time before the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time before the request would be allowed, if it isn't.
+	RetryAfter time.Duration
+}
+
+// sweepInterval is how often the buckets that are full again are forgotten.
+const sweepInterval = time.Minute
+
+// Limiter holds a token bucket per client key.
+type Limiter struct {
+	limit Limit
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func New(limit Limit) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (l *Limiter) Limit() Limit {
+	return l.limit
+}
+
+// Allow takes cost tokens from the bucket of key if it holds enough of them. A request costing
+// more than the burst is charged the burst, so that it can still be allowed with a full bucket.
+func (l *Limiter) Allow(key string, cost int) Result {
+	burst := float64(l.limit.Burst)
+	need := math.Min(float64(max(cost, 1)), burst)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, updated: now}
+		l.buckets[key] = b
+	} else {
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.updated).Seconds()*l.limit.Rate)
+		b.updated = now
+	}
+
+	result := Result{Allowed: b.tokens >= need}
+	if result.Allowed {
+		b.tokens -= need
+	} else {
+		result.RetryAfter = l.duration(need - b.tokens)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = l.duration(burst - b.tokens)
+	return result
+}
+
+// sweep forgets the buckets that are full again, which are the same as new buckets.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	burst := float64(l.limit.Burst)
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*l.limit.Rate >= burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// duration returns the time taken to refill tokens.
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.limit.Rate * float64(time.Second))
+}
@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock advanced by the tests.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestLimiter(limit Limit) (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 5, 12, 9, 30, 0, 0, time.UTC)}
+	l := New(limit)
+	l.now = clock.Now
+	return l, clock
+}
+
+func TestAllow(t *testing.T) {
+	// 2 tokens per second, up to 10.
+	limit := Limit{Rate: 2, Burst: 10}
+
+	type step struct {
+		advance        time.Duration
+		cost           int
+		wantAllowed    bool
+		wantRemaining  int
+		wantReset      time.Duration
+		wantRetryAfter time.Duration
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "new bucket is full",
+			steps: []step{
+				{cost: 1, wantAllowed: true, wantRemaining: 9, wantReset: 500 * time.Millisecond},
+			},
+		},
+		{
+			name: "burst then rejected",
+			steps: []step{
+				{cost: 10, wantAllowed: true, wantRemaining: 0, wantReset: 5 * time.Second},
+				{cost: 1, wantAllowed: false, wantRemaining: 0, wantReset: 5 * time.Second, wantRetryAfter: 500 * time.Millisecond},
+			},
+		},
+		{
+			name: "refilled over time",
+			steps: []step{
+				{cost: 10, wantAllowed: true, wantRemaining: 0, wantReset: 5 * time.Second},
+				{advance: 1500 * time.Millisecond, cost: 3, wantAllowed: true, wantRemaining: 0, wantReset: 5 * time.Second},
+				{advance: time.Second, cost: 3, wantAllowed: false, wantRemaining: 2, wantReset: 4 * time.Second, wantRetryAfter: 500 * time.Millisecond},
+			},
+		},
+		{
+			name: "refill capped at the burst",
+			steps: []step{
+				{cost: 1, wantAllowed: true, wantRemaining: 9, wantReset: 500 * time.Millisecond},
+				{advance: time.Hour, cost: 1, wantAllowed: true, wantRemaining: 9, wantReset: 500 * time.Millisecond},
+			},
+		},
+		{
+			name: "rejected request takes no tokens",
+			steps: []step{
+				{cost: 8, wantAllowed: true, wantRemaining: 2, wantReset: 4 * time.Second},
+				{cost: 5, wantAllowed: false, wantRemaining: 2, wantReset: 4 * time.Second, wantRetryAfter: 1500 * time.Millisecond},
+				{cost: 2, wantAllowed: true, wantRemaining: 0, wantReset: 5 * time.Second},
+			},
+		},
+		{
+			name: "cost above the burst charged the burst",
+			steps: []step{
+				{cost: 50, wantAllowed: true, wantRemaining: 0, wantReset: 5 * time.Second},
+				{advance: 4 * time.Second, cost: 50, wantAllowed: false, wantRemaining: 8, wantReset: time.Second, wantRetryAfter: time.Second},
+			},
+		},
+		{
+			name: "zero cost charged a token",
+			steps: []step{
+				{cost: 0, wantAllowed: true, wantRemaining: 9, wantReset: 500 * time.Millisecond},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, clock := newTestLimiter(limit)
+			for i, s := range tt.steps {
+				clock.Advance(s.advance)
+				got := l.Allow("client", s.cost)
+				want := Result{Allowed: s.wantAllowed, Remaining: s.wantRemaining, Reset: s.wantReset, RetryAfter: s.wantRetryAfter}
+				if got != want {
+					t.Errorf("step %d: Allow() = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAllowSeparateClients(t *testing.T) {
+	l, _ := newTestLimiter(Limit{Rate: 1, Burst: 1})
+
+	if !l.Allow("a", 1).Allowed {
+		t.Fatal("first request of a rejected")
+	}
+	if l.Allow("a", 1).Allowed {
+		t.Error("second request of a allowed")
+	}
+	if !l.Allow("b", 1).Allowed {
+		t.Error("first request of b rejected")
+	}
+}
+
+func TestSweep(t *testing.T) {
+	l, clock := newTestLimiter(Limit{Rate: 1, Burst: 10})
+
+	l.Allow("idle", 10)
+	l.Allow("busy", 10)
+	clock.Advance(sweepInterval)
+	l.Allow("busy", 10)
+
+	// The idle bucket is full again after 10 s and is forgotten, the busy one is empty.
+	if _, ok := l.buckets["idle"]; ok {
+		t.Error("full bucket not swept")
+	}
+	if _, ok := l.buckets["busy"]; !ok {
+		t.Error("bucket in use swept")
+	}
+
+	// A forgotten bucket is the same as a full one.
+	if got := l.Allow("idle", 1); !got.Allowed || got.Remaining != 9 {
+		t.Errorf("Allow() after sweep = %+v, want a full bucket", got)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	if got := (Limit{Rate: 0.1, Burst: 2}).Window(); got != 20*time.Second {
+		t.Errorf("Window() = %s, want 20s", got)
+	}
+}
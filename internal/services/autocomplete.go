@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"supmap-gis/internal/geo"
+	"supmap-gis/internal/lru"
+	"supmap-gis/internal/providers/nominatim"
+	"supmap-gis/internal/words"
+	"sync"
+	"time"
+)
+
+// nominatimDefaultLimit is the number of results returned by Nominatim when no limit is given.
+const nominatimDefaultLimit = 10
+
+// AutocompleteService provides type-ahead suggestions. Suggestions are cached by query, and a
+// query extending a cached one whose results weren't truncated is answered by filtering them,
+// without calling the provider. Concurrent identical queries are coalesced into a single
+// provider call.
+type AutocompleteService struct {
+	client  GeocodingClient
+	options AutocompleteOptions
+	cache   *lru.Cache
+
+	mu       sync.Mutex
+	inFlight map[string]*autocompleteCall
+}
+
+type AutocompleteOptions struct {
+	// MinLength is the minimum number of characters of a query.
+	MinLength int
+	// CacheSize is the maximum number of cached queries, 0 disabling the cache.
+	CacheSize int
+	CacheTTL  time.Duration
+}
+
+func DefaultAutocompleteOptions() AutocompleteOptions {
+	return AutocompleteOptions{
+		MinLength: 3,
+		CacheSize: 1000,
+		CacheTTL:  5 * time.Minute,
+	}
+}
+
+func NewAutocompleteService(client GeocodingClient, options ...AutocompleteOptions) *AutocompleteService {
+	opts := DefaultAutocompleteOptions()
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	return &AutocompleteService{
+		client:   client,
+		options:  opts,
+		cache:    lru.New(opts.CacheSize),
+		inFlight: make(map[string]*autocompleteCall),
+	}
+}
+
+func (s *AutocompleteService) Stats() lru.Stats {
+	return s.cache.Stats()
+}
+
+// Suggestion is a compact geocoding result, suited to type-ahead lists.
+type Suggestion struct {
+	Name  string  `json:"name"`
+	Label string  `json:"label"`
+	Type  string  `json:"type"`
+	Lat   float64 `json:"lat"`
+	Lon   float64 `json:"lon"`
+	// Distance from the user's position in meters, only set when a position is given.
+	Distance *float64 `json:"distance,omitempty"`
+
+	importance float64
+	score      float64
+	words      []string
+}
+
+// autocompleteEntry is a cached search.
+type autocompleteEntry struct {
+	suggestions []Suggestion
+	// complete is true if the provider returned fewer results than requested, so that the
+	// suggestions of any query extending this one are among them.
+	complete bool
+}
+
+type autocompleteCall struct {
+	done        chan struct{}
+	suggestions []Suggestion
+	err         error
+}
+
+const (
+	// autocompleteBiasRadius is the distance in meters around the user's position within which
+	// the provider favors results.
+	autocompleteBiasRadius = 50000
+	// autocompleteBiasPrecision is the number of decimals to which the user's position is rounded
+	// before biasing results, so that close users share the cached results. 1 decimal is about 11 km.
+	autocompleteBiasPrecision = 1
+)
+
+// Suggest returns at most limit suggestions for query. If position is not nil, the provider favors
+// results around it, and suggestions are re-ranked according to their distance from it and their
+// importance. Queries shorter than the minimum length have no suggestions.
+func (s *AutocompleteService) Suggest(ctx context.Context, query string, position *Point, limit int, language string) ([]Suggestion, error) {
+	normalized := normalizeQuery(query)
+	if len([]rune(normalized)) < s.options.MinLength {
+		return []Suggestion{}, nil
+	}
+
+	params := nominatim.SearchParams{Query: normalized, Language: language}
+	if position != nil {
+		factor := math.Pow10(autocompleteBiasPrecision)
+		center := Point{Lat: math.Round(position.Lat*factor) / factor, Lon: math.Round(position.Lon*factor) / factor}
+		params.ViewBox = boundingViewBox([]Point{center}, autocompleteBiasRadius)
+	}
+
+	suggestions, err := s.suggestions(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := make([]Suggestion, len(suggestions))
+	copy(ranked, suggestions)
+	rankSuggestions(ranked, position)
+
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked, nil
+}
+
+// suggestions returns the cached suggestions of params, or searches them, or waits for the
+// identical search in flight.
+func (s *AutocompleteService) suggestions(ctx context.Context, params nominatim.SearchParams) ([]Suggestion, error) {
+	if suggestions, ok := s.cached(params); ok {
+		return suggestions, nil
+	}
+	key := searchCacheKey(params)
+
+	s.mu.Lock()
+	call, ok := s.inFlight[key]
+	if !ok {
+		call = &autocompleteCall{done: make(chan struct{})}
+		s.inFlight[key] = call
+		// The search is shared by all the callers, so it doesn't stop when the first one leaves.
+		// It's still bounded by the timeout of the client.
+		go s.search(context.WithoutCancel(ctx), key, params, call)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.suggestions, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *AutocompleteService) search(ctx context.Context, key string, params nominatim.SearchParams, call *autocompleteCall) {
+	defer close(call.done)
+	call.suggestions, call.err = s.searchSuggestions(ctx, params)
+	if call.err == nil {
+		entry := autocompleteEntry{suggestions: call.suggestions, complete: len(call.suggestions) < searchLimit(params)}
+		s.cache.Set(key, entry, s.options.CacheTTL)
+	}
+
+	s.mu.Lock()
+	delete(s.inFlight, key)
+	s.mu.Unlock()
+}
+
+// cached returns the cached suggestions of params, or filters the suggestions of the longest
+// cached prefix of the query if they are complete. The prefixes are as long as the minimum length
+// at least, and searched with the same parameters otherwise.
+func (s *AutocompleteService) cached(params nominatim.SearchParams) ([]Suggestion, bool) {
+	if value, _, ok := s.cache.Get(searchCacheKey(params)); ok {
+		return value.(autocompleteEntry).suggestions, true
+	}
+
+	queryWords := words.Split(params.Query)
+	runes := []rune(params.Query)
+	for n := len(runes) - 1; n >= s.options.MinLength; n-- {
+		if runes[n-1] == ' ' {
+			// Not a normalized query, and the same one as the next, shorter, prefix.
+			continue
+		}
+		prefix := params
+		prefix.Query = string(runes[:n])
+		value, _, ok := s.cache.Get(searchCacheKey(prefix))
+		if !ok {
+			continue
+		}
+
+		entry := value.(autocompleteEntry)
+		if !entry.complete {
+			return nil, false
+		}
+		var filtered []Suggestion
+		for _, suggestion := range entry.suggestions {
+			if words.MatchAll(suggestion.words, queryWords) {
+				filtered = append(filtered, suggestion)
+			}
+		}
+		// The provider doesn't only match prefixes, so no match falls back to a search.
+		return filtered, len(filtered) > 0
+	}
+	return nil, false
+}
+
+func (s *AutocompleteService) searchSuggestions(ctx context.Context, params nominatim.SearchParams) ([]Suggestion, error) {
+	resp, err := s.client.Search(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("searching %q: %w", params.Query, err)
+	}
+
+	suggestions := make([]Suggestion, 0, len(resp))
+	for _, result := range resp {
+		suggestion, err := newSuggestion(result)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, nil
+}
+
+func newSuggestion(result nominatim.GeocodeResult) (Suggestion, error) {
+	lat, err := strconv.ParseFloat(result.Lat, 64)
+	if err != nil {
+		return Suggestion{}, fmt.Errorf("failed to parse latitude: %w", err)
+	}
+	lon, err := strconv.ParseFloat(result.Lon, 64)
+	if err != nil {
+		return Suggestion{}, fmt.Errorf("failed to parse longitude: %w", err)
+	}
+
+	return Suggestion{
+		Name:       result.Name,
+		Label:      result.DisplayName,
+		Type:       result.Type,
+		Lat:        lat,
+		Lon:        lon,
+		importance: result.Importance,
+		words:      words.Split(result.Name + " " + result.DisplayName),
+	}, nil
+}
+
+// proximityScale is the distance in meters at which the proximity score of a suggestion is halved.
+const proximityScale = 10000
+
+// rankSuggestions sorts suggestions by decreasing score. Without a position, the score is the
+// importance given by Nominatim. With a position, proximity counts as much as importance.
+func rankSuggestions(suggestions []Suggestion, position *Point) {
+	if position == nil {
+		sort.SliceStable(suggestions, func(i, j int) bool {
+			return suggestions[i].importance > suggestions[j].importance
+		})
+		return
+	}
+
+	for i := range suggestions {
+		distance := geo.Haversine(position.Lat, position.Lon, suggestions[i].Lat, suggestions[i].Lon)
+		suggestions[i].Distance = &distance
+		suggestions[i].score = (suggestions[i].importance + 1/(1+distance/proximityScale)) / 2
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].score > suggestions[j].score
+	})
+}
+
+// normalizeQuery lowercases a query, replaces punctuation with spaces and collapses spaces.
+func normalizeQuery(query string) string {
+	return strings.Join(words.Split(query), " ")
+}
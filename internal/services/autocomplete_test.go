@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"supmap-gis/internal/providers/nominatim"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSuggestClient records its searches and, if release is set, waits for it before answering.
+// It returns results, or two places named Caen if nil.
+type fakeSuggestClient struct {
+	release chan struct{}
+	results []nominatim.GeocodeResult
+
+	mu       sync.Mutex
+	searches []nominatim.SearchParams
+}
+
+func (c *fakeSuggestClient) Search(ctx context.Context, params nominatim.SearchParams) ([]nominatim.GeocodeResult, error) {
+	c.mu.Lock()
+	c.searches = append(c.searches, params)
+	c.mu.Unlock()
+
+	if c.release != nil {
+		select {
+		case <-c.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if c.results != nil {
+		return c.results, nil
+	}
+	return []nominatim.GeocodeResult{
+		{Name: "Caen", DisplayName: "Caen, Calvados, France", Lat: "49.18", Lon: "-0.37", Importance: 0.7},
+		{Name: "Caen", DisplayName: "Caen, Saint-Pierre-et-Miquelon", Lat: "46.78", Lon: "-56.18", Importance: 0.9},
+	}, nil
+}
+
+func (c *fakeSuggestClient) Reverse(ctx context.Context, params nominatim.ReverseParams) (*nominatim.ReverseResult, error) {
+	return nil, nil
+}
+
+func (c *fakeSuggestClient) searchCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.searches)
+}
+
+func TestSuggestCache(t *testing.T) {
+	client := &fakeSuggestClient{}
+	s := NewAutocompleteService(client)
+	ctx := context.Background()
+
+	suggest := func(query string) []Suggestion {
+		t.Helper()
+		suggestions, err := s.Suggest(ctx, query, nil, 5, "fr-FR")
+		if err != nil {
+			t.Fatalf("Suggest(%q) error = %v", query, err)
+		}
+		return suggestions
+	}
+
+	// Queries are cached once normalized.
+	suggest("Cae")
+	suggest("CAE ")
+	if got := client.searchCount(); got != 1 || client.searches[0].Query != "cae" {
+		t.Fatalf("searches = %+v, want a single search of the normalized query", client.searches)
+	}
+
+	// The results of "cae" weren't truncated, so they are filtered for the queries extending it.
+	if got := suggest("Caen"); len(got) != 2 {
+		t.Errorf("Suggest(\"Caen\") = %+v, want both places", got)
+	}
+	if got := suggest("caen, calv"); len(got) != 1 || got[0].Label != "Caen, Calvados, France" {
+		t.Errorf("Suggest(\"caen, calv\") = %+v, want the Caen of Calvados", got)
+	}
+	if got := client.searchCount(); got != 1 {
+		t.Errorf("%d searches for queries extending a cached one, want 1", got)
+	}
+
+	// When nothing cached matches, the provider is asked.
+	suggest("caen paris")
+	if got := client.searchCount(); got != 2 || client.searches[1].Query != "caen paris" {
+		t.Errorf("searches = %+v, want a search of \"caen paris\"", client.searches)
+	}
+
+	got, err := s.Suggest(ctx, "ca", nil, 5, "fr-FR")
+	if err != nil || len(got) != 0 || client.searchCount() != 2 {
+		t.Errorf("Suggest() of a short query = %v, %v, want no suggestion and no search", got, err)
+	}
+}
+
+func TestSuggestTruncatedResults(t *testing.T) {
+	var results []nominatim.GeocodeResult
+	for i := range nominatimDefaultLimit {
+		results = append(results, nominatim.GeocodeResult{Name: fmt.Sprintf("Paris %d", i), DisplayName: "Paris, France", Lat: "48.85", Lon: "2.35"})
+	}
+	client := &fakeSuggestClient{results: results}
+	s := NewAutocompleteService(client)
+	ctx := context.Background()
+
+	// The results of "par" are as many as the limit, so some places matching "pari" may be missing.
+	s.Suggest(ctx, "par", nil, 5, "fr-FR")
+	s.Suggest(ctx, "pari", nil, 5, "fr-FR")
+	if got := client.searchCount(); got != 2 {
+		t.Errorf("%d searches, want 2", got)
+	}
+}
+
+func TestSuggestCacheDisabled(t *testing.T) {
+	client := &fakeSuggestClient{}
+	s := NewAutocompleteService(client, AutocompleteOptions{MinLength: 3})
+	ctx := context.Background()
+
+	s.Suggest(ctx, "cae", nil, 5, "fr-FR")
+	s.Suggest(ctx, "cae", nil, 5, "fr-FR")
+	s.Suggest(ctx, "caen", nil, 5, "fr-FR")
+	if got := client.searchCount(); got != 3 {
+		t.Errorf("%d searches, want 3", got)
+	}
+}
+
+func TestSuggestPositionBias(t *testing.T) {
+	client := &fakeSuggestClient{}
+	s := NewAutocompleteService(client)
+	ctx := context.Background()
+
+	got, err := s.Suggest(ctx, "Caen", &Point{Lat: 49.183, Lon: -0.371}, 5, "fr-FR")
+	if err != nil {
+		t.Fatalf("Suggest() error = %v", err)
+	}
+	if len(got) != 2 || got[0].Label != "Caen, Calvados, France" || got[0].Distance == nil {
+		t.Errorf("Suggest() = %+v, want the closest Caen first", got)
+	}
+
+	params := client.searches[0]
+	if params.ViewBox == nil || params.Bounded {
+		t.Fatalf("searched %+v, want an unbounded view box", params)
+	}
+	center := Point{Lat: (params.ViewBox.MinLat + params.ViewBox.MaxLat) / 2, Lon: (params.ViewBox.MinLon + params.ViewBox.MaxLon) / 2}
+	if d := Distance(center, Point{Lat: 49.2, Lon: -0.4}); d > 1 {
+		t.Errorf("view box centered on %+v, want the rounded position", center)
+	}
+
+	// A close position shares the cached results, a farther one doesn't.
+	s.Suggest(ctx, "Caen", &Point{Lat: 49.21, Lon: -0.38}, 5, "fr-FR")
+	if got := client.searchCount(); got != 1 {
+		t.Errorf("%d searches for close positions, want 1", got)
+	}
+	s.Suggest(ctx, "Caen", &Point{Lat: 48.85, Lon: 2.35}, 5, "fr-FR")
+	s.Suggest(ctx, "Caen", nil, 5, "fr-FR")
+	if got := client.searchCount(); got != 3 {
+		t.Errorf("%d searches, want one per area", got)
+	}
+}
+
+func TestSuggestCoalescedCalls(t *testing.T) {
+	client := &fakeSuggestClient{release: make(chan struct{})}
+	s := NewAutocompleteService(client)
+
+	// The first caller leaves before the search is over.
+	firstCtx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error)
+	go func() {
+		_, err := s.Suggest(firstCtx, "Caen", nil, 5, "fr-FR")
+		firstErr <- err
+	}()
+	waitFor(t, func() bool { return client.searchCount() == 1 })
+
+	type result struct {
+		suggestions []Suggestion
+		err         error
+	}
+	second := make(chan result)
+	go func() {
+		suggestions, err := s.Suggest(context.Background(), "caen", nil, 5, "fr-FR")
+		second <- result{suggestions, err}
+	}()
+
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("first Suggest() error = %v, want context.Canceled", err)
+	}
+
+	// The second caller still gets the results of the shared search, once it has joined it.
+	time.Sleep(20 * time.Millisecond)
+	close(client.release)
+	got := <-second
+	if got.err != nil || len(got.suggestions) != 2 {
+		t.Errorf("second Suggest() = %v, %v, want 2 suggestions", got.suggestions, got.err)
+	}
+	if n := client.searchCount(); n != 1 {
+		t.Errorf("%d searches, want 1", n)
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"supmap-gis/internal/providers/nominatim"
+	"sync"
+	"time"
+)
+
+// BatchService geocodes and reverse geocodes lists of items with a bounded concurrency,
+// either synchronously or as background jobs.
+type BatchService struct {
+	geocodingService *GeocodingService
+	options          BatchOptions
+	// semaphore is shared by all the batches and jobs, so that their items don't add up
+	// to more than Concurrency calls at the same time.
+	semaphore chan struct{}
+
+	// ctx is the context of the jobs, canceled by Close.
+	ctx         context.Context
+	cancel      context.CancelFunc
+	runningJobs sync.WaitGroup
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	running int
+}
+
+type BatchOptions struct {
+	// Concurrency is the maximum number of items processed at the same time, by all the batches and jobs.
+	Concurrency int
+	// MaxRunningJobs is the maximum number of jobs running at the same time.
+	MaxRunningJobs int
+	// JobTTL is how long the results of a finished job are kept.
+	JobTTL time.Duration
+}
+
+func DefaultBatchOptions() BatchOptions {
+	return BatchOptions{
+		Concurrency:    4,
+		MaxRunningJobs: 4,
+		JobTTL:         time.Hour,
+	}
+}
+
+// ErrTooManyJobs is returned when a job is started while MaxRunningJobs jobs are running.
+var ErrTooManyJobs = errors.New("too many jobs running")
+
+// ErrBatchServiceClosed is returned when a job is started after the service was closed.
+var ErrBatchServiceClosed = errors.New("batch service closed")
+
+func NewBatchService(geocodingService *GeocodingService, options ...BatchOptions) *BatchService {
+	opts := DefaultBatchOptions()
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &BatchService{
+		geocodingService: geocodingService,
+		options:          opts,
+		semaphore:        make(chan struct{}, max(opts.Concurrency, 1)),
+		ctx:              ctx,
+		cancel:           cancel,
+		jobs:             make(map[string]*Job),
+	}
+}
+
+// Close cancels the running jobs, and waits for them to stop. Their remaining items fail with
+// the cancellation error.
+func (s *BatchService) Close() {
+	s.cancel()
+	s.runningJobs.Wait()
+}
+
+// BatchInput is an item of a batch. An item with an Err, which was found invalid while decoding
+// the batch, isn't processed and has this error in its result.
+type BatchInput[T any] struct {
+	Params T
+	Err    error
+}
+
+// BatchItem is the result of a single item of a batch. Either Result or Error is set.
+type BatchItem[T any] struct {
+	Index  int    `json:"index"`
+	Result *T     `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// GeocodeBatch geocodes each search, and returns the results in the same order.
+func (s *BatchService) GeocodeBatch(ctx context.Context, searches []BatchInput[nominatim.SearchParams]) []BatchItem[[]Place] {
+	return runBatch(ctx, searches, s.semaphore, s.search, nil)
+}
+
+// ReverseBatch reverse geocodes each position, and returns the results in the same order.
+// Positions without an address have an error.
+func (s *BatchService) ReverseBatch(ctx context.Context, positions []BatchInput[nominatim.ReverseParams]) []BatchItem[Address] {
+	return runBatch(ctx, positions, s.semaphore, s.reverse, nil)
+}
+
+func (s *BatchService) search(ctx context.Context, params nominatim.SearchParams) (*[]Place, error) {
+	places, err := s.geocodingService.Search(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return &places, nil
+}
+
+// ErrNoAddress is the error of a batch item for which no address was found.
+var ErrNoAddress = errors.New("no address found")
+
+func (s *BatchService) reverse(ctx context.Context, params nominatim.ReverseParams) (*Address, error) {
+	address, err := s.geocodingService.Reverse(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if address == nil {
+		return nil, ErrNoAddress
+	}
+	return address, nil
+}
+
+// runBatch calls fn for each valid item, each call holding a slot of semaphore.
+// Once ctx is done, the remaining items fail with its error.
+// If not nil, progress is called after each item, whether it succeeded or failed.
+func runBatch[In, Out any](ctx context.Context, items []BatchInput[In], semaphore chan struct{}, fn func(context.Context, In) (*Out, error), progress func()) []BatchItem[Out] {
+	results := make([]BatchItem[Out], len(items))
+	fail := func(i int, err error) {
+		results[i].Error = err.Error()
+		if progress != nil {
+			progress()
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, item := range items {
+		results[i].Index = i
+		if item.Err != nil {
+			fail(i, item.Err)
+			continue
+		}
+		// select picks randomly among the ready cases, so a free slot could still be taken
+		// after the cancellation.
+		if err := ctx.Err(); err != nil {
+			fail(i, err)
+			continue
+		}
+
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			fail(i, ctx.Err())
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			result, err := fn(ctx, item.Params)
+			if err != nil {
+				results[i].Error = err.Error()
+			} else {
+				results[i].Result = result
+			}
+			if progress != nil {
+				progress()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// --- Jobs ---
+
+type JobType string
+
+const (
+	JobTypeGeocode JobType = "geocode"
+	JobTypeReverse JobType = "reverse"
+)
+
+type JobStatus string
+
+const (
+	JobStatusRunning JobStatus = "running"
+	JobStatusDone    JobStatus = "done"
+)
+
+// Job is a batch processed in the background.
+type Job struct {
+	ID         string     `json:"id"`
+	Type       JobType    `json:"type"`
+	Status     JobStatus  `json:"status"`
+	Total      int        `json:"total"`
+	Completed  int        `json:"completed"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Results is a list of [BatchItem], only set once the job is done.
+	Results any `json:"results,omitempty"`
+}
+
+// StartGeocodeJob geocodes the searches in the background and returns the created job.
+func (s *BatchService) StartGeocodeJob(searches []BatchInput[nominatim.SearchParams]) (Job, error) {
+	return startJob(s, JobTypeGeocode, searches, s.search)
+}
+
+// StartReverseJob reverse geocodes the positions in the background and returns the created job.
+func (s *BatchService) StartReverseJob(positions []BatchInput[nominatim.ReverseParams]) (Job, error) {
+	return startJob(s, JobTypeReverse, positions, s.reverse)
+}
+
+// Job returns a snapshot of the job with the given ID.
+func (s *BatchService) Job(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeExpiredJobs()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+func startJob[In, Out any](s *BatchService, jobType JobType, items []BatchInput[In], fn func(context.Context, In) (*Out, error)) (Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, err
+	}
+
+	job := &Job{
+		ID:        id,
+		Type:      jobType,
+		Status:    JobStatusRunning,
+		Total:     len(items),
+		CreatedAt: time.Now(),
+	}
+
+	s.mu.Lock()
+	if s.ctx.Err() != nil {
+		s.mu.Unlock()
+		return Job{}, ErrBatchServiceClosed
+	}
+	if s.running >= s.options.MaxRunningJobs {
+		s.mu.Unlock()
+		return Job{}, ErrTooManyJobs
+	}
+	s.removeExpiredJobs()
+	s.jobs[id] = job
+	s.running++
+	s.runningJobs.Add(1)
+	snapshot := *job
+	s.mu.Unlock()
+
+	go func() {
+		defer s.runningJobs.Done()
+
+		// The job outlives the request that created it, but not the service.
+		results := runBatch(s.ctx, items, s.semaphore, fn, func() {
+			s.mu.Lock()
+			job.Completed++
+			s.mu.Unlock()
+		})
+
+		s.mu.Lock()
+		finishedAt := time.Now()
+		job.Status = JobStatusDone
+		job.FinishedAt = &finishedAt
+		job.Results = results
+		s.running--
+		s.mu.Unlock()
+	}()
+
+	return snapshot, nil
+}
+
+// removeExpiredJobs must be called with the lock held.
+func (s *BatchService) removeExpiredJobs() {
+	now := time.Now()
+	for id, job := range s.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > s.options.JobTTL {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunBatchInvalidItems(t *testing.T) {
+	items := []BatchInput[int]{{Params: 1}, {Err: errors.New("missing address")}, {Params: 3}}
+	double := func(_ context.Context, n int) (*int, error) {
+		result := n * 2
+		return &result, nil
+	}
+
+	// progress is called from the goroutines processing the items.
+	var progress atomic.Int32
+	results := runBatch(context.Background(), items, make(chan struct{}, 2), double, func() { progress.Add(1) })
+
+	if got := int(progress.Load()); got != len(items) {
+		t.Errorf("progress called %d times, want %d", got, len(items))
+	}
+	for i, result := range results {
+		if result.Index != i {
+			t.Errorf("results[%d].Index = %d", i, result.Index)
+		}
+	}
+	if results[1].Error != "missing address" || results[1].Result != nil {
+		t.Errorf("results[1] = %+v, want the item error", results[1])
+	}
+	if results[0].Result == nil || *results[0].Result != 2 || results[2].Result == nil || *results[2].Result != 6 {
+		t.Errorf("valid items not processed: %+v", results)
+	}
+}
+
+func TestRunBatchSharedConcurrency(t *testing.T) {
+	const concurrency = 2
+	semaphore := make(chan struct{}, concurrency)
+
+	var current, peak atomic.Int32
+	fn := func(context.Context, int) (*int, error) {
+		n := current.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		current.Add(-1)
+		return new(int), nil
+	}
+
+	items := make([]BatchInput[int], 10)
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runBatch(context.Background(), items, semaphore, fn, nil)
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > concurrency {
+		t.Errorf("%d concurrent calls across batches, want at most %d", got, concurrency)
+	}
+}
+
+func TestRunBatchCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var calls, progress atomic.Int32
+	fn := func(context.Context, int) (*int, error) {
+		calls.Add(1)
+		return new(int), nil
+	}
+	// The semaphore has free slots, which select could pick instead of the done context.
+	items := make([]BatchInput[int], 100)
+	results := runBatch(ctx, items, make(chan struct{}, len(items)), fn, func() { progress.Add(1) })
+
+	if got := calls.Load(); got != 0 {
+		t.Errorf("%d calls after the cancellation, want 0", got)
+	}
+	if got := int(progress.Load()); got != len(items) {
+		t.Errorf("progress called %d times, want %d", got, len(items))
+	}
+	for _, result := range results {
+		if result.Error != context.Canceled.Error() {
+			t.Errorf("item %d error = %q, want %q", result.Index, result.Error, context.Canceled)
+		}
+	}
+}
+
+func TestJobsLimitAndClose(t *testing.T) {
+	s := NewBatchService(nil, BatchOptions{Concurrency: 1, MaxRunningJobs: 1, JobTTL: time.Hour})
+
+	// The job blocks until it's canceled by Close.
+	block := func(ctx context.Context, _ int) (*int, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	job, err := startJob(s, JobTypeGeocode, []BatchInput[int]{{Params: 1}, {Params: 2}}, block)
+	if err != nil {
+		t.Fatalf("startJob() error = %v", err)
+	}
+
+	if _, err := startJob(s, JobTypeGeocode, []BatchInput[int]{{Params: 1}}, block); !errors.Is(err, ErrTooManyJobs) {
+		t.Errorf("second startJob() error = %v, want ErrTooManyJobs", err)
+	}
+
+	s.Close()
+
+	done, ok := s.Job(job.ID)
+	if !ok || done.Status != JobStatusDone || done.Completed != done.Total {
+		t.Fatalf("job after Close = %+v, want done and completed", done)
+	}
+	for _, item := range done.Results.([]BatchItem[int]) {
+		if item.Error != context.Canceled.Error() {
+			t.Errorf("item %d error = %q, want %q", item.Index, item.Error, context.Canceled)
+		}
+	}
+
+	if _, err := startJob(s, JobTypeGeocode, []BatchInput[int]{{Params: 1}}, block); !errors.Is(err, ErrBatchServiceClosed) {
+		t.Errorf("startJob() after Close error = %v, want ErrBatchServiceClosed", err)
+	}
+}
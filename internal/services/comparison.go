@@ -0,0 +1,246 @@
+package services
+
+import (
+	"math"
+	"supmap-gis/internal/geo"
+)
+
+// TripLabelType describes why a [Trip] may be preferred over the others.
+type TripLabelType string
+
+const (
+	TripLabelFastest        TripLabelType = "fastest"
+	TripLabelShortest       TripLabelType = "shortest"
+	TripLabelFewestTolls    TripLabelType = "fewest_tolls"
+	TripLabelAvoidsIncident TripLabelType = "avoids_incident"
+)
+
+type TripLabel struct {
+	Type       TripLabelType `json:"type"`
+	IncidentID *int64        `json:"incident_id,omitempty"`
+}
+
+// SharedGeometry is the ratio (between 0 and 1) of a trip's shape length that is shared
+// with the trip at TripIndex in the response.
+type SharedGeometry struct {
+	TripIndex int     `json:"trip_index"`
+	Ratio     float64 `json:"ratio"`
+}
+
+const (
+	// sharedGeometryTolerance is the maximum distance in meters between two shapes
+	// for a portion of them to be considered shared.
+	sharedGeometryTolerance = 20.0
+	// incidentOnTripTolerance is the maximum distance in meters between an incident
+	// and a shape for the incident to be considered on the trip.
+	incidentOnTripTolerance = 30.0
+)
+
+// compareTrips drops the alternates that overlap a previously kept trip by more than maxOverlap,
+// then labels the remaining trips and computes the geometry they share with each other.
+// The first trip is Valhalla's main trip and is always kept.
+func compareTrips(trips []Trip, incidents []Incident, maxOverlap float64) []Trip {
+	shapes := make([]*indexedShape, len(trips))
+	for i := range trips {
+		shapes[i] = newIndexedShape(tripShape(trips[i]))
+	}
+
+	kept := make([]int, 0, len(trips))
+	for i := range trips {
+		duplicate := false
+		for _, k := range kept {
+			overlap := math.Min(shapes[i].sharedRatio(shapes[k]), shapes[k].sharedRatio(shapes[i]))
+			if overlap > maxOverlap {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			kept = append(kept, i)
+		}
+	}
+
+	result := make([]Trip, len(kept))
+	keptShapes := make([]*indexedShape, len(kept))
+	for i, k := range kept {
+		result[i] = trips[k]
+		keptShapes[i] = shapes[k]
+	}
+
+	for i := range result {
+		result[i].SharedGeometry = make([]SharedGeometry, 0, len(result)-1)
+		for j := range result {
+			if i == j {
+				continue
+			}
+			result[i].SharedGeometry = append(result[i].SharedGeometry, SharedGeometry{
+				TripIndex: j,
+				Ratio:     keptShapes[i].sharedRatio(keptShapes[j]),
+			})
+		}
+		result[i].Labels = []TripLabel{}
+	}
+
+	labelTrips(result, keptShapes, incidents)
+
+	return result
+}
+
+// labelTrips adds labels to trips when they stand out from the others.
+// Nothing is labelled when there is a single trip, since there is nothing to compare.
+func labelTrips(trips []Trip, shapes []*indexedShape, incidents []Incident) {
+	if len(trips) < 2 {
+		return
+	}
+
+	addBestLabel(trips, TripLabelFastest, func(t Trip) float64 { return t.Summary.Time })
+	addBestLabel(trips, TripLabelShortest, func(t Trip) float64 { return t.Summary.Length })
+	addBestLabel(trips, TripLabelFewestTolls, tollLength)
+
+	for _, incident := range incidents {
+		onTrip := make([]bool, len(trips))
+		onAny := false
+		for i, shape := range shapes {
+			onTrip[i] = shape.isNear(incident.Point, incidentOnTripTolerance)
+			onAny = onAny || onTrip[i]
+		}
+		if !onAny {
+			continue
+		}
+		for i := range trips {
+			if !onTrip[i] {
+				id := incident.ID
+				trips[i].Labels = append(trips[i].Labels, TripLabel{Type: TripLabelAvoidsIncident, IncidentID: &id})
+			}
+		}
+	}
+}
+
+// addBestLabel labels the trip with the strictly lowest value. Ties are not labelled.
+func addBestLabel(trips []Trip, label TripLabelType, value func(Trip) float64) {
+	best, bestValue, tie := 0, value(trips[0]), false
+	for i := 1; i < len(trips); i++ {
+		v := value(trips[i])
+		switch {
+		case v < bestValue:
+			best, bestValue, tie = i, v, false
+		case v == bestValue:
+			tie = true
+		}
+	}
+	if !tie {
+		trips[best].Labels = append(trips[best].Labels, TripLabel{Type: label})
+	}
+}
+
+// tollLength returns the length of the trip's maneuvers that are on toll roads.
+func tollLength(t Trip) float64 {
+	var length float64
+	for _, leg := range t.Legs {
+		for _, m := range leg.Maneuvers {
+			if m.Toll {
+				length += m.Length
+			}
+		}
+	}
+	return length
+}
+
+// tripShape concatenates the shapes of all the legs of a trip.
+func tripShape(t Trip) []Point {
+	var shape []Point
+	for _, leg := range t.Legs {
+		shape = append(shape, leg.Shape...)
+	}
+	return shape
+}
+
+// indexedShape is a polyline whose segments are indexed in a grid,
+// so that finding the segments near a point doesn't require scanning the whole shape.
+type indexedShape struct {
+	points []Point
+	cells  map[gridCell][]int // segment indexes by cell
+}
+
+type gridCell struct {
+	x, y int
+}
+
+// gridCellSize is the size of a cell of the index in degrees (about 100m of latitude).
+const gridCellSize = 0.001
+
+func cellOf(p Point) gridCell {
+	return gridCell{x: int(math.Floor(p.Lon / gridCellSize)), y: int(math.Floor(p.Lat / gridCellSize))}
+}
+
+func newIndexedShape(points []Point) *indexedShape {
+	s := &indexedShape{points: points, cells: make(map[gridCell][]int)}
+	for i := 0; i+1 < len(points); i++ {
+		a, b := points[i], points[i+1]
+		minCell := cellOf(Point{Lat: math.Min(a.Lat, b.Lat), Lon: math.Min(a.Lon, b.Lon)})
+		maxCell := cellOf(Point{Lat: math.Max(a.Lat, b.Lat), Lon: math.Max(a.Lon, b.Lon)})
+		for x := minCell.x; x <= maxCell.x; x++ {
+			for y := minCell.y; y <= maxCell.y; y++ {
+				cell := gridCell{x: x, y: y}
+				s.cells[cell] = append(s.cells[cell], i)
+			}
+		}
+	}
+	return s
+}
+
+// isNear reports whether p is within tolerance meters of the shape.
+// The tolerance must be smaller than a grid cell.
+func (s *indexedShape) isNear(p Point, tolerance float64) bool {
+	if len(s.points) == 1 {
+		return geo.Haversine(p.Lat, p.Lon, s.points[0].Lat, s.points[0].Lon) <= tolerance
+	}
+
+	c := cellOf(p)
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			for _, i := range s.cells[gridCell{x: c.x + dx, y: c.y + dy}] {
+				if distanceToSegment(p, s.points[i], s.points[i+1]) <= tolerance {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// sharedRatio returns the ratio of the length of s that lies within [sharedGeometryTolerance] of other.
+func (s *indexedShape) sharedRatio(other *indexedShape) float64 {
+	var total, shared float64
+	for i := 0; i+1 < len(s.points); i++ {
+		a, b := s.points[i], s.points[i+1]
+		length := geo.Haversine(a.Lat, a.Lon, b.Lat, b.Lon)
+		total += length
+
+		middle := Point{Lat: (a.Lat + b.Lat) / 2, Lon: (a.Lon + b.Lon) / 2}
+		if other.isNear(a, sharedGeometryTolerance) && other.isNear(middle, sharedGeometryTolerance) && other.isNear(b, sharedGeometryTolerance) {
+			shared += length
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return shared / total
+}
+
+// distanceToSegment returns the distance in meters between p and the segment [a, b],
+// using an equirectangular projection centered on p, which is accurate at short distances.
+func distanceToSegment(p, a, b Point) float64 {
+	const metersPerDegree = 6371000 * math.Pi / 180
+	cosLat := math.Cos(p.Lat * math.Pi / 180)
+
+	ax, ay := (a.Lon-p.Lon)*metersPerDegree*cosLat, (a.Lat-p.Lat)*metersPerDegree
+	bx, by := (b.Lon-p.Lon)*metersPerDegree*cosLat, (b.Lat-p.Lat)*metersPerDegree
+
+	dx, dy := bx-ax, by-ay
+	t := 0.0
+	if lengthSquared := dx*dx + dy*dy; lengthSquared > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/lengthSquared))
+	}
+	return math.Hypot(ax+t*dx, ay+t*dy)
+}
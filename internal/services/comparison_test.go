@@ -0,0 +1,246 @@
+package services
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// The test routes go north from (49, 0), a degree of latitude being about 111 km, and a degree of
+// longitude about 73 km.
+var (
+	// straightShape goes 2.2 km due north, with a point every 111 m.
+	straightShape = densify(Point{Lat: 49, Lon: 0}, Point{Lat: 49.02, Lon: 0}, 20)
+	// halfSharedShape follows the first half of straightShape, then turns east for as long.
+	halfSharedShape = append(densify(Point{Lat: 49, Lon: 0}, Point{Lat: 49.01, Lon: 0}, 10), Point{Lat: 49.01, Lon: 0.0152})
+	// eastShape goes 2.2 km due north, 1.5 km east of straightShape.
+	eastShape = densify(Point{Lat: 49, Lon: 0.02}, Point{Lat: 49.02, Lon: 0.02}, 20)
+)
+
+func testTrip(time, length float64, shape []Point, maneuvers ...Maneuver) Trip {
+	return Trip{
+		Legs:    []Leg{{Shape: shape, Maneuvers: maneuvers}},
+		Summary: Summary{Time: time, Length: length},
+	}
+}
+
+func labelTypes(trip Trip) []TripLabelType {
+	types := make([]TripLabelType, len(trip.Labels))
+	for i, label := range trip.Labels {
+		types[i] = label.Type
+	}
+	return types
+}
+
+func TestCompareTrips(t *testing.T) {
+	trips := []Trip{
+		testTrip(600, 2200, straightShape),
+		// The same route with another duration is dropped.
+		testTrip(500, 2200, straightShape),
+		testTrip(700, 2100, halfSharedShape),
+		testTrip(650, 2300, eastShape),
+	}
+
+	got := compareTrips(trips, nil, 0.8)
+
+	if len(got) != 3 {
+		t.Fatalf("%d trips, want 3", len(got))
+	}
+	if got[0].Summary.Time != 600 || got[1].Summary.Time != 700 || got[2].Summary.Time != 650 {
+		t.Errorf("kept trips of %v, %v and %v s, want the main trip and the distinct alternates", got[0].Summary.Time, got[1].Summary.Time, got[2].Summary.Time)
+	}
+
+	// The indexes of the shared geometries are the ones of the kept trips.
+	for i, trip := range got {
+		if len(trip.SharedGeometry) != 2 {
+			t.Fatalf("trip %d shares geometry with %d trips, want 2", i, len(trip.SharedGeometry))
+		}
+		for _, shared := range trip.SharedGeometry {
+			if shared.TripIndex == i || shared.TripIndex >= len(got) {
+				t.Errorf("trip %d shares geometry with trip %d", i, shared.TripIndex)
+			}
+		}
+	}
+	if ratio := got[0].SharedGeometry[0].Ratio; math.Abs(ratio-0.5) > 0.02 {
+		t.Errorf("main trip shares %.2f with the half shared one, want 0.5", ratio)
+	}
+	if ratio := got[1].SharedGeometry[0].Ratio; math.Abs(ratio-0.5) > 0.02 {
+		t.Errorf("half shared trip shares %.2f with the main one, want 0.5", ratio)
+	}
+	if ratio := got[0].SharedGeometry[1].Ratio; ratio != 0 {
+		t.Errorf("main trip shares %.2f with the east one, want 0", ratio)
+	}
+
+	wantLabels := [][]TripLabelType{
+		{TripLabelFastest},
+		{TripLabelShortest},
+		// No trip has tolls, which is a tie.
+		{},
+	}
+	for i, want := range wantLabels {
+		if labels := labelTypes(got[i]); !slices.Equal(labels, want) {
+			t.Errorf("labels of trip %d = %v, want %v", i, labels, want)
+		}
+	}
+}
+
+func TestCompareSingleTrip(t *testing.T) {
+	got := compareTrips([]Trip{testTrip(600, 2200, straightShape)}, []Incident{{Point: straightShape[3], ID: 1}}, 0.8)
+
+	if len(got) != 1 {
+		t.Fatalf("%d trips, want 1", len(got))
+	}
+	// There is nothing to compare, but the fields are still set.
+	if got[0].Labels == nil || len(got[0].Labels) != 0 {
+		t.Errorf("labels = %#v, want none", got[0].Labels)
+	}
+	if got[0].SharedGeometry == nil || len(got[0].SharedGeometry) != 0 {
+		t.Errorf("shared geometry = %#v, want none", got[0].SharedGeometry)
+	}
+}
+
+func TestCompareIdenticalTrips(t *testing.T) {
+	// Identical trips overlap completely, which is more than any maximum overlap below 1.
+	trips := []Trip{testTrip(600, 2200, straightShape), testTrip(600, 2200, straightShape)}
+	if got := compareTrips(trips, nil, 0.99); len(got) != 1 {
+		t.Errorf("%d trips, want 1", len(got))
+	}
+	// With a maximum overlap of 1, nothing is dropped, and identical trips tie for every label.
+	got := compareTrips(trips, nil, 1)
+	if len(got) != 2 {
+		t.Fatalf("%d trips, want 2", len(got))
+	}
+	for i := range got {
+		if len(got[i].Labels) != 0 {
+			t.Errorf("labels of trip %d = %v, want none", i, labelTypes(got[i]))
+		}
+		if ratio := got[i].SharedGeometry[0].Ratio; ratio != 1 {
+			t.Errorf("trip %d shares %.2f, want 1", i, ratio)
+		}
+	}
+}
+
+func TestLabelTripsIncidents(t *testing.T) {
+	trips := []Trip{
+		testTrip(600, 2200, straightShape),
+		testTrip(650, 2300, eastShape),
+		testTrip(700, 2100, halfSharedShape),
+	}
+	shapes := make([]*indexedShape, len(trips))
+	for i := range trips {
+		shapes[i] = newIndexedShape(tripShape(trips[i]))
+	}
+
+	labelTrips(trips, shapes, []Incident{
+		// 10 m away from the northern half of straightShape only.
+		{Point: Point{Lat: 49.015, Lon: 0.00013}, ID: 1},
+		// On the first half of both straightShape and halfSharedShape.
+		{Point: Point{Lat: 49.005, Lon: 0}, ID: 2},
+		// Far from every trip.
+		{Point: Point{Lat: 48, Lon: 0}, ID: 3},
+	})
+
+	avoided := func(trip Trip) []int64 {
+		var ids []int64
+		for _, label := range trip.Labels {
+			if label.Type == TripLabelAvoidsIncident {
+				ids = append(ids, *label.IncidentID)
+			}
+		}
+		return ids
+	}
+	wantAvoided := [][]int64{nil, {1, 2}, {1}}
+	for i, want := range wantAvoided {
+		if got := avoided(trips[i]); !slices.Equal(got, want) {
+			t.Errorf("trip %d avoids incidents %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestAddBestLabel(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   int // -1 if no trip is labelled
+	}{
+		{"lowest first", []float64{1, 2, 3}, 0},
+		{"lowest last", []float64{3, 2, 1}, 2},
+		{"tie for the lowest", []float64{2, 1, 1}, -1},
+		{"tie above the lowest", []float64{2, 2, 1}, 2},
+		{"all equal", []float64{1, 1}, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trips := make([]Trip, len(tt.values))
+			for i, v := range tt.values {
+				trips[i].Summary.Time = v
+			}
+			addBestLabel(trips, TripLabelFastest, func(t Trip) float64 { return t.Summary.Time })
+
+			for i, trip := range trips {
+				if labelled := len(trip.Labels) > 0; labelled != (i == tt.want) {
+					t.Errorf("trip %d labelled = %v, want %v", i, labelled, i == tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFewestTollsLabel(t *testing.T) {
+	trips := []Trip{
+		testTrip(600, 2200, straightShape, Maneuver{Length: 1.5, Toll: true}, Maneuver{Length: 0.7}),
+		testTrip(650, 2300, eastShape, Maneuver{Length: 0.5, Toll: true}, Maneuver{Length: 1.8}),
+	}
+	labelTrips(trips, []*indexedShape{newIndexedShape(straightShape), newIndexedShape(eastShape)}, nil)
+
+	if labels := labelTypes(trips[1]); !slices.Contains(labels, TripLabelFewestTolls) {
+		t.Errorf("labels of the trip with fewer tolls = %v, want %s", labels, TripLabelFewestTolls)
+	}
+}
+
+func TestIndexedShapeIsNear(t *testing.T) {
+	// A segment just south of a boundary between two rows of cells, and crossing several columns.
+	south := gridCellSize*49001 - 0.000001
+	shape := newIndexedShape([]Point{{Lat: south, Lon: 0.0005}, {Lat: south, Lon: 0.0045}})
+
+	if north := cellOf(Point{Lat: south + 0.000002, Lon: 0.002}); north.y != cellOf(shape.points[0]).y+1 {
+		t.Fatalf("the segment isn't just south of a cell boundary")
+	}
+
+	tests := []struct {
+		name  string
+		point Point
+		want  bool
+	}{
+		{"on the segment", Point{Lat: south, Lon: 0.002}, true},
+		// The point is in the cell north of the segment's one.
+		{"across the cell boundary", Point{Lat: south + 0.000002, Lon: 0.002}, true},
+		{"15 m north, across the boundary", Point{Lat: south + 0.000135, Lon: 0.0042}, true},
+		{"25 m north", Point{Lat: south + 0.000225, Lon: 0.002}, false},
+		{"beyond the end of the segment", Point{Lat: south, Lon: 0.0050}, false},
+		{"near the end of the segment", Point{Lat: south, Lon: 0.0047}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shape.isNear(tt.point, sharedGeometryTolerance); got != tt.want {
+				t.Errorf("isNear() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSharedRatio(t *testing.T) {
+	straight := newIndexedShape(straightShape)
+	// 10 m east of straightShape, within the tolerance.
+	parallel := newIndexedShape(densify(Point{Lat: 49, Lon: 0.00013}, Point{Lat: 49.02, Lon: 0.00013}, 20))
+
+	if ratio := straight.sharedRatio(parallel); ratio != 1 {
+		t.Errorf("ratio with a close parallel shape = %v, want 1", ratio)
+	}
+	if ratio := straight.sharedRatio(newIndexedShape(eastShape)); ratio != 0 {
+		t.Errorf("ratio with a distant shape = %v, want 0", ratio)
+	}
+	if ratio := newIndexedShape([]Point{{Lat: 49, Lon: 0}}).sharedRatio(straight); ratio != 0 {
+		t.Errorf("ratio of a single point = %v, want 0", ratio)
+	}
+}
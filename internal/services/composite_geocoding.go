@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"supmap-gis/internal/geo"
+	"supmap-gis/internal/providers/nominatim"
+	"sync"
+)
+
+// GeocodingMode is how a [CompositeGeocodingClient] combines its backends.
+type GeocodingMode string
+
+const (
+	// GeocodingModeFailover returns the results of the first backend, in priority order,
+	// that succeeds with at least one result.
+	GeocodingModeFailover GeocodingMode = "failover"
+	// GeocodingModeMerge queries all the backends and merges their results, in priority order,
+	// without duplicates.
+	GeocodingModeMerge GeocodingMode = "merge"
+)
+
+// GeocodingBackend is a named [GeocodingClient], the name being used in error messages.
+type GeocodingBackend struct {
+	Name   string
+	Client GeocodingClient
+}
+
+// CompositeGeocodingClient is a [GeocodingClient] backed by several backends in priority order.
+// Reverse geocoding always fails over, since a single address is returned.
+type CompositeGeocodingClient struct {
+	backends []GeocodingBackend
+	mode     GeocodingMode
+}
+
+func NewCompositeGeocodingClient(mode GeocodingMode, backends ...GeocodingBackend) *CompositeGeocodingClient {
+	return &CompositeGeocodingClient{
+		backends: backends,
+		mode:     mode,
+	}
+}
+
+// mergeDistance is the distance in meters under which two results with the same name are
+// considered to be the same place.
+const mergeDistance = 50
+
+func (c *CompositeGeocodingClient) Search(ctx context.Context, params nominatim.SearchParams) ([]nominatim.GeocodeResult, error) {
+	if c.mode == GeocodingModeMerge {
+		return c.mergeSearch(ctx, params)
+	}
+
+	var errs []error
+	for _, backend := range c.backends {
+		results, err := backend.Client.Search(ctx, params)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", backend.Name, err))
+			continue
+		}
+		if len(results) > 0 {
+			return results, nil
+		}
+	}
+	if len(errs) == len(c.backends) {
+		return nil, errors.Join(errs...)
+	}
+	return []nominatim.GeocodeResult{}, nil
+}
+
+func (c *CompositeGeocodingClient) mergeSearch(ctx context.Context, params nominatim.SearchParams) ([]nominatim.GeocodeResult, error) {
+	results := make([][]nominatim.GeocodeResult, len(c.backends))
+	errs := make([]error, len(c.backends))
+
+	var wg sync.WaitGroup
+	for i, backend := range c.backends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = backend.Client.Search(ctx, params)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("%s: %w", backend.Name, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed == len(c.backends) {
+		return nil, errors.Join(errs...)
+	}
+
+	limit := searchLimit(params)
+	merged := make([]nominatim.GeocodeResult, 0, limit)
+	for _, backendResults := range results {
+		for _, result := range backendResults {
+			if len(merged) == limit {
+				return merged, nil
+			}
+			if !containsPlace(merged, result) {
+				merged = append(merged, result)
+			}
+		}
+	}
+	return merged, nil
+}
+
+// containsPlace reports whether result is the same place as one of results: either the same
+// OSM object, or a place with the same name nearby.
+func containsPlace(results []nominatim.GeocodeResult, result nominatim.GeocodeResult) bool {
+	for _, other := range results {
+		if result.OSMID != 0 && result.OSMType == other.OSMType && result.OSMID == other.OSMID {
+			return true
+		}
+		if normalizeQuery(result.Name) != normalizeQuery(other.Name) {
+			continue
+		}
+		lat1, lon1, ok1 := parseCoordinates(result.Lat, result.Lon)
+		lat2, lon2, ok2 := parseCoordinates(other.Lat, other.Lon)
+		if ok1 && ok2 && geo.Haversine(lat1, lon1, lat2, lon2) <= mergeDistance {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CompositeGeocodingClient) Reverse(ctx context.Context, params nominatim.ReverseParams) (*nominatim.ReverseResult, error) {
+	var errs []error
+	for _, backend := range c.backends {
+		result, err := backend.Client.Reverse(ctx, params)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", backend.Name, err))
+			continue
+		}
+		if result != nil && len(result.Features) > 0 {
+			return result, nil
+		}
+	}
+	if len(errs) == len(c.backends) {
+		return nil, errors.Join(errs...)
+	}
+	return &nominatim.ReverseResult{}, nil
+}
+
+func parseCoordinates(lat, lon string) (float64, float64, bool) {
+	parsedLat, errLat := strconv.ParseFloat(lat, 64)
+	parsedLon, errLon := strconv.ParseFloat(lon, 64)
+	return parsedLat, parsedLon, errLat == nil && errLon == nil
+}
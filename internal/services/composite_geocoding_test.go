@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"supmap-gis/internal/providers/nominatim"
+	"testing"
+)
+
+// fakeGeocodingClient returns the same results, or error, for every search and reverse lookup.
+type fakeGeocodingClient struct {
+	results []nominatim.GeocodeResult
+	reverse *nominatim.ReverseResult
+	err     error
+	calls   int
+}
+
+func (c *fakeGeocodingClient) Search(ctx context.Context, params nominatim.SearchParams) ([]nominatim.GeocodeResult, error) {
+	c.calls++
+	return c.results, c.err
+}
+
+func (c *fakeGeocodingClient) Reverse(ctx context.Context, params nominatim.ReverseParams) (*nominatim.ReverseResult, error) {
+	c.calls++
+	return c.reverse, c.err
+}
+
+func geocodeResult(osmID int64, name, lat, lon string) nominatim.GeocodeResult {
+	return nominatim.GeocodeResult{OSMType: "node", OSMID: osmID, Name: name, Lat: lat, Lon: lon}
+}
+
+func resultIDs(results []nominatim.GeocodeResult) []int64 {
+	ids := make([]int64, len(results))
+	for i, result := range results {
+		ids[i] = result.OSMID
+	}
+	return ids
+}
+
+func TestCompositeSearchFailover(t *testing.T) {
+	failing := &fakeGeocodingClient{err: errors.New("unavailable")}
+	empty := &fakeGeocodingClient{}
+	first := &fakeGeocodingClient{results: []nominatim.GeocodeResult{geocodeResult(1, "Caen", "49.18", "-0.37")}}
+	second := &fakeGeocodingClient{results: []nominatim.GeocodeResult{geocodeResult(2, "Caen", "49.18", "-0.37")}}
+
+	client := NewCompositeGeocodingClient(GeocodingModeFailover,
+		GeocodingBackend{Name: "failing", Client: failing},
+		GeocodingBackend{Name: "empty", Client: empty},
+		GeocodingBackend{Name: "first", Client: first},
+		GeocodingBackend{Name: "second", Client: second},
+	)
+	results, err := client.Search(t.Context(), nominatim.SearchParams{Query: "caen"})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if ids := resultIDs(results); len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("results = %v, want the ones of the first backend with results", ids)
+	}
+	if second.calls != 0 {
+		t.Errorf("%d searches after a backend had results", second.calls)
+	}
+
+	// An empty result is not an error, unless every backend failed.
+	client = NewCompositeGeocodingClient(GeocodingModeFailover,
+		GeocodingBackend{Name: "failing", Client: failing},
+		GeocodingBackend{Name: "empty", Client: empty},
+	)
+	if results, err := client.Search(t.Context(), nominatim.SearchParams{Query: "caen"}); err != nil || results == nil || len(results) != 0 {
+		t.Errorf("Search() = %v, %v, want no results", results, err)
+	}
+	client = NewCompositeGeocodingClient(GeocodingModeFailover,
+		GeocodingBackend{Name: "failing", Client: failing},
+		GeocodingBackend{Name: "other", Client: &fakeGeocodingClient{err: errors.New("timeout")}},
+	)
+	_, err = client.Search(t.Context(), nominatim.SearchParams{Query: "caen"})
+	if err == nil || !strings.Contains(err.Error(), "failing: unavailable") || !strings.Contains(err.Error(), "other: timeout") {
+		t.Errorf("Search() error = %v, want the errors of every backend", err)
+	}
+}
+
+func TestCompositeSearchMerge(t *testing.T) {
+	primary := &fakeGeocodingClient{results: []nominatim.GeocodeResult{
+		geocodeResult(1, "Gare de Caen", "49.1767", "-0.3481"),
+		geocodeResult(2, "Hôtel de Ville", "49.1829", "-0.3707"),
+	}}
+	secondary := &fakeGeocodingClient{results: []nominatim.GeocodeResult{
+		// The same OSM object.
+		geocodeResult(2, "Hôtel de ville de Caen", "49.1829", "-0.3707"),
+		// The same name, written differently, about 30 m away.
+		geocodeResult(10, "gare de CAEN", "49.1769", "-0.3479"),
+		// The same name about 200 m away.
+		geocodeResult(11, "Gare de Caen", "49.1785", "-0.3481"),
+		// Without OSM id nor coordinates.
+		{Name: "Château de Caen"},
+		geocodeResult(12, "Abbaye aux Hommes", "49.1811", "-0.3725"),
+	}}
+
+	tests := []struct {
+		name     string
+		backends []GeocodingBackend
+		limit    int
+		want     []int64
+	}{
+		{"backend order", []GeocodingBackend{{"primary", primary}, {"secondary", secondary}}, 0, []int64{1, 2, 11, 0, 12}},
+		{"other backend order", []GeocodingBackend{{"secondary", secondary}, {"primary", primary}}, 0, []int64{2, 10, 11, 0, 12}},
+		{"limit", []GeocodingBackend{{"primary", primary}, {"secondary", secondary}}, 3, []int64{1, 2, 11}},
+		{"failing backend", []GeocodingBackend{{"failing", &fakeGeocodingClient{err: errors.New("unavailable")}}, {"primary", primary}}, 0, []int64{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewCompositeGeocodingClient(GeocodingModeMerge, tt.backends...)
+			results, err := client.Search(t.Context(), nominatim.SearchParams{Query: "caen", Limit: tt.limit})
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if got := resultIDs(results); !slices.Equal(got, tt.want) {
+				t.Errorf("results = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	client := NewCompositeGeocodingClient(GeocodingModeMerge,
+		GeocodingBackend{Name: "failing", Client: &fakeGeocodingClient{err: errors.New("unavailable")}},
+	)
+	if _, err := client.Search(t.Context(), nominatim.SearchParams{Query: "caen"}); err == nil {
+		t.Error("Search() succeeded with every backend failing")
+	}
+}
+
+func TestCompositeReverse(t *testing.T) {
+	found := &nominatim.ReverseResult{Features: []nominatim.ReverseFeature{{}}}
+	merged := NewCompositeGeocodingClient(GeocodingModeMerge,
+		GeocodingBackend{Name: "failing", Client: &fakeGeocodingClient{err: errors.New("unavailable")}},
+		GeocodingBackend{Name: "empty", Client: &fakeGeocodingClient{reverse: &nominatim.ReverseResult{}}},
+		GeocodingBackend{Name: "found", Client: &fakeGeocodingClient{reverse: found}},
+	)
+	// Reverse geocoding fails over even when merging.
+	if result, err := merged.Reverse(t.Context(), nominatim.ReverseParams{Lat: 49.18, Lon: -0.37}); err != nil || result != found {
+		t.Errorf("Reverse() = %v, %v, want the result of the last backend", result, err)
+	}
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"errors"
+	"supmap-gis/internal/providers/valhalla"
+)
+
+// VehicleProfileName identifies a [VehicleProfile].
+type VehicleProfileName string
+
+const (
+	VehicleProfileCombustionCar VehicleProfileName = "combustion_car"
+	VehicleProfileElectricCar   VehicleProfileName = "electric_car"
+	VehicleProfileTruck         VehicleProfileName = "truck"
+	VehicleProfileScooter       VehicleProfileName = "scooter"
+)
+
+// ErrUnknownVehicleProfile is returned when the requested vehicle profile doesn't exist.
+var ErrUnknownVehicleProfile = errors.New("unknown vehicle profile")
+
+// VehicleProfile estimates the consumption and the emissions of a vehicle.
+type VehicleProfile interface {
+	Estimate(usage RoadUsage) Consumption
+}
+
+// RoadUsage describes how a vehicle is driven along a trip.
+type RoadUsage struct {
+	// UrbanKm, ExtraUrbanKm and HighwayKm are the distances driven in town (below 50 km/h),
+	// outside town and on highways, in kilometers.
+	UrbanKm      float64
+	ExtraUrbanKm float64
+	HighwayKm    float64
+	// Ascent and Descent are the cumulated elevation gain and loss in meters, 0 if unknown.
+	Ascent  float64
+	Descent float64
+}
+
+// Consumption is an estimation of the fuel or energy consumed and CO2 emitted by a vehicle.
+type Consumption struct {
+	VehicleProfile VehicleProfileName `json:"vehicle_profile"`
+	FuelLiters     float64            `json:"fuel_liters"`
+	EnergyKWh      float64            `json:"energy_kwh"`
+	CO2Kg          float64            `json:"co2_kg"`
+}
+
+// EnergySource is the energy used by a [ConsumptionModel].
+type EnergySource int
+
+const (
+	EnergyPetrol EnergySource = iota
+	EnergyDiesel
+	EnergyElectricity
+)
+
+const (
+	gravity = 9.81 // m/s²
+	// Energy densities, in joules per liter.
+	petrolEnergyDensity = 34.2e6
+	dieselEnergyDensity = 38.6e6
+	joulesPerKWh        = 3.6e6
+)
+
+// ConsumptionModel is a [VehicleProfile] based on average consumptions per road type,
+// corrected by the energy needed to climb the elevation gain of the trip.
+type ConsumptionModel struct {
+	Name   VehicleProfileName
+	Energy EnergySource
+	MassKg float64
+	// Urban, ExtraUrban and Highway are the consumptions per 100 km, in liters of fuel
+	// or in kWh depending on the energy source.
+	Urban      float64
+	ExtraUrban float64
+	Highway    float64
+	// CO2PerUnit is the CO2 emitted per liter of fuel or per kWh, in kg.
+	CO2PerUnit float64
+	// Efficiency is the ratio of the energy used that actually moves the vehicle.
+	Efficiency float64
+	// Regeneration is the ratio of the potential energy recovered when going downhill,
+	// by regenerative braking or by cutting off fuel injection.
+	Regeneration float64
+}
+
+func (m ConsumptionModel) Estimate(usage RoadUsage) Consumption {
+	units := (usage.UrbanKm*m.Urban + usage.ExtraUrbanKm*m.ExtraUrban + usage.HighwayKm*m.Highway) / 100
+
+	// The consumptions per road type are for flat roads: climbing costs the potential energy
+	// gained, part of which is recovered when going downhill.
+	climbing := m.MassKg * gravity * (usage.Ascent - usage.Descent*m.Regeneration) / m.Efficiency
+	units += climbing / m.joulesPerUnit()
+	units = max(units, 0)
+
+	consumption := Consumption{
+		VehicleProfile: m.Name,
+		CO2Kg:          units * m.CO2PerUnit,
+	}
+	if m.Energy == EnergyElectricity {
+		consumption.EnergyKWh = units
+	} else {
+		consumption.FuelLiters = units
+	}
+	return consumption
+}
+
+func (m ConsumptionModel) joulesPerUnit() float64 {
+	switch m.Energy {
+	case EnergyDiesel:
+		return dieselEnergyDensity
+	case EnergyElectricity:
+		return joulesPerKWh
+	default:
+		return petrolEnergyDensity
+	}
+}
+
+// DefaultVehicleProfiles returns the built-in vehicle profiles. Consumptions are averages for
+// recent vehicles, and the CO2 of electricity is the one of the French electricity mix.
+func DefaultVehicleProfiles() map[VehicleProfileName]VehicleProfile {
+	return map[VehicleProfileName]VehicleProfile{
+		VehicleProfileCombustionCar: ConsumptionModel{
+			Name: VehicleProfileCombustionCar, Energy: EnergyPetrol, MassKg: 1300,
+			Urban: 7.5, ExtraUrban: 5, Highway: 6.5,
+			CO2PerUnit: 2.31, Efficiency: 0.25, Regeneration: 0.3,
+		},
+		VehicleProfileElectricCar: ConsumptionModel{
+			Name: VehicleProfileElectricCar, Energy: EnergyElectricity, MassKg: 1700,
+			Urban: 14, ExtraUrban: 13, Highway: 19,
+			CO2PerUnit: 0.052, Efficiency: 0.85, Regeneration: 0.6,
+		},
+		VehicleProfileTruck: ConsumptionModel{
+			Name: VehicleProfileTruck, Energy: EnergyDiesel, MassKg: 20000,
+			Urban: 40, ExtraUrban: 30, Highway: 33,
+			CO2PerUnit: 2.68, Efficiency: 0.35, Regeneration: 0.3,
+		},
+		VehicleProfileScooter: ConsumptionModel{
+			Name: VehicleProfileScooter, Energy: EnergyPetrol, MassKg: 250,
+			Urban: 3, ExtraUrban: 2.8, Highway: 3.5,
+			CO2PerUnit: 2.31, Efficiency: 0.2, Regeneration: 0.3,
+		},
+	}
+}
+
+// defaultVehicleProfileName returns the vehicle profile used for a costing when none is requested.
+// Costings without a motor don't have one.
+func defaultVehicleProfileName(costing valhalla.Costing) (VehicleProfileName, bool) {
+	switch costing {
+	case valhalla.CostingAuto:
+		return VehicleProfileCombustionCar, true
+	case valhalla.CostingTruck:
+		return VehicleProfileTruck, true
+	case valhalla.CostingMotorScooter:
+		return VehicleProfileScooter, true
+	default:
+		return "", false
+	}
+}
+
+// urbanSpeedLimit is the average speed in km/h below which a maneuver is considered urban.
+const urbanSpeedLimit = 50
+
+const kilometersPerMile = 1.609344
+
+// tripRoadUsage classifies the maneuvers of a trip by road type. Elevation is taken into account
+// when the legs have an elevation profile.
+func tripRoadUsage(t Trip) RoadUsage {
+	toKm := 1.0
+	if t.Units == valhalla.UnitsMiles {
+		toKm = kilometersPerMile
+	}
+
+	var usage RoadUsage
+	for _, leg := range t.Legs {
+		for _, m := range leg.Maneuvers {
+			km := m.Length * toKm
+			switch {
+			case m.Highway:
+				usage.HighwayKm += km
+			case m.Time > 0 && km/(m.Time/3600) < urbanSpeedLimit:
+				usage.UrbanKm += km
+			default:
+				usage.ExtraUrbanKm += km
+			}
+		}
+		if leg.Elevation != nil {
+			usage.Ascent += leg.Elevation.Ascent
+			usage.Descent += leg.Elevation.Descent
+		}
+	}
+	return usage
+}
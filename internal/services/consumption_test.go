@@ -0,0 +1,129 @@
+package services
+
+import (
+	"math"
+	"supmap-gis/internal/providers/valhalla"
+	"testing"
+)
+
+func TestTripRoadUsage(t *testing.T) {
+	trip := Trip{
+		Legs: []Leg{
+			{
+				Maneuvers: []Maneuver{
+					{Length: 10, Time: 300, Highway: true},
+					// 0.7 km in a minute is 42 km/h, but 0.7 mile is 68 km/h.
+					{Length: 0.7, Time: 60},
+					// A maneuver without time isn't considered urban.
+					{Length: 0.3, Time: 0},
+				},
+				Elevation: &ElevationProfile{Ascent: 120, Descent: 30},
+			},
+			{
+				Maneuvers: []Maneuver{{Length: 5, Time: 240}},
+				Elevation: &ElevationProfile{Ascent: 10, Descent: 50},
+			},
+		},
+	}
+
+	tests := []struct {
+		units valhalla.Units
+		want  RoadUsage
+	}{
+		{valhalla.UnitsKilometers, RoadUsage{UrbanKm: 0.7, ExtraUrbanKm: 5.3, HighwayKm: 10, Ascent: 130, Descent: 80}},
+		{valhalla.UnitsMiles, RoadUsage{
+			UrbanKm:      0,
+			ExtraUrbanKm: 6 * kilometersPerMile,
+			HighwayKm:    10 * kilometersPerMile,
+			Ascent:       130,
+			Descent:      80,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.units), func(t *testing.T) {
+			trip.Units = tt.units
+			got := tripRoadUsage(trip)
+			for _, field := range []struct {
+				name      string
+				got, want float64
+			}{
+				{"UrbanKm", got.UrbanKm, tt.want.UrbanKm},
+				{"ExtraUrbanKm", got.ExtraUrbanKm, tt.want.ExtraUrbanKm},
+				{"HighwayKm", got.HighwayKm, tt.want.HighwayKm},
+				{"Ascent", got.Ascent, tt.want.Ascent},
+				{"Descent", got.Descent, tt.want.Descent},
+			} {
+				if math.Abs(field.got-field.want) > 1e-9 {
+					t.Errorf("%s = %v, want %v", field.name, field.got, field.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultVehicleProfiles(t *testing.T) {
+	flat := RoadUsage{UrbanKm: 50, ExtraUrbanKm: 30, HighwayKm: 20}
+	hilly := flat
+	hilly.Ascent, hilly.Descent = 500, 200
+
+	tests := []struct {
+		name     VehicleProfileName
+		electric bool
+		flat     float64 // liters or kWh
+		flatCO2  float64
+		hilly    float64
+		hillyCO2 float64
+	}{
+		{VehicleProfileCombustionCar, false, 6.55, 15.1305, 7.2063, 16.6465},
+		{VehicleProfileElectricCar, true, 14.7, 0.7644, 16.771, 0.8721},
+		{VehicleProfileTruck, false, 35.6, 95.408, 41.9899, 112.533},
+		{VehicleProfileScooter, false, 3.04, 7.0224, 3.1978, 7.3868},
+	}
+
+	profiles := DefaultVehicleProfiles()
+	if len(profiles) != len(tests) {
+		t.Errorf("%d default profiles, want %d", len(profiles), len(tests))
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.name), func(t *testing.T) {
+			profile, ok := profiles[tt.name]
+			if !ok {
+				t.Fatal("profile missing")
+			}
+
+			for _, c := range []struct {
+				usage      RoadUsage
+				units, co2 float64
+			}{
+				{flat, tt.flat, tt.flatCO2},
+				{hilly, tt.hilly, tt.hillyCO2},
+			} {
+				got := profile.Estimate(c.usage)
+				if got.VehicleProfile != tt.name {
+					t.Errorf("VehicleProfile = %q, want %q", got.VehicleProfile, tt.name)
+				}
+				units, other := got.FuelLiters, got.EnergyKWh
+				if tt.electric {
+					units, other = got.EnergyKWh, got.FuelLiters
+				}
+				if math.Abs(units-c.units) > 1e-3 || other != 0 {
+					t.Errorf("Estimate(%+v) = %+v, want %v liters or kWh", c.usage, got, c.units)
+				}
+				if math.Abs(got.CO2Kg-c.co2) > 1e-3 {
+					t.Errorf("Estimate(%+v) CO2 = %v kg, want %v kg", c.usage, got.CO2Kg, c.co2)
+				}
+			}
+		})
+	}
+}
+
+func TestEstimateDownhill(t *testing.T) {
+	model := DefaultVehicleProfiles()[VehicleProfileElectricCar]
+
+	// Going down 1000 m over 1 km recovers more energy than the km consumes, but the
+	// consumption can't be negative.
+	got := model.Estimate(RoadUsage{UrbanKm: 1, Descent: 1000})
+	if got.EnergyKWh != 0 || got.CO2Kg != 0 {
+		t.Errorf("Estimate() = %+v, want no consumption", got)
+	}
+}
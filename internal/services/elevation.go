@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"supmap-gis/internal/geo"
+	"supmap-gis/internal/providers/srtm"
+	"supmap-gis/internal/providers/valhalla"
+)
+
+// ElevationClient returns the elevation in meters of each point.
+type ElevationClient interface {
+	Elevations(ctx context.Context, points []Point) ([]float64, error)
+}
+
+// SRTMElevationClient adapts a [srtm.Client] to the [ElevationClient] interface.
+type SRTMElevationClient struct {
+	client *srtm.Client
+}
+
+func NewSRTMElevationClient(client *srtm.Client) *SRTMElevationClient {
+	return &SRTMElevationClient{client: client}
+}
+
+func (c *SRTMElevationClient) Elevations(ctx context.Context, points []Point) ([]float64, error) {
+	coordinates := make([]srtm.Coordinate, len(points))
+	for i, p := range points {
+		coordinates[i] = srtm.Coordinate{Lat: p.Lat, Lon: p.Lon}
+	}
+	return c.client.Elevations(ctx, coordinates)
+}
+
+// ValhallaElevationClient adapts the Valhalla /height API to the [ElevationClient] interface.
+type ValhallaElevationClient struct {
+	client *valhalla.Client
+}
+
+func NewValhallaElevationClient(client *valhalla.Client) *ValhallaElevationClient {
+	return &ValhallaElevationClient{client: client}
+}
+
+func (c *ValhallaElevationClient) Elevations(ctx context.Context, points []Point) ([]float64, error) {
+	shape := make([]valhalla.Coordinate, len(points))
+	for i, p := range points {
+		shape[i] = valhalla.Coordinate{Lat: p.Lat, Lon: p.Lon}
+	}
+
+	resp, err := c.client.Height(ctx, valhalla.HeightRequest{Shape: shape})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Height) != len(points) {
+		return nil, fmt.Errorf("expected %d heights, got %d", len(points), len(resp.Height))
+	}
+
+	heights := make([]float64, len(resp.Height))
+	for i, h := range resp.Height {
+		if h != nil {
+			heights[i] = *h
+		}
+	}
+	return heights, nil
+}
+
+type ElevationService struct {
+	client   ElevationClient
+	interval float64
+}
+
+// maxElevationSamples bounds the number of heights of a profile. The sampling interval
+// is widened for long shapes so that the profile doesn't exceed it.
+const maxElevationSamples = 1000
+
+// NewElevationService creates a service sampling shapes every interval meters.
+func NewElevationService(client ElevationClient, interval float64) *ElevationService {
+	return &ElevationService{client: client, interval: interval}
+}
+
+// ElevationProfile describes the elevation along a shape.
+type ElevationProfile struct {
+	// Ascent and Descent are the cumulated elevation gain and loss, in meters.
+	Ascent  float64 `json:"ascent"`
+	Descent float64 `json:"descent"`
+	// MaxGrade is the steepest grade between two samples, in percent. It is negative when downhill.
+	MaxGrade float64 `json:"max_grade"`
+	// SampleInterval is the distance in meters between two consecutive heights.
+	SampleInterval float64   `json:"sample_interval"`
+	Heights        []float64 `json:"heights"`
+}
+
+// Profile computes the elevation profile of a shape, sampled at regular intervals.
+func (s *ElevationService) Profile(ctx context.Context, shape []Point) (*ElevationProfile, error) {
+	if len(shape) == 0 {
+		return nil, errors.New("shape is empty")
+	}
+
+	samples, interval := resampleShape(shape, s.interval, maxElevationSamples)
+
+	heights, err := s.client.Elevations(ctx, samples)
+	if err != nil {
+		return nil, fmt.Errorf("getting elevations: %w", err)
+	}
+
+	profile := &ElevationProfile{
+		SampleInterval: interval,
+		Heights:        heights,
+	}
+	for i := 1; i < len(heights); i++ {
+		diff := heights[i] - heights[i-1]
+		if diff > 0 {
+			profile.Ascent += diff
+		} else {
+			profile.Descent -= diff
+		}
+
+		if interval > 0 {
+			grade := diff / interval * 100
+			if math.Abs(grade) > math.Abs(profile.MaxGrade) {
+				profile.MaxGrade = grade
+			}
+		}
+	}
+
+	return profile, nil
+}
+
+// AddLegsElevation computes the elevation profile of each leg of the trips.
+func (s *ElevationService) AddLegsElevation(ctx context.Context, trips []Trip) error {
+	for i := range trips {
+		for j := range trips[i].Legs {
+			profile, err := s.Profile(ctx, trips[i].Legs[j].Shape)
+			if err != nil {
+				return fmt.Errorf("trips[%d].legs[%d]: %w", i, j, err)
+			}
+			trips[i].Legs[j].Elevation = profile
+		}
+	}
+	return nil
+}
+
+// resampleShape returns points located every interval meters along the shape, including both ends.
+// The interval is widened if necessary so that no more than maxSamples points are returned.
+func resampleShape(shape []Point, interval float64, maxSamples int) ([]Point, float64) {
+	var length float64
+	for i := 1; i < len(shape); i++ {
+		length += geo.Haversine(shape[i-1].Lat, shape[i-1].Lon, shape[i].Lat, shape[i].Lon)
+	}
+	if length == 0 {
+		return shape[:1], 0
+	}
+
+	count := int(math.Ceil(length/interval)) + 1
+	if count > maxSamples {
+		count = maxSamples
+	}
+	interval = length / float64(count-1)
+
+	samples := make([]Point, 0, count)
+	samples = append(samples, shape[0])
+
+	next := interval
+	var travelled float64
+	for i := 1; i < len(shape) && len(samples) < count-1; i++ {
+		a, b := shape[i-1], shape[i]
+		segment := geo.Haversine(a.Lat, a.Lon, b.Lat, b.Lon)
+		for segment > 0 && next <= travelled+segment && len(samples) < count-1 {
+			t := (next - travelled) / segment
+			samples = append(samples, Point{
+				Lat: a.Lat + (b.Lat-a.Lat)*t,
+				Lon: a.Lon + (b.Lon-a.Lon)*t,
+			})
+			next += interval
+		}
+		travelled += segment
+	}
+	samples = append(samples, shape[len(shape)-1])
+
+	return samples, interval
+}
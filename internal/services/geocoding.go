@@ -4,12 +4,16 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
 	"supmap-gis/internal/providers/nominatim"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type GeocodingClient interface {
-	Search(ctx context.Context, address string) ([]nominatim.GeocodeResult, error)
-	Reverse(ctx context.Context, lat, lon float64) (*nominatim.ReverseResult, error)
+	Search(ctx context.Context, params nominatim.SearchParams) ([]nominatim.GeocodeResult, error)
+	Reverse(ctx context.Context, params nominatim.ReverseParams) (*nominatim.ReverseResult, error)
 }
 
 type GeocodingService struct {
@@ -25,12 +29,21 @@ type Place struct {
 	Lon         float64 `json:"lon"`
 	Name        string  `json:"name"`
 	DisplayName string  `json:"display_name"`
+	// OSMType and OSMID identify the place, to fetch its details later.
+	OSMType string `json:"osm_type,omitempty"`
+	OSMID   int64  `json:"osm_id,omitempty"`
 }
 
-func (s *GeocodingService) Search(ctx context.Context, address string) ([]Place, error) {
-	resp, err := s.client.Search(ctx, address)
+func (s *GeocodingService) Search(ctx context.Context, params nominatim.SearchParams) (_ []Place, err error) {
+	ctx, span := tracer.Start(ctx, "GeocodingService.Search", trace.WithAttributes(
+		attribute.Bool("geocoding.structured", params.IsStructured()),
+		attribute.Int("geocoding.limit", params.Limit),
+	))
+	defer func() { endSpan(span, err) }()
+
+	resp, err := s.client.Search(ctx, params)
 	if err != nil {
-		return nil, fmt.Errorf("searching address %q: %w", address, err)
+		return nil, fmt.Errorf("searching address %q: %w", describeSearch(params), err)
 	}
 
 	if len(resp) == 0 {
@@ -53,12 +66,88 @@ func (s *GeocodingService) Search(ctx context.Context, address string) ([]Place,
 			Lon:         lon,
 			Name:        geocodeResult.Name,
 			DisplayName: geocodeResult.DisplayName,
+			OSMType:     geocodeResult.OSMType,
+			OSMID:       geocodeResult.OSMID,
 		}
 	}
 
 	return places, nil
 }
 
-func (s *GeocodingService) Reverse(ctx context.Context, lat, lon float64) (*nominatim.ReverseResult, error) {
-	return s.client.Reverse(ctx, lat, lon)
+// Address is the result of a reverse geocoding.
+type Address struct {
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
+	DisplayName string  `json:"display_name,omitempty"`
+	HouseNumber string  `json:"house_number,omitempty"`
+	Road        string  `json:"road,omitempty"`
+	Postcode    string  `json:"postcode,omitempty"`
+	City        string  `json:"city,omitempty"`
+	County      string  `json:"county,omitempty"`
+	CountryCode string  `json:"country_code,omitempty"`
+	OSMType     string  `json:"osm_type,omitempty"`
+	OSMID       int64   `json:"osm_id,omitempty"`
+	Category    string  `json:"category,omitempty"`
+	Type        string  `json:"type,omitempty"`
+	// BoundingBox is [minLon, minLat, maxLon, maxLat], as in GeoJSON.
+	BoundingBox []float64 `json:"bbox,omitempty"`
+}
+
+// Reverse returns the address at the given coordinates, or nil if there is none.
+func (s *GeocodingService) Reverse(ctx context.Context, params nominatim.ReverseParams) (*Address, error) {
+	resp, err := s.client.Reverse(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("reverse geocoding (%v, %v): %w", params.Lat, params.Lon, err)
+	}
+
+	if resp == nil || len(resp.Features) == 0 || resp.Features[0].Properties.DisplayName == "" {
+		return nil, nil
+	}
+
+	feature := resp.Features[0]
+	props := feature.Properties
+	address := &Address{
+		Lat:         params.Lat,
+		Lon:         params.Lon,
+		DisplayName: props.DisplayName,
+		HouseNumber: props.Address.HouseNumber,
+		Road:        props.Address.Road,
+		Postcode:    props.Address.Postcode,
+		City:        firstNonEmpty(props.Address.City, props.Address.Town, props.Address.Village, props.Address.Municipality),
+		County:      props.Address.County,
+		CountryCode: props.Address.CountryCode,
+		OSMType:     props.OSMType,
+		OSMID:       props.OSMID,
+		Category:    props.Category,
+		Type:        props.Type,
+		BoundingBox: feature.BoundingBox,
+	}
+	if len(feature.Geometry.Coordinates) == 2 {
+		address.Lon, address.Lat = feature.Geometry.Coordinates[0], feature.Geometry.Coordinates[1]
+	}
+
+	return address, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// describeSearch returns a human-readable representation of the searched address, for error messages.
+func describeSearch(params nominatim.SearchParams) string {
+	if !params.IsStructured() {
+		return params.Query
+	}
+	var parts []string
+	for _, part := range []string{params.Amenity, params.Street, params.PostalCode, params.City, params.County, params.State, params.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
 }
@@ -0,0 +1,431 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"supmap-gis/internal/providers/gazetteer"
+	"supmap-gis/internal/providers/nominatim"
+	"supmap-gis/internal/providers/pelias"
+	"supmap-gis/internal/providers/photon"
+)
+
+// The geocoding backends other than Nominatim are adapted to the [GeocodingClient] interface,
+// so their results are converted to Nominatim results. Search parameters they don't support,
+// such as layers or deduplication, are ignored.
+
+// backendPlace is a geocoding result of a backend, before its conversion to a Nominatim result.
+type backendPlace struct {
+	lat, lon    float64
+	name        string
+	displayName string
+	category    string
+	placeType   string
+	osmType     string
+	osmID       int64
+	importance  float64
+	address     nominatim.Address
+	// bbox is [minLon, minLat, maxLon, maxLat], as in GeoJSON.
+	bbox []float64
+}
+
+func (p backendPlace) geocodeResult() nominatim.GeocodeResult {
+	displayName := p.displayName
+	if displayName == "" {
+		displayName = describeAddress(p.name, p.address)
+	}
+	address := p.address
+
+	result := nominatim.GeocodeResult{
+		OSMType:     p.osmType,
+		OSMID:       p.osmID,
+		Lat:         strconv.FormatFloat(p.lat, 'f', -1, 64),
+		Lon:         strconv.FormatFloat(p.lon, 'f', -1, 64),
+		Category:    p.category,
+		Type:        p.placeType,
+		Importance:  p.importance,
+		Name:        p.name,
+		DisplayName: displayName,
+		Address:     &address,
+	}
+	if len(p.bbox) == 4 {
+		// Nominatim's bounding boxes are [minLat, maxLat, minLon, maxLon].
+		for _, i := range []int{1, 3, 0, 2} {
+			result.BoundingBox = append(result.BoundingBox, strconv.FormatFloat(p.bbox[i], 'f', -1, 64))
+		}
+	}
+	return result
+}
+
+func (p backendPlace) reverseResult() *nominatim.ReverseResult {
+	result := p.geocodeResult()
+
+	feature := nominatim.ReverseFeature{
+		Properties: nominatim.ReverseProperties{
+			OSMType:     result.OSMType,
+			OSMID:       result.OSMID,
+			Category:    result.Category,
+			Type:        result.Type,
+			Importance:  result.Importance,
+			Name:        result.Name,
+			DisplayName: result.DisplayName,
+			Address:     p.address,
+		},
+		BoundingBox: p.bbox,
+	}
+	feature.Geometry.Type = "Point"
+	feature.Geometry.Coordinates = []float64{p.lon, p.lat}
+
+	return &nominatim.ReverseResult{Features: []nominatim.ReverseFeature{feature}}
+}
+
+// describeAddress builds a display name from the name and the address of a place.
+func describeAddress(name string, address nominatim.Address) string {
+	street := strings.TrimSpace(address.HouseNumber + " " + address.Road)
+	city := strings.TrimSpace(address.Postcode + " " + firstNonEmpty(address.City, address.Town, address.Village))
+
+	var parts []string
+	for _, part := range []string{name, street, city, address.County, address.State, address.Country} {
+		if part != "" && !slices.Contains(parts, part) {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// boundedViewBox returns the view box of params as [minLon, minLat, maxLon, maxLat] if results
+// must be restricted to it.
+func boundedViewBox(params nominatim.SearchParams) []float64 {
+	if params.ViewBox == nil || !params.Bounded {
+		return nil
+	}
+	return []float64{params.ViewBox.MinLon, params.ViewBox.MinLat, params.ViewBox.MaxLon, params.ViewBox.MaxLat}
+}
+
+// focusPoint returns the center of the view box of params as [lon, lat] if results are only
+// preferred within it, since the other backends can't use a view box as a preference.
+func focusPoint(params nominatim.SearchParams) []float64 {
+	if params.ViewBox == nil || params.Bounded {
+		return nil
+	}
+	return []float64{(params.ViewBox.MinLon + params.ViewBox.MaxLon) / 2, (params.ViewBox.MinLat + params.ViewBox.MaxLat) / 2}
+}
+
+// searchLimit returns the number of results requested by params, with Nominatim's default.
+func searchLimit(params nominatim.SearchParams) int {
+	if params.Limit > 0 {
+		return params.Limit
+	}
+	return nominatimDefaultLimit
+}
+
+// inCountries reports whether countryCode is one of countryCodes, or if countryCodes is empty.
+func inCountries(countryCode string, countryCodes []string) bool {
+	if len(countryCodes) == 0 {
+		return true
+	}
+	for _, code := range countryCodes {
+		if strings.EqualFold(code, countryCode) {
+			return true
+		}
+	}
+	return false
+}
+
+// positionImportance gives an importance to the i-th of n results of a backend that doesn't
+// provide one, so that results keep their order when ranked by importance.
+func positionImportance(i, n int) float64 {
+	return 1 - float64(i)/float64(n)
+}
+
+// osmTypeNames maps OSM type letters to the names used by Nominatim.
+var osmTypeNames = map[string]string{
+	"N": "node",
+	"W": "way",
+	"R": "relation",
+}
+
+// PhotonGeocodingClient adapts a [photon.Client] to the [GeocodingClient] interface.
+// Photon has no structured search, so structured searches are sent as free text.
+type PhotonGeocodingClient struct {
+	client *photon.Client
+}
+
+func NewPhotonGeocodingClient(client *photon.Client) *PhotonGeocodingClient {
+	return &PhotonGeocodingClient{client: client}
+}
+
+const (
+	// photonCountryOverfetch is how many times more results are requested from Photon when
+	// searching in some countries only, since Photon can't filter them by country itself.
+	photonCountryOverfetch = 5
+	// photonMaxLimit is the maximum number of results Photon returns.
+	photonMaxLimit = 50
+)
+
+func (c *PhotonGeocodingClient) Search(ctx context.Context, params nominatim.SearchParams) ([]nominatim.GeocodeResult, error) {
+	limit := searchLimit(params)
+	photonLimit := limit
+	if len(params.CountryCodes) > 0 {
+		photonLimit = max(limit, min(limit*photonCountryOverfetch, photonMaxLimit))
+	}
+
+	resp, err := c.client.Search(ctx, photon.SearchParams{
+		Query:       describeSearch(params),
+		Limit:       photonLimit,
+		BoundingBox: boundedViewBox(params),
+		Focus:       focusPoint(params),
+		Lang:        primaryLanguage(params.Language),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]nominatim.GeocodeResult, 0, min(limit, len(resp.Features)))
+	for i, feature := range resp.Features {
+		if len(results) == limit {
+			break
+		}
+		if !inCountries(feature.Properties.CountryCode, params.CountryCodes) {
+			continue
+		}
+		place, ok := photonPlace(feature)
+		if !ok {
+			continue
+		}
+		place.importance = positionImportance(i, len(resp.Features))
+		results = append(results, place.geocodeResult())
+	}
+	return results, nil
+}
+
+func (c *PhotonGeocodingClient) Reverse(ctx context.Context, params nominatim.ReverseParams) (*nominatim.ReverseResult, error) {
+	resp, err := c.client.Reverse(ctx, photon.ReverseParams{
+		Lat:  params.Lat,
+		Lon:  params.Lon,
+		Lang: primaryLanguage(params.Language),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Features) == 0 {
+		return &nominatim.ReverseResult{}, nil
+	}
+	place, ok := photonPlace(resp.Features[0])
+	if !ok {
+		return &nominatim.ReverseResult{}, nil
+	}
+	return place.reverseResult(), nil
+}
+
+func photonPlace(feature photon.Feature) (backendPlace, bool) {
+	if len(feature.Geometry.Coordinates) < 2 {
+		return backendPlace{}, false
+	}
+	props := feature.Properties
+
+	place := backendPlace{
+		lon:       feature.Geometry.Coordinates[0],
+		lat:       feature.Geometry.Coordinates[1],
+		name:      props.Name,
+		category:  props.OSMKey,
+		placeType: props.OSMValue,
+		osmType:   osmTypeNames[props.OSMType],
+		osmID:     props.OSMID,
+		address: nominatim.Address{
+			HouseNumber: props.HouseNumber,
+			Road:        props.Street,
+			Suburb:      props.District,
+			City:        props.City,
+			County:      props.County,
+			State:       props.State,
+			Postcode:    props.Postcode,
+			Country:     props.Country,
+			CountryCode: strings.ToLower(props.CountryCode),
+		},
+	}
+	if len(props.Extent) == 4 {
+		// Photon's extents are [minLon, maxLat, maxLon, minLat].
+		place.bbox = []float64{props.Extent[0], props.Extent[3], props.Extent[2], props.Extent[1]}
+	}
+	return place, true
+}
+
+// primaryLanguage returns the primary subtag of a language tag, e.g. "fr" for "fr-FR".
+func primaryLanguage(language string) string {
+	primary, _, _ := strings.Cut(language, "-")
+	return strings.ToLower(primary)
+}
+
+// PeliasGeocodingClient adapts a [pelias.Client] to the [GeocodingClient] interface.
+type PeliasGeocodingClient struct {
+	client *pelias.Client
+}
+
+func NewPeliasGeocodingClient(client *pelias.Client) *PeliasGeocodingClient {
+	return &PeliasGeocodingClient{client: client}
+}
+
+func (c *PeliasGeocodingClient) Search(ctx context.Context, params nominatim.SearchParams) ([]nominatim.GeocodeResult, error) {
+	searchParams := pelias.SearchParams{
+		Size:        searchLimit(params),
+		Countries:   params.CountryCodes,
+		BoundingBox: boundedViewBox(params),
+		Focus:       focusPoint(params),
+		Lang:        params.Language,
+	}
+	// Pelias' structured search has no field for amenities.
+	if params.IsStructured() && params.Amenity == "" {
+		searchParams.Address = params.Street
+		searchParams.Locality = params.City
+		searchParams.County = params.County
+		searchParams.Region = params.State
+		searchParams.PostalCode = params.PostalCode
+		searchParams.Country = params.Country
+	} else {
+		searchParams.Text = describeSearch(params)
+	}
+
+	resp, err := c.client.Search(ctx, searchParams)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]nominatim.GeocodeResult, 0, len(resp.Features))
+	for _, feature := range resp.Features {
+		place, ok := peliasPlace(feature)
+		if !ok {
+			continue
+		}
+		results = append(results, place.geocodeResult())
+	}
+	return results, nil
+}
+
+func (c *PeliasGeocodingClient) Reverse(ctx context.Context, params nominatim.ReverseParams) (*nominatim.ReverseResult, error) {
+	resp, err := c.client.Reverse(ctx, pelias.ReverseParams{
+		Lat:  params.Lat,
+		Lon:  params.Lon,
+		Lang: params.Language,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Features) == 0 {
+		return &nominatim.ReverseResult{}, nil
+	}
+	place, ok := peliasPlace(resp.Features[0])
+	if !ok {
+		return &nominatim.ReverseResult{}, nil
+	}
+	return place.reverseResult(), nil
+}
+
+func peliasPlace(feature pelias.Feature) (backendPlace, bool) {
+	if len(feature.Geometry.Coordinates) < 2 {
+		return backendPlace{}, false
+	}
+	props := feature.Properties
+
+	place := backendPlace{
+		lon:         feature.Geometry.Coordinates[0],
+		lat:         feature.Geometry.Coordinates[1],
+		name:        props.Name,
+		displayName: props.Label,
+		category:    "place",
+		placeType:   props.Layer,
+		importance:  props.Confidence,
+		address: nominatim.Address{
+			HouseNumber: props.HouseNumber,
+			Road:        props.Street,
+			City:        props.Locality,
+			County:      props.County,
+			State:       props.Region,
+			Postcode:    props.PostalCode,
+			Country:     props.Country,
+			CountryCode: strings.ToLower(props.CountryCode),
+		},
+		bbox: feature.BoundingBox,
+	}
+	// OpenStreetMap source IDs look like "node/123".
+	if props.Source == "openstreetmap" {
+		if osmType, id, ok := strings.Cut(props.SourceID, "/"); ok {
+			if osmID, err := strconv.ParseInt(id, 10, 64); err == nil {
+				place.osmType, place.osmID = osmType, osmID
+			}
+		}
+	}
+	return place, true
+}
+
+// GazetteerGeocodingClient adapts a [gazetteer.Gazetteer] to the [GeocodingClient] interface.
+// It works offline, with the places of a local file only.
+type GazetteerGeocodingClient struct {
+	gazetteer *gazetteer.Gazetteer
+	// maxReverseDistance is the maximum distance in meters between a position and the place returned
+	// by a reverse geocoding.
+	maxReverseDistance float64
+}
+
+func NewGazetteerGeocodingClient(gazetteer *gazetteer.Gazetteer, maxReverseDistance float64) *GazetteerGeocodingClient {
+	return &GazetteerGeocodingClient{
+		gazetteer:          gazetteer,
+		maxReverseDistance: maxReverseDistance,
+	}
+}
+
+func (c *GazetteerGeocodingClient) Search(_ context.Context, params nominatim.SearchParams) ([]nominatim.GeocodeResult, error) {
+	bbox := boundedViewBox(params)
+
+	// Filters are applied after the search, so more entries than needed are searched.
+	entries := c.gazetteer.Search(describeSearch(params), 0)
+	limit := searchLimit(params)
+
+	results := make([]nominatim.GeocodeResult, 0, min(len(entries), limit))
+	for i, entry := range entries {
+		if len(results) == limit {
+			break
+		}
+		if !inCountries(entry.CountryCode, params.CountryCodes) {
+			continue
+		}
+		if bbox != nil && (entry.Lon < bbox[0] || entry.Lat < bbox[1] || entry.Lon > bbox[2] || entry.Lat > bbox[3]) {
+			continue
+		}
+		place := gazetteerPlace(entry)
+		place.importance = positionImportance(i, len(entries))
+		results = append(results, place.geocodeResult())
+	}
+	return results, nil
+}
+
+func (c *GazetteerGeocodingClient) Reverse(_ context.Context, params nominatim.ReverseParams) (*nominatim.ReverseResult, error) {
+	entry := c.gazetteer.Nearest(params.Lat, params.Lon, c.maxReverseDistance)
+	if entry == nil {
+		return &nominatim.ReverseResult{}, nil
+	}
+	return gazetteerPlace(*entry).reverseResult(), nil
+}
+
+func gazetteerPlace(entry gazetteer.Entry) backendPlace {
+	return backendPlace{
+		lat:       entry.Lat,
+		lon:       entry.Lon,
+		name:      entry.Name,
+		category:  "place",
+		placeType: entry.Type,
+		address: nominatim.Address{
+			HouseNumber: entry.HouseNumber,
+			Road:        entry.Street,
+			City:        entry.City,
+			County:      entry.County,
+			State:       entry.State,
+			Postcode:    entry.Postcode,
+			Country:     entry.Country,
+			CountryCode: strings.ToLower(entry.CountryCode),
+		},
+	}
+}
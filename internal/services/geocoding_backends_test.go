@@ -0,0 +1,64 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"supmap-gis/internal/providers/nominatim"
+	"supmap-gis/internal/providers/photon"
+	"testing"
+)
+
+func TestPhotonSearchCountryCodes(t *testing.T) {
+	var limits []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limits = append(limits, r.URL.Query().Get("limit"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+		// The best results are in Belgium, the following ones in France.
+		var collection photon.FeatureCollection
+		for i := range limit {
+			feature := photon.Feature{Properties: photon.Properties{OSMID: int64(i + 1), OSMType: "N", Name: "Place " + strconv.Itoa(i), CountryCode: "FR"}}
+			if i < 4 {
+				feature.Properties.CountryCode = "BE"
+			}
+			feature.Geometry.Coordinates = []float64{2.35, 48.86}
+			collection.Features = append(collection.Features, feature)
+		}
+		json.NewEncoder(w).Encode(collection)
+	}))
+	defer server.Close()
+	client := NewPhotonGeocodingClient(photon.NewClient(server.URL))
+
+	tests := []struct {
+		name        string
+		params      nominatim.SearchParams
+		wantLimit   string
+		wantResults int
+	}{
+		{"no country", nominatim.SearchParams{Query: "place", Limit: 3}, "3", 3},
+		{"over-fetched", nominatim.SearchParams{Query: "place", Limit: 3, CountryCodes: []string{"fr"}}, "15", 3},
+		{"default limit", nominatim.SearchParams{Query: "place", CountryCodes: []string{"fr"}}, "50", 10},
+		{"limit above the maximum of Photon", nominatim.SearchParams{Query: "place", Limit: 40, CountryCodes: []string{"fr"}}, "50", 40},
+		{"several countries", nominatim.SearchParams{Query: "place", Limit: 5, CountryCodes: []string{"be", "fr"}}, "25", 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits = nil
+			results, err := client.Search(t.Context(), tt.params)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if len(limits) != 1 || limits[0] != tt.wantLimit {
+				t.Errorf("limits sent to Photon = %v, want %s", limits, tt.wantLimit)
+			}
+			if len(results) != tt.wantResults {
+				t.Errorf("%d results, want %d", len(results), tt.wantResults)
+			}
+			if len(tt.params.CountryCodes) == 1 && len(results) > 0 && results[0].OSMID != 5 {
+				t.Errorf("first result is %d, want the first one in France", results[0].OSMID)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"supmap-gis/internal/lru"
+	"supmap-gis/internal/providers/nominatim"
+	"time"
+)
+
+// CachedGeocodingClient is a [GeocodingClient] caching the results of another one in a size-bounded
+// LRU cache. Searches are keyed by their normalized parameters, and reverse geocodings by their
+// rounded coordinates. Empty results are cached too, with a shorter TTL. Errors aren't cached.
+//
+// Cached results are shared between callers, which must not modify them.
+type CachedGeocodingClient struct {
+	client  GeocodingClient
+	options GeocodingCacheOptions
+	cache   *lru.Cache
+}
+
+type GeocodingCacheOptions struct {
+	Size int
+	TTL  time.Duration
+	// NegativeTTL is how long empty results are cached.
+	NegativeTTL time.Duration
+	// ReversePrecision is the number of decimals to which coordinates are rounded for reverse
+	// geocoding. 4 decimals are about 11 meters.
+	ReversePrecision int
+}
+
+func DefaultGeocodingCacheOptions() GeocodingCacheOptions {
+	return GeocodingCacheOptions{
+		Size:             10000,
+		TTL:              24 * time.Hour,
+		NegativeTTL:      10 * time.Minute,
+		ReversePrecision: 4,
+	}
+}
+
+func NewCachedGeocodingClient(client GeocodingClient, options ...GeocodingCacheOptions) *CachedGeocodingClient {
+	opts := DefaultGeocodingCacheOptions()
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	return &CachedGeocodingClient{
+		client:  client,
+		options: opts,
+		cache:   lru.New(opts.Size),
+	}
+}
+
+func (c *CachedGeocodingClient) Stats() lru.Stats {
+	return c.cache.Stats()
+}
+
+func (c *CachedGeocodingClient) Search(ctx context.Context, params nominatim.SearchParams) ([]nominatim.GeocodeResult, error) {
+	key := searchCacheKey(params)
+	if value, _, ok := c.cache.Get(key); ok {
+		return value.([]nominatim.GeocodeResult), nil
+	}
+
+	results, err := c.client.Search(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	c.cache.Set(key, results, c.ttl(len(results) == 0))
+	return results, nil
+}
+
+func (c *CachedGeocodingClient) Reverse(ctx context.Context, params nominatim.ReverseParams) (*nominatim.ReverseResult, error) {
+	key := c.reverseCacheKey(params)
+	if value, _, ok := c.cache.Get(key); ok {
+		return value.(*nominatim.ReverseResult), nil
+	}
+
+	result, err := c.client.Reverse(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	c.cache.Set(key, result, c.ttl(result == nil || len(result.Features) == 0))
+	return result, nil
+}
+
+// ttl returns how long a result is cached, according to whether it's empty.
+func (c *CachedGeocodingClient) ttl(empty bool) time.Duration {
+	if empty {
+		return c.options.NegativeTTL
+	}
+	return c.options.TTL
+}
+
+// searchCacheKey returns a key identifying the results of a search. Texts are normalized,
+// so that "12 rue de la Paix, Paris" and "12 Rue de la Paix Paris" share the same results.
+func searchCacheKey(params nominatim.SearchParams) string {
+	var b strings.Builder
+	b.WriteString("search")
+	for _, text := range []string{
+		params.Query, params.Amenity, params.Street, params.City, params.County, params.State,
+		params.PostalCode, params.Country,
+	} {
+		b.WriteString("|")
+		b.WriteString(normalizeQuery(text))
+	}
+
+	fmt.Fprintf(&b, "|%s|%v|%d|%s|%s|%s",
+		strings.ToLower(strings.Join(params.CountryCodes, ",")),
+		params.Bounded,
+		params.Limit,
+		strings.Join(params.Layers, ","),
+		params.FeatureType,
+		params.Language,
+	)
+	if params.ViewBox != nil {
+		fmt.Fprintf(&b, "|%v,%v,%v,%v", params.ViewBox.MinLon, params.ViewBox.MinLat, params.ViewBox.MaxLon, params.ViewBox.MaxLat)
+	} else {
+		b.WriteString("|")
+	}
+	if params.Dedupe != nil {
+		fmt.Fprintf(&b, "|%v", *params.Dedupe)
+	} else {
+		b.WriteString("|")
+	}
+	return b.String()
+}
+
+// reverseCacheKey returns a key identifying the result of a reverse geocoding,
+// with the coordinates rounded to the configured precision.
+func (c *CachedGeocodingClient) reverseCacheKey(params nominatim.ReverseParams) string {
+	factor := math.Pow10(c.options.ReversePrecision)
+	lat := math.Round(params.Lat*factor) / factor
+	lon := math.Round(params.Lon*factor) / factor
+	return fmt.Sprintf("reverse|%.*f|%.*f|%d|%s",
+		c.options.ReversePrecision, lat, c.options.ReversePrecision, lon, params.Zoom, params.Language)
+}
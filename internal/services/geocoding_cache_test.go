@@ -0,0 +1,183 @@
+package services
+
+import (
+	"errors"
+	"supmap-gis/internal/providers/nominatim"
+	"testing"
+	"time"
+)
+
+func TestSearchCacheKey(t *testing.T) {
+	base := nominatim.SearchParams{Query: "12 rue de la Paix, Paris", Limit: 5, Language: "fr"}
+	key := searchCacheKey(base)
+
+	same := []struct {
+		name   string
+		params nominatim.SearchParams
+	}{
+		{"same parameters", base},
+		{"query written differently", nominatim.SearchParams{Query: "12 Rue de la Paix  Paris", Limit: 5, Language: "fr"}},
+	}
+	for _, tt := range same {
+		if got := searchCacheKey(tt.params); got != key {
+			t.Errorf("%s: key = %q, want %q", tt.name, got, key)
+		}
+	}
+
+	with := func(change func(*nominatim.SearchParams)) nominatim.SearchParams {
+		params := base
+		change(&params)
+		return params
+	}
+	viewBox := &nominatim.ViewBox{MinLon: 2.2, MinLat: 48.8, MaxLon: 2.5, MaxLat: 48.9}
+	distinct := []struct {
+		name   string
+		params nominatim.SearchParams
+	}{
+		{"other query", with(func(p *nominatim.SearchParams) { p.Query = "12 rue de la Paix, Lyon" })},
+		{"other language", with(func(p *nominatim.SearchParams) { p.Language = "en" })},
+		{"regional language", with(func(p *nominatim.SearchParams) { p.Language = "fr-CA" })},
+		{"no language", with(func(p *nominatim.SearchParams) { p.Language = "" })},
+		{"country codes", with(func(p *nominatim.SearchParams) { p.CountryCodes = []string{"fr"} })},
+		{"other country codes", with(func(p *nominatim.SearchParams) { p.CountryCodes = []string{"fr", "be"} })},
+		{"viewbox", with(func(p *nominatim.SearchParams) { p.ViewBox = viewBox })},
+		{"other viewbox", with(func(p *nominatim.SearchParams) {
+			p.ViewBox = &nominatim.ViewBox{MinLon: 2.2, MinLat: 48.8, MaxLon: 2.5, MaxLat: 48.95}
+		})},
+		{"bounded viewbox", with(func(p *nominatim.SearchParams) { p.ViewBox, p.Bounded = viewBox, true })},
+		{"other limit", with(func(p *nominatim.SearchParams) { p.Limit = 10 })},
+		{"default limit", with(func(p *nominatim.SearchParams) { p.Limit = 0 })},
+		// The same text in another field is another search.
+		{"structured search", nominatim.SearchParams{Street: "12 rue de la Paix", City: "Paris", Limit: 5, Language: "fr"}},
+	}
+	seen := map[string]string{key: "base"}
+	for _, tt := range distinct {
+		got := searchCacheKey(tt.params)
+		if other, ok := seen[got]; ok {
+			t.Errorf("%s: same key as %s: %q", tt.name, other, got)
+		}
+		seen[got] = tt.name
+	}
+
+	// Country codes are case insensitive.
+	upper := with(func(p *nominatim.SearchParams) { p.CountryCodes = []string{"FR"} })
+	if searchCacheKey(upper) != searchCacheKey(with(func(p *nominatim.SearchParams) { p.CountryCodes = []string{"fr"} })) {
+		t.Error("country codes in upper case have another key")
+	}
+}
+
+func TestReverseCacheKey(t *testing.T) {
+	c := NewCachedGeocodingClient(&fakeGeocodingClient{})
+	key := func(lat, lon float64, zoom int, language string) string {
+		return c.reverseCacheKey(nominatim.ReverseParams{Lat: lat, Lon: lon, Zoom: zoom, Language: language})
+	}
+
+	// Coordinates are rounded to 4 decimals, about 11 meters.
+	if a, b := key(49.18291, -0.37072, 18, "fr"), key(49.18289, -0.37068, 18, "fr"); a != b {
+		t.Errorf("keys of positions 3 m apart = %q and %q, want the same", a, b)
+	}
+
+	base := key(49.1829, -0.3707, 18, "fr")
+	distinct := []struct {
+		name string
+		key  string
+	}{
+		{"next latitude", key(49.1830, -0.3707, 18, "fr")},
+		{"next longitude", key(49.1829, -0.3706, 18, "fr")},
+		{"shorter latitude", key(49.1, -0.3707, 18, "fr")},
+		{"swapped coordinates", key(-0.3707, 49.1829, 18, "fr")},
+		{"other hemisphere", key(-49.1829, -0.3707, 18, "fr")},
+		{"other zoom", key(49.1829, -0.3707, 10, "fr")},
+		{"other language", key(49.1829, -0.3707, 18, "en")},
+	}
+	seen := map[string]string{base: "base"}
+	for _, tt := range distinct {
+		if other, ok := seen[tt.key]; ok {
+			t.Errorf("%s: same key as %s: %q", tt.name, other, tt.key)
+		}
+		seen[tt.key] = tt.name
+	}
+
+	precise := NewCachedGeocodingClient(&fakeGeocodingClient{}, GeocodingCacheOptions{ReversePrecision: 5})
+	a := precise.reverseCacheKey(nominatim.ReverseParams{Lat: 49.18291, Lon: -0.37072})
+	b := precise.reverseCacheKey(nominatim.ReverseParams{Lat: 49.18289, Lon: -0.37068})
+	if a == b {
+		t.Errorf("keys with 5 decimals = %q for positions 3 m apart", a)
+	}
+}
+
+func TestCachedGeocodingClient(t *testing.T) {
+	found := &fakeGeocodingClient{
+		results: []nominatim.GeocodeResult{geocodeResult(1, "Caen", "49.18", "-0.37")},
+		reverse: &nominatim.ReverseResult{Features: []nominatim.ReverseFeature{{}}},
+	}
+	c := NewCachedGeocodingClient(found, GeocodingCacheOptions{Size: 10, TTL: time.Hour, NegativeTTL: time.Hour, ReversePrecision: 4})
+	search := nominatim.SearchParams{Query: "caen"}
+	reverse := nominatim.ReverseParams{Lat: 49.18, Lon: -0.37}
+
+	for range 2 {
+		if results, err := c.Search(t.Context(), search); err != nil || len(results) != 1 {
+			t.Fatalf("Search() = %v, %v", results, err)
+		}
+		if result, err := c.Reverse(t.Context(), reverse); err != nil || result != found.reverse {
+			t.Fatalf("Reverse() = %v, %v", result, err)
+		}
+	}
+	if found.calls != 2 {
+		t.Errorf("%d calls to the client, want 2", found.calls)
+	}
+	if stats := c.Stats(); stats.Hits != 2 || stats.Misses != 2 || stats.Entries != 2 {
+		t.Errorf("stats = %+v, want 2 hits, 2 misses and 2 entries", stats)
+	}
+}
+
+func TestCachedGeocodingClientTTL(t *testing.T) {
+	empty := &fakeGeocodingClient{reverse: &nominatim.ReverseResult{}}
+	found := &fakeGeocodingClient{results: []nominatim.GeocodeResult{geocodeResult(1, "Caen", "49.18", "-0.37")}}
+	failing := &fakeGeocodingClient{err: errors.New("unavailable")}
+	options := GeocodingCacheOptions{Size: 10, TTL: time.Hour, NegativeTTL: time.Millisecond, ReversePrecision: 4}
+
+	tests := []struct {
+		name      string
+		client    *fakeGeocodingClient
+		call      func(c *CachedGeocodingClient) error
+		wantCalls int
+	}{
+		{"result", found, func(c *CachedGeocodingClient) error {
+			_, err := c.Search(t.Context(), nominatim.SearchParams{Query: "caen"})
+			return err
+		}, 1},
+		// Empty results expire after the negative TTL.
+		{"empty search", empty, func(c *CachedGeocodingClient) error {
+			_, err := c.Search(t.Context(), nominatim.SearchParams{Query: "caen"})
+			return err
+		}, 2},
+		{"empty reverse", empty, func(c *CachedGeocodingClient) error {
+			_, err := c.Reverse(t.Context(), nominatim.ReverseParams{Lat: 49.18, Lon: -0.37})
+			return err
+		}, 2},
+		// Errors aren't cached.
+		{"error", failing, func(c *CachedGeocodingClient) error {
+			_, err := c.Search(t.Context(), nominatim.SearchParams{Query: "caen"})
+			if err == nil {
+				return errors.New("no error")
+			}
+			return nil
+		}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.client.calls = 0
+			c := NewCachedGeocodingClient(tt.client, options)
+			for range 2 {
+				if err := tt.call(c); err != nil {
+					t.Fatal(err)
+				}
+				time.Sleep(2 * time.Millisecond)
+			}
+			if tt.client.calls != tt.wantCalls {
+				t.Errorf("%d calls to the client, want %d", tt.client.calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"supmap-gis/internal/logging"
+	"supmap-gis/internal/providers/transport"
+	"sync"
+	"time"
+)
+
+// HealthChecker checks that an upstream dependency is available.
+type HealthChecker interface {
+	Status(ctx context.Context) error
+}
+
+// Dependency is an upstream dependency probed by the [HealthService]. The service isn't ready
+// when a critical dependency is down, while a non-critical one only degrades it.
+type Dependency struct {
+	Name     string
+	Checker  HealthChecker
+	Critical bool
+}
+
+type HealthStatus string
+
+const (
+	HealthStatusUp   HealthStatus = "up"
+	HealthStatusDown HealthStatus = "down"
+	// HealthStatusDegraded is reported when only non-critical dependencies are down.
+	HealthStatusDegraded HealthStatus = "degraded"
+)
+
+type DependencyHealth struct {
+	Name     string       `json:"name"`
+	Status   HealthStatus `json:"status"`
+	Critical bool         `json:"critical"`
+	// LatencyMs is the duration of the probe, in milliseconds.
+	LatencyMs int64 `json:"latency_ms"`
+	// Error is the reason the probe failed: "timeout", "unreachable", "status 5xx"... The details
+	// are only logged, since they may reveal internal addresses.
+	Error string `json:"error,omitempty"`
+}
+
+type HealthReport struct {
+	Status       HealthStatus       `json:"status"`
+	Dependencies []DependencyHealth `json:"dependencies"`
+	CheckedAt    time.Time          `json:"checked_at"`
+}
+
+// Ready reports whether all the critical dependencies are up.
+func (r HealthReport) Ready() bool {
+	return r.Status != HealthStatusDown
+}
+
+// HealthService probes the upstream dependencies concurrently, and caches the report so that
+// frequent readiness probes don't load the upstreams.
+type HealthService struct {
+	dependencies []Dependency
+	options      HealthOptions
+
+	// mu is held during the checks, so that concurrent callers wait for the same report.
+	mu     sync.Mutex
+	report *HealthReport
+}
+
+type HealthOptions struct {
+	// Timeout bounds the probe of each dependency.
+	Timeout time.Duration
+	// CacheTTL is how long a report is reused.
+	CacheTTL time.Duration
+}
+
+func DefaultHealthOptions() HealthOptions {
+	return HealthOptions{
+		Timeout:  2 * time.Second,
+		CacheTTL: 5 * time.Second,
+	}
+}
+
+func NewHealthService(dependencies []Dependency, options ...HealthOptions) *HealthService {
+	opts := DefaultHealthOptions()
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	return &HealthService{
+		dependencies: dependencies,
+		options:      opts,
+	}
+}
+
+// Readiness returns the health of the dependencies, probing them if the cached report expired.
+func (s *HealthService) Readiness(ctx context.Context) HealthReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.report != nil && time.Since(s.report.CheckedAt) < s.options.CacheTTL {
+		return *s.report
+	}
+
+	// The report is shared with other callers, so it must not be cut short by this request.
+	ctx = context.WithoutCancel(ctx)
+
+	report := HealthReport{
+		Status:       HealthStatusUp,
+		Dependencies: make([]DependencyHealth, len(s.dependencies)),
+	}
+
+	var wg sync.WaitGroup
+	for i, dependency := range s.dependencies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Dependencies[i] = s.check(ctx, dependency)
+		}()
+	}
+	wg.Wait()
+
+	for _, dependency := range report.Dependencies {
+		if dependency.Status == HealthStatusUp {
+			continue
+		}
+		if dependency.Critical {
+			report.Status = HealthStatusDown
+			break
+		}
+		report.Status = HealthStatusDegraded
+	}
+
+	report.CheckedAt = time.Now()
+	s.report = &report
+	return report
+}
+
+func (s *HealthService) check(ctx context.Context, dependency Dependency) DependencyHealth {
+	ctx, cancel := context.WithTimeout(ctx, s.options.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := dependency.Checker.Status(ctx)
+
+	health := DependencyHealth{
+		Name:      dependency.Name,
+		Status:    HealthStatusUp,
+		Critical:  dependency.Critical,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		health.Status = HealthStatusDown
+		health.Error = probeFailure(err)
+		logging.FromContext(ctx).Warn("Health check failed", "dependency", dependency.Name, "reason", health.Error, "error", err)
+	}
+	return health
+}
+
+// probeFailure returns the reason of the failure of a probe.
+func probeFailure(err error) string {
+	var statusErr *transport.StatusError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &statusErr):
+		return fmt.Sprintf("status %dxx", statusErr.StatusCode/100)
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &netErr):
+		return "unreachable"
+	default:
+		return "unavailable"
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"supmap-gis/internal/logging"
+	"supmap-gis/internal/providers/transport"
+	"supmap-gis/internal/providers/valhalla"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProbeFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"server error", fmt.Errorf("database down: %w", &transport.StatusError{StatusCode: http.StatusServiceUnavailable}), "status 5xx"},
+		{"client error", &transport.StatusError{StatusCode: http.StatusNotFound}, "status 4xx"},
+		{"deadline", fmt.Errorf("failed to send request: %w", context.DeadlineExceeded), "timeout"},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connect: connection refused")}, "unreachable"},
+		{"other", errors.New("failed to decode response"), "unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := probeFailure(tt.err); got != tt.want {
+				t.Errorf("probeFailure() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadinessProbes(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "tile directory /data/valhalla_tiles not found", http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+
+	// A port nothing listens on.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedURL := "http://" + listener.Addr().String()
+	listener.Close()
+
+	s := NewHealthService([]Dependency{
+		{Name: "valhalla", Checker: valhalla.NewClient(upstream.URL), Critical: true},
+		{Name: "closed", Checker: valhalla.NewClient(closedURL), Critical: false},
+	}, HealthOptions{Timeout: time.Second})
+
+	ctx := logging.WithLogger(context.Background(), slog.New(slog.DiscardHandler))
+
+	// Each probe calls the upstream once, and failed probes don't open a circuit breaker.
+	for i := range 10 {
+		report := s.Readiness(ctx)
+		if report.Status != HealthStatusDown {
+			t.Fatalf("status = %s, want %s", report.Status, HealthStatusDown)
+		}
+		if got := report.Dependencies[0].Error; got != "status 5xx" {
+			t.Errorf("error of valhalla = %q, want %q", got, "status 5xx")
+		}
+		if got := report.Dependencies[1].Error; got != "unreachable" {
+			t.Errorf("error of closed = %q, want %q", got, "unreachable")
+		}
+		if got := calls.Load(); got != int32(i+1) {
+			t.Fatalf("upstream called %d times after %d probes", got, i+1)
+		}
+	}
+}
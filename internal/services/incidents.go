@@ -2,9 +2,11 @@ package services
 
 import (
 	"context"
-	"log"
-	"math"
+	"supmap-gis/internal/geo"
+	"supmap-gis/internal/logging"
 	supmapIncidents "supmap-gis/internal/providers/supmap-incidents"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type IncidentsClient interface {
@@ -19,26 +21,59 @@ func NewIncidentsService(client IncidentsClient) *IncidentsService {
 	return &IncidentsService{client: client}
 }
 
-func (s *IncidentsService) IncidentsAroundLocations(ctx context.Context, locations []Point) []Point {
+// Incident is an incident reported near the locations of a route.
+type Incident struct {
+	Point
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+	// Blocking incidents need the route to be recalculated to avoid them.
+	Blocking bool `json:"blocking"`
+}
+
+func (s *IncidentsService) IncidentsAroundLocations(ctx context.Context, locations []Point) []Incident {
+	ctx, span := tracer.Start(ctx, "IncidentsService.IncidentsAroundLocations")
+	defer span.End()
+
 	centerLat, centerLon, radius := computeLocationsBoundingCircle(locations)
+	span.SetAttributes(attribute.Int("incidents.radius", int(radius)))
 
 	incidents, err := s.client.IncidentsInRadius(ctx, centerLat, centerLon, radius)
 	if err != nil {
-		log.Printf("Error getting incidents: %v", err)
-		return []Point{}
+		// Routes are calculated without incidents, so the error is only recorded.
+		span.RecordError(err)
+		logging.FromContext(ctx).Warn("Error getting incidents, the route ignores them", "error", err)
+		return []Incident{}
 	}
+	span.SetAttributes(attribute.Int("incidents.count", len(incidents)))
 
-	incidentsPoints := make([]Point, 0, len(incidents))
+	result := make([]Incident, 0, len(incidents))
 	for _, incident := range incidents {
-		if incident.Type != nil && incident.Type.NeedRecalculation {
-			incidentsPoints = append(incidentsPoints, Point{
+		i := Incident{
+			Point: Point{
 				Lat: incident.Latitude,
 				Lon: incident.Longitude,
-			})
+			},
+			ID: incident.ID,
+		}
+		if incident.Type != nil {
+			i.Type = incident.Type.Name
+			i.Blocking = incident.Type.NeedRecalculation
 		}
+		result = append(result, i)
 	}
 
-	return incidentsPoints
+	return result
+}
+
+// blockingIncidentsPoints returns the position of the incidents that need to be avoided.
+func blockingIncidentsPoints(incidents []Incident) []Point {
+	points := make([]Point, 0, len(incidents))
+	for _, incident := range incidents {
+		if incident.Blocking {
+			points = append(points, incident.Point)
+		}
+	}
+	return points
 }
 
 // computeLocationsBoundingCircle calcule un cercle englobant tous les points de locations.
@@ -53,7 +88,7 @@ func computeLocationsBoundingCircle(locations []Point) (centerLat, centerLon flo
 
 	maxDist := 0.0
 	for _, loc := range locations {
-		dist := haversine(centerLat, centerLon, loc.Lat, loc.Lon)
+		dist := geo.Haversine(centerLat, centerLon, loc.Lat, loc.Lon)
 		if dist > maxDist {
 			maxDist = dist
 		}
@@ -61,16 +96,7 @@ func computeLocationsBoundingCircle(locations []Point) (centerLat, centerLon flo
 	return centerLat, centerLon, supmapIncidents.RadiusMeter(maxDist * 1.6)
 }
 
-// Haversine distance in meters
-func haversine(lat1, lon1, lat2, lon2 float64) float64 {
-	const R = 6371000 // m
-	phi1 := lat1 * math.Pi / 180
-	phi2 := lat2 * math.Pi / 180
-	dPhi := (lat2 - lat1) * math.Pi / 180
-	dLambda := (lon2 - lon1) * math.Pi / 180
-
-	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) +
-		math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
-	return R * c
+// Distance returns the great-circle distance in meters between a and b.
+func Distance(a, b Point) float64 {
+	return geo.Haversine(a.Lat, a.Lon, b.Lat, b.Lon)
 }
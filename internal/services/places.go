@@ -0,0 +1,509 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"supmap-gis/internal/geo"
+	"supmap-gis/internal/providers/nominatim"
+	"supmap-gis/internal/providers/valhalla"
+	"sync"
+)
+
+type MatrixClient interface {
+	Matrix(ctx context.Context, matrixRequest valhalla.MatrixRequest) (*valhalla.MatrixResponse, error)
+}
+
+type LookupClient interface {
+	Lookup(ctx context.Context, params nominatim.LookupParams) ([]nominatim.GeocodeResult, error)
+	Details(ctx context.Context, params nominatim.LookupParams) (*nominatim.DetailsResult, error)
+}
+
+// PlacesService finds points of interest of a given category around a position or along a route,
+// and fetches the details of known places.
+type PlacesService struct {
+	client       GeocodingClient
+	lookupClient LookupClient
+	matrixClient MatrixClient
+}
+
+func NewPlacesService(client GeocodingClient, lookupClient LookupClient, matrixClient MatrixClient) *PlacesService {
+	return &PlacesService{
+		client:       client,
+		lookupClient: lookupClient,
+		matrixClient: matrixClient,
+	}
+}
+
+// PlaceCategory is the OSM amenity of a point of interest.
+type PlaceCategory string
+
+const (
+	PlaceCategoryFuel            PlaceCategory = "fuel"
+	PlaceCategoryParking         PlaceCategory = "parking"
+	PlaceCategoryChargingStation PlaceCategory = "charging_station"
+	PlaceCategoryHospital        PlaceCategory = "hospital"
+)
+
+func (c PlaceCategory) IsValid() bool {
+	switch c {
+	case PlaceCategoryFuel, PlaceCategoryParking, PlaceCategoryChargingStation, PlaceCategoryHospital:
+		return true
+	}
+	return false
+}
+
+// NearbySort is the order of the places returned by [PlacesService.Nearby].
+type NearbySort string
+
+const (
+	// NearbySortDistance sorts places by straight-line distance from the position.
+	NearbySortDistance NearbySort = "distance"
+	// NearbySortRoute sorts places by travel time from the position.
+	NearbySortRoute NearbySort = "route"
+	// NearbySortDetour sorts places by the time they add to the route. It requires a corridor.
+	NearbySortDetour NearbySort = "detour"
+)
+
+func (s NearbySort) IsValid() bool {
+	switch s {
+	case NearbySortDistance, NearbySortRoute, NearbySortDetour:
+		return true
+	}
+	return false
+}
+
+// ErrDetourWithoutCorridor is returned when places are sorted by detour without a route corridor.
+var ErrDetourWithoutCorridor = errors.New("sorting by detour requires a route")
+
+type NearbyParams struct {
+	Position Point
+	Category PlaceCategory
+	// Radius is the search radius around the position in meters or, when Corridor is set,
+	// the maximum distance of the places from the route.
+	Radius float64
+	// Corridor is the shape of the route along which places are searched, from the position
+	// to the destination.
+	Corridor []Point
+	Sort     NearbySort
+	// Costing is used to compute routed distances and detours.
+	Costing valhalla.Costing
+	Limit   int
+	// Language is the language tag of the results, e.g. "fr-BE".
+	Language string
+}
+
+type NearbyPlace struct {
+	Name        string  `json:"name"`
+	DisplayName string  `json:"display_name"`
+	Type        string  `json:"type"`
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
+	// Distance is the straight-line distance from the position in meters.
+	Distance float64 `json:"distance"`
+	// RouteDistance (in meters) and RouteTime (in seconds) are the travel distance and time from the
+	// position, only set when sorting by route or detour.
+	RouteDistance *float64 `json:"route_distance,omitempty"`
+	RouteTime     *float64 `json:"route_time,omitempty"`
+	// DetourTime is the time in seconds added to the route by stopping at the place,
+	// only set when sorting by detour.
+	DetourTime *float64 `json:"detour_time,omitempty"`
+}
+
+// nominatimMaxLimit is the maximum number of results Nominatim returns for a search.
+const nominatimMaxLimit = 40
+
+const (
+	// corridorWindowLength is the length in meters of the parts of a corridor searched separately,
+	// so that the results of a search aren't spread over the whole route.
+	corridorWindowLength = 25000
+	// maxCorridorWindows bounds the number of searches made for a corridor. The windows of
+	// longer corridors are longer.
+	maxCorridorWindows = 16
+	// corridorSearchConcurrency is the number of windows searched at the same time.
+	corridorSearchConcurrency = 4
+)
+
+// Nearby returns at most params.Limit places of the category around the position, or along the corridor.
+// When sorting by route or detour, places that can't be reached are left out.
+func (s *PlacesService) Nearby(ctx context.Context, params NearbyParams) ([]NearbyPlace, error) {
+	if params.Sort == NearbySortDetour && len(params.Corridor) < 2 {
+		return nil, ErrDetourWithoutCorridor
+	}
+
+	area := params.Corridor
+	if len(area) == 0 {
+		area = []Point{params.Position}
+	}
+
+	resp, err := s.searchArea(ctx, params, area)
+	if err != nil {
+		return nil, fmt.Errorf("searching %s places: %w", params.Category, err)
+	}
+
+	places := make([]NearbyPlace, 0, len(resp))
+	for _, result := range resp {
+		// Nominatim also matches places whose name contains the amenity.
+		if result.Category != "amenity" || result.Type != string(params.Category) {
+			continue
+		}
+
+		lat, err := strconv.ParseFloat(result.Lat, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse latitude: %w", err)
+		}
+		lon, err := strconv.ParseFloat(result.Lon, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse longitude: %w", err)
+		}
+		point := Point{Lat: lat, Lon: lon}
+
+		if distanceToShape(point, area) > params.Radius {
+			continue
+		}
+
+		places = append(places, NearbyPlace{
+			Name:        result.Name,
+			DisplayName: result.DisplayName,
+			Type:        result.Type,
+			Lat:         lat,
+			Lon:         lon,
+			Distance:    geo.Haversine(params.Position.Lat, params.Position.Lon, lat, lon),
+		})
+	}
+
+	switch params.Sort {
+	case NearbySortRoute, NearbySortDetour:
+		places, err = s.addRoutes(ctx, places, params)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(places, func(i, j int) bool {
+		switch params.Sort {
+		case NearbySortRoute:
+			return *places[i].RouteTime < *places[j].RouteTime
+		case NearbySortDetour:
+			return *places[i].DetourTime < *places[j].DetourTime
+		default:
+			return places[i].Distance < places[j].Distance
+		}
+	})
+
+	if len(places) > params.Limit {
+		places = places[:params.Limit]
+	}
+	return places, nil
+}
+
+// addRoutes sets the travel distance and time from the position to each place and, when sorting
+// by detour, the time added to the route. Places that can't be reached are removed.
+func (s *PlacesService) addRoutes(ctx context.Context, places []NearbyPlace, params NearbyParams) ([]NearbyPlace, error) {
+	if len(places) == 0 {
+		return places, nil
+	}
+
+	targets := make([]valhalla.Coordinate, len(places))
+	for i, place := range places {
+		targets[i] = valhalla.Coordinate{Lat: place.Lat, Lon: place.Lon}
+	}
+	position := valhalla.Coordinate{Lat: params.Position.Lat, Lon: params.Position.Lon}
+
+	detour := params.Sort == NearbySortDetour
+	var destination valhalla.Coordinate
+	if detour {
+		last := params.Corridor[len(params.Corridor)-1]
+		destination = valhalla.Coordinate{Lat: last.Lat, Lon: last.Lon}
+	}
+
+	// The direct route from the position to the destination is the last target of the first row.
+	fromPosition, err := s.matrix(ctx, []valhalla.Coordinate{position}, appendIf(targets, destination, detour), params.Costing)
+	if err != nil {
+		return nil, err
+	}
+
+	var toDestination [][]valhalla.MatrixCell
+	if detour {
+		toDestination, err = s.matrix(ctx, targets, []valhalla.Coordinate{destination}, params.Costing)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var direct *float64
+	if detour {
+		direct = fromPosition[0][len(places)].Time
+		if direct == nil {
+			return nil, errors.New("destination can't be reached from the position")
+		}
+	}
+
+	reachable := places[:0]
+	for i, place := range places {
+		cell := fromPosition[0][i]
+		if cell.Distance == nil || cell.Time == nil {
+			continue
+		}
+		routeDistance := *cell.Distance * 1000
+		place.RouteDistance = &routeDistance
+		place.RouteTime = cell.Time
+
+		if detour {
+			toDestinationTime := toDestination[i][0].Time
+			if toDestinationTime == nil {
+				continue
+			}
+			detourTime := math.Max(0, *cell.Time+*toDestinationTime-*direct)
+			place.DetourTime = &detourTime
+		}
+
+		reachable = append(reachable, place)
+	}
+	return reachable, nil
+}
+
+// matrix returns the travel times and distances in kilometers between sources and targets,
+// as a row per source with a cell per target.
+func (s *PlacesService) matrix(ctx context.Context, sources, targets []valhalla.Coordinate, costing valhalla.Costing) ([][]valhalla.MatrixCell, error) {
+	resp, err := s.matrixClient.Matrix(ctx, valhalla.MatrixRequest{
+		Sources: sources,
+		Targets: targets,
+		Costing: costing,
+		Units:   valhalla.UnitsKilometers,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("computing travel times: %w", err)
+	}
+
+	if len(resp.SourcesToTargets) != len(sources) {
+		return nil, fmt.Errorf("unexpected matrix size: %d rows for %d sources", len(resp.SourcesToTargets), len(sources))
+	}
+	for _, row := range resp.SourcesToTargets {
+		if len(row) != len(targets) {
+			return nil, fmt.Errorf("unexpected matrix size: %d cells for %d targets", len(row), len(targets))
+		}
+	}
+	return resp.SourcesToTargets, nil
+}
+
+func appendIf(coordinates []valhalla.Coordinate, coordinate valhalla.Coordinate, ok bool) []valhalla.Coordinate {
+	if !ok {
+		return coordinates
+	}
+	return append(coordinates[:len(coordinates):len(coordinates)], coordinate)
+}
+
+// searchArea searches the places of the category within params.Radius of area. A corridor is
+// searched window by window, and the places found in several windows are returned once.
+func (s *PlacesService) searchArea(ctx context.Context, params NearbyParams, area []Point) ([]nominatim.GeocodeResult, error) {
+	windows := [][]Point{area}
+	if len(area) > 1 {
+		windows = corridorWindows(area, math.Max(corridorWindowLength, shapeLength(area)/maxCorridorWindows))
+	}
+
+	results := make([][]nominatim.GeocodeResult, len(windows))
+	errs := make([]error, len(windows))
+	semaphore := make(chan struct{}, corridorSearchConcurrency)
+
+	var wg sync.WaitGroup
+	for i, window := range windows {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			results[i], errs[i] = s.client.Search(ctx, nominatim.SearchParams{
+				Amenity:  string(params.Category),
+				ViewBox:  boundingViewBox(window, params.Radius),
+				Bounded:  true,
+				Limit:    nominatimMaxLimit,
+				Language: params.Language,
+			})
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	type osmObject struct {
+		osmType string
+		osmID   int64
+	}
+	seen := make(map[osmObject]bool)
+	var merged []nominatim.GeocodeResult
+	for _, windowResults := range results {
+		for _, result := range windowResults {
+			key := osmObject{result.OSMType, result.OSMID}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, result)
+		}
+	}
+	return merged, nil
+}
+
+// corridorWindows splits corridor into consecutive parts of about length meters. A segment
+// longer than a window is cut, so that the bounding box of each window stays small.
+func corridorWindows(corridor []Point, length float64) [][]Point {
+	var windows [][]Point
+	window := []Point{corridor[0]}
+	covered := 0.0
+	for _, p := range corridor[1:] {
+		prev := window[len(window)-1]
+		if covered >= length {
+			windows = append(windows, window)
+			window, covered = []Point{prev}, 0
+		}
+
+		d := Distance(prev, p)
+		for covered+d > length {
+			f := (length - covered) / d
+			cut := Point{Lat: prev.Lat + f*(p.Lat-prev.Lat), Lon: prev.Lon + f*(p.Lon-prev.Lon)}
+			windows = append(windows, append(window, cut))
+			window, covered = []Point{cut}, 0
+			prev, d = cut, Distance(cut, p)
+		}
+		window = append(window, p)
+		covered += d
+	}
+	return append(windows, window)
+}
+
+// shapeLength returns the length of shape in meters.
+func shapeLength(shape []Point) float64 {
+	length := 0.0
+	for i := 1; i < len(shape); i++ {
+		length += Distance(shape[i-1], shape[i])
+	}
+	return length
+}
+
+// boundingViewBox returns the bounding box of points, extended by margin meters on each side.
+func boundingViewBox(points []Point, margin float64) *nominatim.ViewBox {
+	const metersPerDegree = 6371000 * math.Pi / 180
+
+	box := &nominatim.ViewBox{
+		MinLon: points[0].Lon, MinLat: points[0].Lat,
+		MaxLon: points[0].Lon, MaxLat: points[0].Lat,
+	}
+	for _, p := range points[1:] {
+		box.MinLon, box.MaxLon = math.Min(box.MinLon, p.Lon), math.Max(box.MaxLon, p.Lon)
+		box.MinLat, box.MaxLat = math.Min(box.MinLat, p.Lat), math.Max(box.MaxLat, p.Lat)
+	}
+
+	latMargin := margin / metersPerDegree
+	// The longitude margin is computed at the latitude farthest from the equator, where it's the largest.
+	maxAbsLat := math.Min(math.Max(math.Abs(box.MinLat), math.Abs(box.MaxLat))+latMargin, 89)
+	lonMargin := margin / (metersPerDegree * math.Cos(maxAbsLat*math.Pi/180))
+
+	box.MinLat, box.MaxLat = math.Max(box.MinLat-latMargin, -90), math.Min(box.MaxLat+latMargin, 90)
+	box.MinLon, box.MaxLon = math.Max(box.MinLon-lonMargin, -180), math.Min(box.MaxLon+lonMargin, 180)
+	return box
+}
+
+// distanceToShape returns the distance in meters between p and the closest point of shape.
+func distanceToShape(p Point, shape []Point) float64 {
+	if len(shape) == 1 {
+		return geo.Haversine(p.Lat, p.Lon, shape[0].Lat, shape[0].Lon)
+	}
+
+	distance := math.Inf(1)
+	for i := 1; i < len(shape); i++ {
+		distance = math.Min(distance, distanceToSegment(p, shape[i-1], shape[i]))
+	}
+	return distance
+}
+
+// PlaceDetails is the current state of an OSM object.
+type PlaceDetails struct {
+	OSMType     string             `json:"osm_type"`
+	OSMID       int64              `json:"osm_id"`
+	Name        string             `json:"name"`
+	DisplayName string             `json:"display_name"`
+	Category    string             `json:"category"`
+	Type        string             `json:"type"`
+	Lat         float64            `json:"lat"`
+	Lon         float64            `json:"lon"`
+	Address     *nominatim.Address `json:"address,omitempty"`
+	// Geometry is the GeoJSON geometry of the place, simplified for large objects.
+	Geometry *nominatim.Geometry `json:"geometry,omitempty"`
+	// BoundingBox is [minLon, minLat, maxLon, maxLat], as in GeoJSON.
+	BoundingBox []float64 `json:"bbox,omitempty"`
+	// ExtraTags contains the additional OSM tags of the place, e.g. "opening_hours" or "website".
+	ExtraTags map[string]string `json:"extratags,omitempty"`
+	// Names contains all the names of the place, e.g. "name:en" or "alt_name".
+	Names     map[string]string `json:"names,omitempty"`
+	Wikipedia string            `json:"wikipedia,omitempty"`
+	// UpdatedAt is the date of the last update of the place in the database.
+	UpdatedAt string `json:"updated_at,omitempty"`
+}
+
+// Details returns the current details of the OSM object, or nil if it doesn't exist.
+func (s *PlacesService) Details(ctx context.Context, params nominatim.LookupParams) (*PlaceDetails, error) {
+	resp, err := s.lookupClient.Lookup(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("looking up %s%d: %w", params.OSMType, params.OSMID, err)
+	}
+	if len(resp) == 0 {
+		return nil, nil
+	}
+	result := resp[0]
+
+	lat, err := strconv.ParseFloat(result.Lat, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse latitude: %w", err)
+	}
+	lon, err := strconv.ParseFloat(result.Lon, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse longitude: %w", err)
+	}
+
+	place := &PlaceDetails{
+		OSMType:     result.OSMType,
+		OSMID:       result.OSMID,
+		Name:        result.Name,
+		DisplayName: result.DisplayName,
+		Category:    result.Category,
+		Type:        result.Type,
+		Lat:         lat,
+		Lon:         lon,
+		Address:     result.Address,
+		Geometry:    result.GeoJSON,
+		ExtraTags:   result.ExtraTags,
+	}
+
+	// Nominatim's bounding boxes are [minLat, maxLat, minLon, maxLon].
+	if len(result.BoundingBox) == 4 {
+		bbox := make([]float64, 4)
+		for i, value := range result.BoundingBox {
+			if bbox[i], err = strconv.ParseFloat(value, 64); err != nil {
+				return nil, fmt.Errorf("failed to parse bounding box: %w", err)
+			}
+		}
+		place.BoundingBox = []float64{bbox[2], bbox[0], bbox[3], bbox[1]}
+	}
+
+	details, err := s.lookupClient.Details(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("fetching details of %s%d: %w", params.OSMType, params.OSMID, err)
+	}
+	if details != nil {
+		place.Names = details.Names
+		place.Wikipedia = details.CalculatedWikipedia
+		place.UpdatedAt = details.IndexedDate
+		if place.ExtraTags == nil {
+			place.ExtraTags = details.ExtraTags
+		}
+	}
+
+	return place, nil
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"supmap-gis/internal/providers/nominatim"
+	"sync"
+	"testing"
+)
+
+// fakePlacesClient returns the places of its list within the searched view box.
+type fakePlacesClient struct {
+	places []nominatim.GeocodeResult
+
+	mu       sync.Mutex
+	searches []nominatim.SearchParams
+}
+
+func (c *fakePlacesClient) Search(ctx context.Context, params nominatim.SearchParams) ([]nominatim.GeocodeResult, error) {
+	c.mu.Lock()
+	c.searches = append(c.searches, params)
+	c.mu.Unlock()
+
+	var results []nominatim.GeocodeResult
+	for _, place := range c.places {
+		var lat, lon float64
+		fmt.Sscan(place.Lat, &lat)
+		fmt.Sscan(place.Lon, &lon)
+		box := params.ViewBox
+		if lat >= box.MinLat && lat <= box.MaxLat && lon >= box.MinLon && lon <= box.MaxLon && len(results) < params.Limit {
+			results = append(results, place)
+		}
+	}
+	return results, nil
+}
+
+func (c *fakePlacesClient) Reverse(ctx context.Context, params nominatim.ReverseParams) (*nominatim.ReverseResult, error) {
+	return nil, nil
+}
+
+func fuelStation(id int64, lat, lon float64) nominatim.GeocodeResult {
+	return nominatim.GeocodeResult{
+		OSMType:  "node",
+		OSMID:    id,
+		Name:     fmt.Sprintf("station %d", id),
+		Lat:      fmt.Sprint(lat),
+		Lon:      fmt.Sprint(lon),
+		Category: "amenity",
+		Type:     "fuel",
+	}
+}
+
+func TestNearbyAlongCorridor(t *testing.T) {
+	// A 200 km route due north from Caen, with a station every 0.01° along it: far more
+	// than a single search returns.
+	corridor := []Point{{Lat: 49.18, Lon: -0.37}, {Lat: 50.98, Lon: -0.37}}
+	var places []nominatim.GeocodeResult
+	for i := range 181 {
+		places = append(places, fuelStation(int64(i), 49.18+float64(i)*0.01, -0.37))
+	}
+	client := &fakePlacesClient{places: places}
+	s := NewPlacesService(client, nil, nil)
+
+	got, err := s.Nearby(context.Background(), NearbyParams{
+		Position: corridor[0],
+		Category: PlaceCategoryFuel,
+		Radius:   200,
+		Corridor: corridor,
+		Sort:     NearbySortDistance,
+		Limit:    1000,
+	})
+	if err != nil {
+		t.Fatalf("Nearby() error = %v", err)
+	}
+
+	if n := len(client.searches); n < 8 || n > maxCorridorWindows {
+		t.Errorf("%d searches, want one per window of %d m", n, corridorWindowLength)
+	}
+	// Every station on the route is found once, whatever the window it's in.
+	if len(got) != 181 {
+		t.Fatalf("%d places, want 181", len(got))
+	}
+	seen := make(map[string]bool)
+	for i, place := range got {
+		if seen[place.Name] {
+			t.Errorf("%s returned twice", place.Name)
+		}
+		seen[place.Name] = true
+		if i > 0 && place.Distance < got[i-1].Distance {
+			t.Errorf("places not sorted by distance at %d", i)
+		}
+	}
+}
+
+func TestNearbyAroundPosition(t *testing.T) {
+	client := &fakePlacesClient{places: []nominatim.GeocodeResult{
+		fuelStation(1, 49.181, -0.37),
+		fuelStation(2, 49.19, -0.37),
+	}}
+	s := NewPlacesService(client, nil, nil)
+
+	got, err := s.Nearby(context.Background(), NearbyParams{
+		Position: Point{Lat: 49.18, Lon: -0.37},
+		Category: PlaceCategoryFuel,
+		Radius:   500,
+		Sort:     NearbySortDistance,
+		Limit:    10,
+	})
+	if err != nil {
+		t.Fatalf("Nearby() error = %v", err)
+	}
+	if len(client.searches) != 1 {
+		t.Errorf("%d searches, want 1", len(client.searches))
+	}
+	if len(got) != 1 || got[0].Name != "station 1" {
+		t.Errorf("Nearby() = %+v, want station 1", got)
+	}
+}
+
+func TestCorridorWindows(t *testing.T) {
+	tests := []struct {
+		name        string
+		corridor    []Point
+		length      float64
+		wantWindows int
+	}{
+		{"short corridor", []Point{{Lat: 49, Lon: 0}, {Lat: 49.01, Lon: 0}}, 25000, 1},
+		// About 111 km in a single segment.
+		{"long segment is cut", []Point{{Lat: 49, Lon: 0}, {Lat: 50, Lon: 0}}, 25000, 5},
+		{"many short segments", densify(Point{Lat: 49, Lon: 0}, Point{Lat: 50, Lon: 0}, 1000), 25000, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			windows := corridorWindows(tt.corridor, tt.length)
+			if len(windows) != tt.wantWindows {
+				t.Fatalf("%d windows, want %d", len(windows), tt.wantWindows)
+			}
+
+			// The windows cover the corridor, end to end, and none is longer than length.
+			if windows[0][0] != tt.corridor[0] || last(windows[len(windows)-1]) != last(tt.corridor) {
+				t.Error("windows don't cover the corridor")
+			}
+			total := 0.0
+			for i, window := range windows {
+				if i > 0 && window[0] != last(windows[i-1]) {
+					t.Errorf("window %d doesn't start at the end of the previous one", i)
+				}
+				if l := shapeLength(window); l > tt.length+1 {
+					t.Errorf("window %d is %.0f m long, want at most %.0f m", i, l, tt.length)
+				}
+				total += shapeLength(window)
+			}
+			if want := shapeLength(tt.corridor); math.Abs(total-want) > 1 {
+				t.Errorf("windows are %.0f m long, want %.0f m", total, want)
+			}
+		})
+	}
+}
+
+func densify(from, to Point, n int) []Point {
+	points := make([]Point, n+1)
+	for i := range points {
+		f := float64(i) / float64(n)
+		points[i] = Point{Lat: from.Lat + f*(to.Lat-from.Lat), Lon: from.Lon + f*(to.Lon-from.Lon)}
+	}
+	return points
+}
+
+func last(points []Point) Point {
+	return points[len(points)-1]
+}
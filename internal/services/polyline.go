@@ -6,16 +6,24 @@ import (
 	"math"
 )
 
+// MaxPolylinePrecision is the highest precision of an encoded polyline, already far below
+// a millimeter.
+const MaxPolylinePrecision = 10
+
 // DecodePolyline decodes a Google encoded Polyline string into a slice of coordinates.
 // The precision parameter defines the number of decimal digits used when encoding.
 // If precision is zero or negative, the function defaults to 6 digits (1e-6 precision).
 //
 // It returns a slice of [Point] structs, each containing latitude and longitude in
-// decimal degrees, or an error if the input string is malformed.
+// decimal degrees, or an error if the input string is malformed or the precision is
+// above [MaxPolylinePrecision].
 func DecodePolyline(encoded string, precision int) ([]Point, error) {
 	if precision <= 0 {
 		precision = 6
 	}
+	if precision > MaxPolylinePrecision {
+		return nil, fmt.Errorf("decodePolyline: precision %d is above %d", precision, MaxPolylinePrecision)
+	}
 	factor := math.Pow10(precision)
 
 	idx := 0
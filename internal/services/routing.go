@@ -2,8 +2,12 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"supmap-gis/internal/providers/valhalla"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type RoutingClient interface {
@@ -13,16 +17,81 @@ type RoutingClient interface {
 type RoutingService struct {
 	client           RoutingClient
 	incidentsService *IncidentsService
+	elevationService *ElevationService
+	tollService      *TollService
+	options          RoutingOptions
+}
+
+type RoutingOptions struct {
+	// AlternatesMaxOverlap is the ratio of shared geometry (between 0 and 1) above which
+	// an alternate is considered a duplicate of another trip and is dropped.
+	AlternatesMaxOverlap float64
+	// VehicleProfiles are the profiles that can be used to estimate the consumption of a trip.
+	VehicleProfiles map[VehicleProfileName]VehicleProfile
+	// ObserveExcludedIncidents, if not nil, is called with the number of blocking incidents
+	// excluded from each route calculation.
+	ObserveExcludedIncidents func(count int)
 }
 
-func NewRoutingService(client RoutingClient, incidentsService *IncidentsService) *RoutingService {
-	return &RoutingService{client: client, incidentsService: incidentsService}
+func DefaultRoutingOptions() RoutingOptions {
+	return RoutingOptions{
+		AlternatesMaxOverlap: 0.9,
+		VehicleProfiles:      DefaultVehicleProfiles(),
+	}
 }
 
-func (s *RoutingService) CalculateRoute(ctx context.Context, routeRequest valhalla.RouteRequest) (*[]Trip, error) {
+// NewRoutingService creates a routing service. The elevation and toll services are optional and may be nil,
+// in which case elevation profiles can't be requested and toll costs aren't estimated.
+func NewRoutingService(client RoutingClient, incidentsService *IncidentsService, elevationService *ElevationService, tollService *TollService, options ...RoutingOptions) *RoutingService {
+	opts := DefaultRoutingOptions()
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	return &RoutingService{
+		client:           client,
+		incidentsService: incidentsService,
+		elevationService: elevationService,
+		tollService:      tollService,
+		options:          opts,
+	}
+}
+
+// ErrElevationUnavailable is returned when an elevation profile is requested but no elevation provider is configured.
+var ErrElevationUnavailable = errors.New("elevation is not available")
+
+// RouteOptions are the per-request options that don't belong to the Valhalla request.
+type RouteOptions struct {
+	// Elevation adds an elevation profile to each leg.
+	Elevation bool
+	// VehicleProfile is used to estimate the consumption of the trips.
+	// If empty, a default profile is chosen according to the costing.
+	VehicleProfile VehicleProfileName
+}
+
+func (s *RoutingService) CalculateRoute(ctx context.Context, routeRequest valhalla.RouteRequest, options RouteOptions) (_ *[]Trip, err error) {
+	ctx, span := tracer.Start(ctx, "RoutingService.CalculateRoute", trace.WithAttributes(
+		attribute.String("route.costing", string(routeRequest.Costing)),
+		attribute.Int("route.locations", len(routeRequest.Locations)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if options.Elevation && s.elevationService == nil {
+		return nil, ErrElevationUnavailable
+	}
+
+	vehicleProfile, err := s.vehicleProfile(options.VehicleProfile, routeRequest.Costing)
+	if err != nil {
+		return nil, err
+	}
+
 	locationsPoints := extractPointsFromLocations(routeRequest.Locations)
 	incidents := s.incidentsService.IncidentsAroundLocations(ctx, locationsPoints)
-	excludes := pointsToExcludeLocations(incidents)
+	excludes := pointsToExcludeLocations(blockingIncidentsPoints(incidents))
+	span.SetAttributes(attribute.Int("route.excluded_incidents", len(excludes)))
+	if s.options.ObserveExcludedIncidents != nil {
+		s.options.ObserveExcludedIncidents(len(excludes))
+	}
 
 	// Add incidents coordinates to the locations to avoid
 	routeRequest.ExcludeLocations = append(routeRequest.ExcludeLocations, excludes...)
@@ -48,9 +117,47 @@ func (s *RoutingService) CalculateRoute(ctx context.Context, routeRequest valhal
 		respTrips = append(respTrips, *trip)
 	}
 
+	respTrips = compareTrips(respTrips, incidents, s.options.AlternatesMaxOverlap)
+
+	if options.Elevation {
+		if err := s.elevationService.AddLegsElevation(ctx, respTrips); err != nil {
+			return nil, fmt.Errorf("elevation: %w", err)
+		}
+	}
+
+	if vehicleProfile != nil {
+		for i := range respTrips {
+			consumption := vehicleProfile.Estimate(tripRoadUsage(respTrips[i]))
+			respTrips[i].Summary.Consumption = &consumption
+		}
+	}
+
+	if s.tollService != nil {
+		for i := range respTrips {
+			respTrips[i].Summary.Tolls = s.tollService.Estimate(respTrips[i], routeRequest.Costing)
+		}
+	}
+
 	return &respTrips, nil
 }
 
+// vehicleProfile returns the requested vehicle profile, or the default one for the costing.
+// It returns nil if the costing has no default profile.
+func (s *RoutingService) vehicleProfile(name VehicleProfileName, costing valhalla.Costing) (VehicleProfile, error) {
+	if name == "" {
+		var ok bool
+		if name, ok = defaultVehicleProfileName(costing); !ok {
+			return nil, nil
+		}
+	}
+
+	profile, ok := s.options.VehicleProfiles[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownVehicleProfile, name)
+	}
+	return profile, nil
+}
+
 func extractPointsFromLocations(locations []valhalla.LocationRequest) []Point {
 	points := make([]Point, 0, len(locations))
 	for _, loc := range locations {
@@ -78,23 +185,61 @@ type Maneuver struct {
 	BeginShapeIndex     uint     `json:"begin_shape_index"`
 	EndShapeIndex       uint     `json:"end_shape_index"`
 	RoundaboutExitCount *uint8   `json:"roundabout_exit_count,omitempty"`
+	Toll                bool     `json:"toll"`
+	Highway             bool     `json:"highway"`
+	Ferry               bool     `json:"ferry"`
+	Sign                *Sign    `json:"sign,omitempty"`
+}
+
+// Sign is the interchange sign of a maneuver, with the texts of each kind of element.
+type Sign struct {
+	ExitNumbers  []string `json:"exit_numbers,omitempty"`
+	ExitBranches []string `json:"exit_branches,omitempty"`
+	ExitToward   []string `json:"exit_toward,omitempty"`
+	ExitNames    []string `json:"exit_names,omitempty"`
 }
 
 type Summary struct {
-	Time   float64 `json:"time"`
-	Length float64 `json:"length"`
+	Time                float64     `json:"time"`
+	Length              float64     `json:"length"`
+	Cost                float64     `json:"cost"`
+	HasToll             bool        `json:"has_toll"`
+	HasHighway          bool        `json:"has_highway"`
+	HasFerry            bool        `json:"has_ferry"`
+	HasTimeRestrictions bool        `json:"has_time_restrictions"`
+	BoundingBox         BoundingBox `json:"bounding_box"`
+	// Consumption is only estimated for whole trips with a motorized costing or a vehicle profile.
+	Consumption *Consumption `json:"consumption,omitempty"`
+	// Tolls is only estimated for whole trips, when toll tariffs are configured.
+	Tolls *TollCost `json:"tolls,omitempty"`
+}
+
+// BoundingBox is the smallest rectangle containing the shape of a [Leg] or a whole [Trip].
+// It allows clients to fit the map viewport without decoding the shape.
+type BoundingBox struct {
+	MinLat float64 `json:"min_lat"`
+	MinLon float64 `json:"min_lon"`
+	MaxLat float64 `json:"max_lat"`
+	MaxLon float64 `json:"max_lon"`
 }
 
 type Leg struct {
-	Maneuvers []Maneuver `json:"maneuvers"`
-	Summary   Summary    `json:"summary"`
-	Shape     []Point    `json:"shape"`
+	Maneuvers []Maneuver        `json:"maneuvers"`
+	Summary   Summary           `json:"summary"`
+	Shape     []Point           `json:"shape"`
+	Elevation *ElevationProfile `json:"elevation,omitempty"`
 }
 
 type Trip struct {
 	Locations []valhalla.LocationResponse `json:"locations"`
 	Legs      []Leg                       `json:"legs"`
 	Summary   Summary                     `json:"summary"`
+	Units     valhalla.Units              `json:"units"`
+	Language  valhalla.Language           `json:"language"`
+	// Labels explain why this trip may be preferred over the other trips of the response.
+	Labels []TripLabel `json:"labels"`
+	// SharedGeometry is the ratio of this trip shared with each other trip of the response.
+	SharedGeometry []SharedGeometry `json:"shared_geometry"`
 }
 
 // --- Mapping Valhalla -> DTO ---
@@ -112,13 +257,35 @@ func MapValhallaTrip(vt valhalla.Trip) (*Trip, error) {
 	return &Trip{
 		Locations: vt.Locations,
 		Legs:      legs,
-		Summary: Summary{
-			Time:   vt.Summary.Time,
-			Length: vt.Summary.Length,
-		},
+		Summary:   mapValhallaSummary(vt.Summary),
+		Units:     vt.Units,
+		Language:  vt.Language,
 	}, nil
 }
 
+// mapValhallaSign maps Valhalla's [valhalla.Sign] struct to a service DTO [Sign] struct.
+func mapValhallaSign(vs *valhalla.Sign) *Sign {
+	if vs == nil {
+		return nil
+	}
+	texts := func(elements []valhalla.ManeuverSignElement) []string {
+		if len(elements) == 0 {
+			return nil
+		}
+		texts := make([]string, len(elements))
+		for i, element := range elements {
+			texts[i] = element.Text
+		}
+		return texts
+	}
+	return &Sign{
+		ExitNumbers:  texts(vs.ExitNumberElements),
+		ExitBranches: texts(vs.ExitBranchElements),
+		ExitToward:   texts(vs.ExitTowardElements),
+		ExitNames:    texts(vs.ExitNameElements),
+	}
+}
+
 // mapValhallaLeg maps Valhalla's [valhalla.Leg] struct to a service DTO [Leg] struct.
 func mapValhallaLeg(vl valhalla.Leg) (*Leg, error) {
 	maneuvers := make([]Maneuver, len(vl.Maneuvers))
@@ -132,6 +299,10 @@ func mapValhallaLeg(vl valhalla.Leg) (*Leg, error) {
 			BeginShapeIndex:     m.BeginShapeIndex,
 			EndShapeIndex:       m.EndShapeIndex,
 			RoundaboutExitCount: m.RoundaboutExitCount,
+			Toll:                m.Toll != nil && *m.Toll,
+			Highway:             m.Highway != nil && *m.Highway,
+			Ferry:               m.Ferry != nil && *m.Ferry,
+			Sign:                mapValhallaSign(m.Sign),
 		}
 
 		// initialize StreetNames to an empty slice instead of returning a nil value
@@ -147,14 +318,30 @@ func mapValhallaLeg(vl valhalla.Leg) (*Leg, error) {
 
 	return &Leg{
 		Maneuvers: maneuvers,
-		Summary: Summary{
-			Time:   vl.Summary.Time,
-			Length: vl.Summary.Length,
-		},
-		Shape: shape,
+		Summary:   mapValhallaSummary(vl.Summary),
+		Shape:     shape,
 	}, nil
 }
 
+// mapValhallaSummary maps Valhalla's [valhalla.Summary] struct to a service DTO [Summary] struct.
+func mapValhallaSummary(vs valhalla.Summary) Summary {
+	return Summary{
+		Time:                vs.Time,
+		Length:              vs.Length,
+		Cost:                vs.Cost,
+		HasToll:             vs.HasToll,
+		HasHighway:          vs.HasHighway,
+		HasFerry:            vs.HasFerry,
+		HasTimeRestrictions: vs.HasTimeRestrictions,
+		BoundingBox: BoundingBox{
+			MinLat: vs.MinLat,
+			MinLon: vs.MinLon,
+			MaxLat: vs.MaxLat,
+			MaxLon: vs.MaxLon,
+		},
+	}
+}
+
 // Conversion []Point -> []valhalla.ExcludeLocations
 func pointsToExcludeLocations(points []Point) []valhalla.ExcludeLocations {
 	excludes := make([]valhalla.ExcludeLocations, 0, len(points))
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"supmap-gis/internal/lru"
+	"supmap-gis/internal/providers/valhalla"
+	"sync"
+	"time"
+)
+
+// CachedRoutingClient is a [RoutingClient] caching the routes of another one in a size-bounded
+// LRU cache, keyed by a hash of the normalized request.
+//
+// Coordinates are rounded in the key, so a cached route may have been computed for locations
+// up to half the precision away from the requested ones: its shape and the locations echoed by
+// Valhalla are then those of the first request.
+//
+// The excluded locations are part of the key. Since the routing service excludes the blocking
+// incidents around the locations, a cached route isn't used anymore once the set of relevant
+// blocking incidents changes.
+//
+// Cached routes are shared between callers, which must not modify them.
+type CachedRoutingClient struct {
+	client  RoutingClient
+	options RoutingCacheOptions
+	cache   *lru.Cache
+}
+
+type RoutingCacheOptions struct {
+	Size int
+	TTL  time.Duration
+	// Precision is the number of decimals to which coordinates are rounded. 4 decimals are about 11 meters.
+	Precision int
+}
+
+func DefaultRoutingCacheOptions() RoutingCacheOptions {
+	return RoutingCacheOptions{
+		Size:      1000,
+		TTL:       5 * time.Minute,
+		Precision: 4,
+	}
+}
+
+func NewCachedRoutingClient(client RoutingClient, options ...RoutingCacheOptions) *CachedRoutingClient {
+	opts := DefaultRoutingCacheOptions()
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	return &CachedRoutingClient{
+		client:  client,
+		options: opts,
+		cache:   lru.New(opts.Size),
+	}
+}
+
+func (c *CachedRoutingClient) Stats() lru.Stats {
+	return c.cache.Stats()
+}
+
+func (c *CachedRoutingClient) CalculateRoute(ctx context.Context, routeRequest valhalla.RouteRequest) (*valhalla.RouteResponse, error) {
+	key, err := routeCacheKey(routeRequest, c.options.Precision)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ttl, ok := c.cache.Get(key); ok {
+		recordCacheStatus(ctx, true, ttl)
+		// The ID is echoed by Valhalla and isn't part of the key.
+		resp := *value.(*valhalla.RouteResponse)
+		resp.ID = routeRequest.ID
+		return &resp, nil
+	}
+
+	resp, err := c.client.CalculateRoute(ctx, routeRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	c.cache.Set(key, resp, c.options.TTL)
+	recordCacheStatus(ctx, false, c.options.TTL)
+	return resp, nil
+}
+
+// routeCacheKey returns a hash of the request, with rounded coordinates and sorted excluded locations,
+// so that equivalent requests share the same key.
+func routeCacheKey(routeRequest valhalla.RouteRequest, precision int) (string, error) {
+	factor := math.Pow10(precision)
+	round := func(v float64) float64 {
+		return math.Round(v*factor) / factor
+	}
+
+	normalized := routeRequest
+	normalized.ID = nil
+
+	normalized.Locations = make([]valhalla.LocationRequest, len(routeRequest.Locations))
+	for i, location := range routeRequest.Locations {
+		location.Lat, location.Lon = round(location.Lat), round(location.Lon)
+		normalized.Locations[i] = location
+	}
+
+	normalized.ExcludeLocations = make([]valhalla.ExcludeLocations, len(routeRequest.ExcludeLocations))
+	for i, location := range routeRequest.ExcludeLocations {
+		normalized.ExcludeLocations[i] = valhalla.ExcludeLocations{Lat: round(location.Lat), Lon: round(location.Lon)}
+	}
+	slices.SortFunc(normalized.ExcludeLocations, func(a, b valhalla.ExcludeLocations) int {
+		return cmp.Or(cmp.Compare(a.Lat, b.Lat), cmp.Compare(a.Lon, b.Lon))
+	})
+	normalized.ExcludeLocations = slices.Compact(normalized.ExcludeLocations)
+
+	body, err := json.Marshal(normalized)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal route request: %w", err)
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// CacheStatus records whether a response was served from a cache, to report it in a Cache-Status
+// header (RFC 9211). It is attached to a request context with [WithCacheStatus].
+type CacheStatus struct {
+	mu       sync.Mutex
+	recorded bool
+	hit      bool
+	ttl      time.Duration
+}
+
+type cacheStatusKey struct{}
+
+// WithCacheStatus returns a context in which caches record their status into the returned [CacheStatus].
+func WithCacheStatus(ctx context.Context) (context.Context, *CacheStatus) {
+	status := &CacheStatus{}
+	return context.WithValue(ctx, cacheStatusKey{}, status), status
+}
+
+func recordCacheStatus(ctx context.Context, hit bool, ttl time.Duration) {
+	status, ok := ctx.Value(cacheStatusKey{}).(*CacheStatus)
+	if !ok {
+		return
+	}
+
+	status.mu.Lock()
+	defer status.mu.Unlock()
+	status.recorded, status.hit, status.ttl = true, hit, ttl
+}
+
+// Header returns the value of the Cache-Status header for a cache named name. If no cache
+// recorded its status, e.g. because it is disabled, the response is reported as bypassing it.
+func (s *CacheStatus) Header(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.recorded {
+		return name + "; fwd=bypass"
+	}
+	if s.hit {
+		return fmt.Sprintf("%s; hit; ttl=%d", name, int(s.ttl.Seconds()))
+	}
+	return fmt.Sprintf("%s; fwd=uri-miss; stored; ttl=%d", name, int(s.ttl.Seconds()))
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"supmap-gis/internal/providers/valhalla"
+	"testing"
+	"time"
+)
+
+func routeRequest(locations ...valhalla.LocationRequest) valhalla.RouteRequest {
+	return valhalla.RouteRequest{Locations: locations, Costing: valhalla.CostingAuto, Units: valhalla.UnitsKilometers}
+}
+
+func TestRouteCacheKey(t *testing.T) {
+	caen := valhalla.LocationRequest{Lat: 49.18291, Lon: -0.37072}
+	paris := valhalla.LocationRequest{Lat: 48.85661, Lon: 2.35222}
+	key := func(req valhalla.RouteRequest, precision int) string {
+		t.Helper()
+		key, err := routeCacheKey(req, precision)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	base := key(routeRequest(caen, paris), 4)
+
+	id := "request-1"
+	withID := routeRequest(caen, paris)
+	withID.ID = &id
+	// 3 m away from caen, with the same rounded coordinates.
+	nearCaen := valhalla.LocationRequest{Lat: 49.18289, Lon: -0.37068}
+	excluded := routeRequest(caen, paris)
+	excluded.ExcludeLocations = []valhalla.ExcludeLocations{{Lat: 49.1, Lon: 0.5}, {Lat: 49, Lon: 1}}
+	reordered := routeRequest(caen, paris)
+	reordered.ExcludeLocations = []valhalla.ExcludeLocations{{Lat: 49, Lon: 1}, {Lat: 49.1, Lon: 0.5}, {Lat: 49.100001, Lon: 0.5}}
+
+	same := []struct {
+		name string
+		key  string
+	}{
+		{"ID", key(withID, 4)},
+		{"close location", key(routeRequest(nearCaen, paris), 4)},
+	}
+	for _, tt := range same {
+		if tt.key != base {
+			t.Errorf("%s: key = %q, want %q", tt.name, tt.key, base)
+		}
+	}
+	if key(excluded, 4) != key(reordered, 4) {
+		t.Error("reordered and duplicated excluded locations have another key")
+	}
+
+	other := func(change func(*valhalla.RouteRequest)) string {
+		req := routeRequest(caen, paris)
+		change(&req)
+		return key(req, 4)
+	}
+	distinct := []struct {
+		name string
+		key  string
+	}{
+		{"reversed locations", key(routeRequest(paris, caen), 4)},
+		{"close location with more decimals", key(routeRequest(nearCaen, paris), 5)},
+		{"excluded locations", key(excluded, 4)},
+		{"costing", other(func(r *valhalla.RouteRequest) { r.Costing = valhalla.CostingBicycle })},
+		{"language", other(func(r *valhalla.RouteRequest) { r.Language = "en-US" })},
+		{"units", other(func(r *valhalla.RouteRequest) { r.Units = valhalla.UnitsMiles })},
+		{"alternates", other(func(r *valhalla.RouteRequest) { r.Alternates = 2 })},
+	}
+	seen := map[string]string{base: "base"}
+	for _, tt := range distinct {
+		if other, ok := seen[tt.key]; ok {
+			t.Errorf("%s: same key as %s", tt.name, other)
+		}
+		seen[tt.key] = tt.name
+	}
+}
+
+// countingRoutingClient returns a route echoing the requested locations, or an error.
+type countingRoutingClient struct {
+	err   error
+	calls int
+}
+
+func (c *countingRoutingClient) CalculateRoute(ctx context.Context, req valhalla.RouteRequest) (*valhalla.RouteResponse, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	resp := &valhalla.RouteResponse{ID: req.ID}
+	for _, location := range req.Locations {
+		resp.Trip.Locations = append(resp.Trip.Locations, valhalla.LocationResponse{Lat: location.Lat, Lon: location.Lon})
+	}
+	return resp, nil
+}
+
+func TestCachedRoutingClient(t *testing.T) {
+	client := &countingRoutingClient{}
+	c := NewCachedRoutingClient(client, RoutingCacheOptions{Size: 10, TTL: time.Hour, Precision: 4})
+
+	first, second := "first", "second"
+	req := routeRequest(valhalla.LocationRequest{Lat: 49.18291, Lon: -0.37072}, valhalla.LocationRequest{Lat: 48.85661, Lon: 2.35222})
+	req.ID = &first
+	ctx, status := WithCacheStatus(t.Context())
+	if _, err := c.CalculateRoute(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := status.Header("test"), "test; fwd=uri-miss; stored; ttl=3600"; got != want {
+		t.Errorf("Cache-Status = %q, want %q", got, want)
+	}
+
+	// A request a few meters away gets the cached route, with its own ID but the locations
+	// of the first request.
+	near := routeRequest(valhalla.LocationRequest{Lat: 49.18289, Lon: -0.37068}, req.Locations[1])
+	near.ID = &second
+	ctx, status = WithCacheStatus(t.Context())
+	resp, err := c.CalculateRoute(ctx, near)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.calls != 1 {
+		t.Errorf("%d calls to Valhalla, want 1", client.calls)
+	}
+	if got := status.Header("test"); got != "test; hit; ttl=3599" && got != "test; hit; ttl=3600" {
+		t.Errorf("Cache-Status = %q, want a hit", got)
+	}
+	if resp.ID == nil || *resp.ID != second {
+		t.Errorf("ID = %v, want %q", resp.ID, second)
+	}
+	if resp.Trip.Locations[0].Lat != 49.18291 {
+		t.Errorf("first location = %+v, want the one of the cached route", resp.Trip.Locations[0])
+	}
+
+	// Errors aren't cached.
+	failing := &countingRoutingClient{err: errors.New("unavailable")}
+	c = NewCachedRoutingClient(failing)
+	for range 2 {
+		if _, err := c.CalculateRoute(t.Context(), req); err == nil {
+			t.Fatal("CalculateRoute() succeeded")
+		}
+	}
+	if failing.calls != 2 {
+		t.Errorf("%d calls to Valhalla, want 2", failing.calls)
+	}
+}
+
+func TestCacheStatusBypass(t *testing.T) {
+	_, status := WithCacheStatus(t.Context())
+	if got, want := status.Header("test"), "test; fwd=bypass"; got != want {
+		t.Errorf("Cache-Status without a cache = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"math"
+	"strings"
+	"supmap-gis/internal/providers/tolls"
+	"supmap-gis/internal/providers/valhalla"
+	"unicode"
+)
+
+// TollService estimates the toll cost of trips from a tariffs data file.
+type TollService struct {
+	tariffs *tolls.Tariffs
+	options TollOptions
+	// sectionsByRoad indexes the sections by normalized road reference.
+	sectionsByRoad map[string][]*tolls.Section
+}
+
+type TollOptions struct {
+	// TruckClass is the vehicle class of the truck costing. Valhalla's truck costing doesn't tell
+	// the number of axles, so it defaults to heavy trucks with 3 axles or more, the most common
+	// class on French motorways, which may overestimate the toll of smaller trucks.
+	TruckClass tolls.VehicleClass
+}
+
+func DefaultTollOptions() TollOptions {
+	return TollOptions{
+		TruckClass: tolls.VehicleClassHeavyTruck,
+	}
+}
+
+func NewTollService(tariffs *tolls.Tariffs, options ...TollOptions) *TollService {
+	opts := DefaultTollOptions()
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	s := &TollService{
+		tariffs:        tariffs,
+		options:        opts,
+		sectionsByRoad: make(map[string][]*tolls.Section),
+	}
+	for i := range tariffs.Sections {
+		section := &tariffs.Sections[i]
+		for _, road := range section.Roads {
+			key := normalizeTollText(road)
+			s.sectionsByRoad[key] = append(s.sectionsByRoad[key], section)
+		}
+	}
+	return s
+}
+
+// TollCost is the estimated toll cost of a trip.
+type TollCost struct {
+	VehicleClass tolls.VehicleClass `json:"vehicle_class"`
+	Currency     string             `json:"currency"`
+	Total        float64            `json:"total"`
+	Sections     []TollSectionCost  `json:"sections"`
+	// UnmatchedKm is the distance driven on toll roads that couldn't be matched with a known section,
+	// in which case the total is underestimated.
+	UnmatchedKm float64 `json:"unmatched_km"`
+}
+
+type TollSectionCost struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	Operator   string  `json:"operator,omitempty"`
+	DistanceKm float64 `json:"distance_km"`
+	Price      float64 `json:"price"`
+}
+
+// vehicleClass returns the toll vehicle class of a costing, if it can use toll roads.
+func (s *TollService) vehicleClass(costing valhalla.Costing) (tolls.VehicleClass, bool) {
+	switch costing {
+	case valhalla.CostingAuto:
+		return tolls.VehicleClassCar, true
+	case valhalla.CostingTruck:
+		return s.options.TruckClass, true
+	case valhalla.CostingMotorScooter:
+		return tolls.VehicleClassMotorcycle, true
+	default:
+		return 0, false
+	}
+}
+
+// Estimate matches the toll maneuvers of the trip against the known sections and returns the
+// estimated cost. It returns nil if the costing can't use toll roads.
+func (s *TollService) Estimate(trip Trip, costing valhalla.Costing) *TollCost {
+	class, ok := s.vehicleClass(costing)
+	if !ok {
+		return nil
+	}
+
+	toKm := 1.0
+	if trip.Units == valhalla.UnitsMiles {
+		toKm = kilometersPerMile
+	}
+
+	cost := &TollCost{
+		VehicleClass: class,
+		Currency:     s.tariffs.Currency,
+		Sections:     []TollSectionCost{},
+	}
+
+	distances := make(map[*tolls.Section]float64)
+	var order []*tolls.Section
+	for _, leg := range trip.Legs {
+		for _, m := range leg.Maneuvers {
+			if !m.Toll {
+				continue
+			}
+			km := m.Length * toKm
+
+			section := s.matchSection(m)
+			if section == nil {
+				cost.UnmatchedKm += km
+				continue
+			}
+			if _, seen := distances[section]; !seen {
+				order = append(order, section)
+			}
+			distances[section] += km
+		}
+	}
+
+	for _, section := range order {
+		price, ok := section.Prices[class]
+		if !ok {
+			cost.UnmatchedKm += distances[section]
+			continue
+		}
+		if section.LengthKm > 0 {
+			price *= math.Min(1, distances[section]/section.LengthKm)
+		}
+		price = math.Round(price*100) / 100
+
+		cost.Sections = append(cost.Sections, TollSectionCost{
+			ID:         section.ID,
+			Name:       section.Name,
+			Operator:   section.Operator,
+			DistanceKm: distances[section],
+			Price:      price,
+		})
+		cost.Total += price
+	}
+	cost.Total = math.Round(cost.Total*100) / 100
+
+	return cost
+}
+
+// matchSection finds the section of a toll maneuver from its street names. When several sections
+// share a road, the one with the most signs in common with the maneuver is chosen.
+func (s *TollService) matchSection(m Maneuver) *tolls.Section {
+	var candidates []*tolls.Section
+	for _, name := range m.StreetNames {
+		candidates = append(candidates, s.sectionsByRoad[normalizeTollText(name)]...)
+	}
+	if len(candidates) <= 1 {
+		if len(candidates) == 1 {
+			return candidates[0]
+		}
+		return nil
+	}
+
+	signs := maneuverSignTexts(m)
+	best, bestScore := candidates[0], -1
+	for _, candidate := range candidates {
+		score := 0
+		for _, sign := range candidate.Signs {
+			if _, ok := signs[normalizeTollText(sign)]; ok {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	return best
+}
+
+// maneuverSignTexts returns the normalized texts of all the signs of a maneuver.
+func maneuverSignTexts(m Maneuver) map[string]struct{} {
+	texts := make(map[string]struct{})
+	if m.Sign == nil {
+		return texts
+	}
+	for _, elements := range [][]string{m.Sign.ExitNumbers, m.Sign.ExitBranches, m.Sign.ExitToward, m.Sign.ExitNames} {
+		for _, text := range elements {
+			texts[normalizeTollText(text)] = struct{}{}
+		}
+	}
+	return texts
+}
+
+// normalizeTollText uppercases text and removes spaces and punctuation,
+// so that "A 13", "a13" and "A-13" are considered equal.
+func normalizeTollText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
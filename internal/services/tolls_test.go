@@ -0,0 +1,174 @@
+package services
+
+import (
+	"math"
+	"supmap-gis/internal/providers/tolls"
+	"supmap-gis/internal/providers/valhalla"
+	"testing"
+)
+
+var testTariffs = &tolls.Tariffs{
+	Currency: "EUR",
+	Sections: []tolls.Section{
+		{
+			ID: "a13-caen-rouen", Name: "A13 Caen - Rouen", Operator: "SAPN",
+			Roads: []string{"A13"}, Signs: []string{"Caen", "Rouen"}, LengthKm: 110,
+			Prices: map[tolls.VehicleClass]float64{1: 10.5, 3: 23.1, 4: 31.2, 5: 6.3},
+		},
+		{
+			ID: "a13-rouen-paris", Name: "A13 Rouen - Paris", Operator: "SAPN",
+			Roads: []string{"A 13"}, Signs: []string{"Paris", "Mantes-la-Jolie"},
+			Prices: map[tolls.VehicleClass]float64{1: 8, 4: 24},
+		},
+		{
+			ID: "a84", Name: "A84 Caen - Rennes",
+			Roads:  []string{"A84", "E3"},
+			Prices: map[tolls.VehicleClass]float64{1: 5},
+		},
+	},
+}
+
+func tollManeuver(length float64, sign *Sign, streetNames ...string) Maneuver {
+	return Maneuver{Length: length, Toll: true, StreetNames: streetNames, Sign: sign}
+}
+
+func TestTollMatchSection(t *testing.T) {
+	s := NewTollService(testTariffs)
+
+	tests := []struct {
+		name     string
+		maneuver Maneuver
+		want     string // "" if no section matches
+	}{
+		{"single section of the road", tollManeuver(1, nil, "A84"), "a84"},
+		{"other reference of the road", tollManeuver(1, nil, "Autoroute des Estuaires", "E 3"), "a84"},
+		{"road reference written differently", tollManeuver(1, nil, "a-84"), "a84"},
+		{"unknown road", tollManeuver(1, nil, "A28"), ""},
+		// Both A13 sections match the road, the signs tell them apart.
+		{"sign of the second section", tollManeuver(1, &Sign{ExitToward: []string{"Mantes la Jolie"}}, "A13"), "a13-rouen-paris"},
+		{"more signs of the first section", tollManeuver(1, &Sign{ExitToward: []string{"Caen", "Paris"}, ExitNames: []string{"ROUEN"}}, "A13"), "a13-caen-rouen"},
+		{"no sign, first section", tollManeuver(1, nil, "A13"), "a13-caen-rouen"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			section := s.matchSection(tt.maneuver)
+			got := ""
+			if section != nil {
+				got = section.ID
+			}
+			if got != tt.want {
+				t.Errorf("matchSection() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTollEstimate(t *testing.T) {
+	trip := Trip{
+		Units: valhalla.UnitsKilometers,
+		Legs: []Leg{
+			{Maneuvers: []Maneuver{
+				{Length: 3, StreetNames: []string{"A13"}}, // not tolled
+				tollManeuver(30, &Sign{ExitToward: []string{"Rouen"}}, "A13"),
+				tollManeuver(25, nil, "A13"),
+			}},
+			{Maneuvers: []Maneuver{
+				tollManeuver(40, &Sign{ExitToward: []string{"Paris"}}, "A13"),
+				tollManeuver(2.5, nil, "A28"),
+			}},
+		},
+	}
+
+	got := NewTollService(testTariffs).Estimate(trip, valhalla.CostingAuto)
+
+	if got.VehicleClass != tolls.VehicleClassCar || got.Currency != "EUR" {
+		t.Errorf("class %d in %s, want class 1 in EUR", got.VehicleClass, got.Currency)
+	}
+	// 55 km of the 110 km of the first section are prorated, the second section has no length.
+	want := []TollSectionCost{
+		{ID: "a13-caen-rouen", Name: "A13 Caen - Rouen", Operator: "SAPN", DistanceKm: 55, Price: 5.25},
+		{ID: "a13-rouen-paris", Name: "A13 Rouen - Paris", Operator: "SAPN", DistanceKm: 40, Price: 8},
+	}
+	if len(got.Sections) != len(want) {
+		t.Fatalf("sections = %+v, want %+v", got.Sections, want)
+	}
+	for i := range want {
+		if got.Sections[i] != want[i] {
+			t.Errorf("section %d = %+v, want %+v", i, got.Sections[i], want[i])
+		}
+	}
+	if got.Total != 13.25 || got.UnmatchedKm != 2.5 {
+		t.Errorf("total = %v, unmatched = %v km, want 13.25 and 2.5 km", got.Total, got.UnmatchedKm)
+	}
+}
+
+func TestTollEstimateMiles(t *testing.T) {
+	// 100 miles are more than the length of the section, whose full price is charged.
+	trip := Trip{
+		Units: valhalla.UnitsMiles,
+		Legs:  []Leg{{Maneuvers: []Maneuver{tollManeuver(100, nil, "A13")}}},
+	}
+
+	got := NewTollService(testTariffs).Estimate(trip, valhalla.CostingMotorScooter)
+
+	if got.VehicleClass != tolls.VehicleClassMotorcycle || len(got.Sections) != 1 {
+		t.Fatalf("Estimate() = %+v, want a section for class 5", got)
+	}
+	if d := got.Sections[0].DistanceKm; math.Abs(d-160.9344) > 1e-9 {
+		t.Errorf("distance = %v km, want 160.9344 km", d)
+	}
+	if got.Total != 6.3 {
+		t.Errorf("total = %v, want the full price of 6.3", got.Total)
+	}
+}
+
+func TestTollVehicleClass(t *testing.T) {
+	trip := Trip{Legs: []Leg{{Maneuvers: []Maneuver{tollManeuver(110, nil, "A13"), tollManeuver(10, nil, "A84")}}}}
+
+	tests := []struct {
+		name        string
+		options     []TollOptions
+		costing     valhalla.Costing
+		wantClass   tolls.VehicleClass
+		wantTotal   float64
+		wantUnknown float64
+	}{
+		// The A84 has no price for trucks, so its distance is unmatched.
+		{"heavy truck by default", nil, valhalla.CostingTruck, tolls.VehicleClassHeavyTruck, 31.2, 10},
+		{"configured truck class", []TollOptions{{TruckClass: tolls.VehicleClassTruck}}, valhalla.CostingTruck, tolls.VehicleClassTruck, 23.1, 10},
+		{"car", nil, valhalla.CostingAuto, tolls.VehicleClassCar, 15.5, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewTollService(testTariffs, tt.options...).Estimate(trip, tt.costing)
+			if got.VehicleClass != tt.wantClass || got.Total != tt.wantTotal || got.UnmatchedKm != tt.wantUnknown {
+				t.Errorf("Estimate() = class %d, total %v, unmatched %v km, want %d, %v, %v km",
+					got.VehicleClass, got.Total, got.UnmatchedKm, tt.wantClass, tt.wantTotal, tt.wantUnknown)
+			}
+		})
+	}
+
+	// Costings without a motor don't use toll roads.
+	for _, costing := range []valhalla.Costing{valhalla.CostingBicycle, valhalla.CostingPedestrian} {
+		if got := NewTollService(testTariffs).Estimate(trip, costing); got != nil {
+			t.Errorf("Estimate() for %s = %+v, want nil", costing, got)
+		}
+	}
+}
+
+func TestMapValhallaSign(t *testing.T) {
+	if got := mapValhallaSign(nil); got != nil {
+		t.Errorf("mapValhallaSign(nil) = %+v, want nil", got)
+	}
+
+	count := 2
+	got := mapValhallaSign(&valhalla.Sign{
+		ExitNumberElements: []valhalla.ManeuverSignElement{{Text: "13"}},
+		ExitTowardElements: []valhalla.ManeuverSignElement{{Text: "Caen", ConsecutiveCount: &count}, {Text: "Rouen"}},
+	})
+	if len(got.ExitNumbers) != 1 || got.ExitNumbers[0] != "13" ||
+		len(got.ExitToward) != 2 || got.ExitToward[0] != "Caen" || got.ExitToward[1] != "Rouen" ||
+		got.ExitBranches != nil || got.ExitNames != nil {
+		t.Errorf("mapValhallaSign() = %+v", got)
+	}
+}
@@ -0,0 +1,32 @@
+// Package words provides the word matching shared by the type-ahead searches of the services and
+// of the offline gazetteer.
+package words
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Split lowercases text and splits it on anything that isn't a letter or a digit.
+func Split(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// MatchAll reports whether each query word is the prefix of one of the words.
+func MatchAll(words, queryWords []string) bool {
+	for _, q := range queryWords {
+		found := false
+		for _, w := range words {
+			if strings.HasPrefix(w, q) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,34 @@
+package words
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	got := Split("Saint-Étienne, 42000 (Loire)")
+	want := []string{"saint", "étienne", "42000", "loire"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Split() = %q, want %q", got, want)
+	}
+}
+
+func TestMatchAll(t *testing.T) {
+	words := []string{"gare", "de", "caen", "calvados"}
+	tests := []struct {
+		query []string
+		want  bool
+	}{
+		{[]string{"cae"}, true},
+		{[]string{"gare", "cal"}, true},
+		{[]string{"caen", "paris"}, false},
+		// Words only match from their start.
+		{[]string{"aen"}, false},
+		{nil, true},
+	}
+	for _, tt := range tests {
+		if got := MatchAll(words, tt.query); got != tt.want {
+			t.Errorf("MatchAll(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}